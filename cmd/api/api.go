@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envFile     string
+	logLevelInt int
+	logLevel    zerolog.Level = zerolog.InfoLevel
+	// The root command of our program
+	rootCmd = &cobra.Command{
+		Use:   "mds-us-api",
+		Short: "MDS query service for US data.",
+	}
+)
+
+// Go, go, go
+func main() {
+	rootCmd.Execute()
+}
+
+func init() {
+	cobra.OnInitialize(initConfig)
+
+	// Bind our args to the command
+	rootCmd.PersistentFlags().StringVar(&envFile, "env", "", "The env file to read.")
+	rootCmd.PersistentFlags().IntVar(&logLevelInt, "log", 1, "The logging level to use.")
+
+	rootCmd.AddCommand(serverCmd)
+}
+
+func initConfig() {
+	setLogLevel()
+
+	if envFile != "" {
+		err := godotenv.Load(envFile)
+		if err != nil {
+			log.Info().Err(err).Msg("failed to load env file")
+		}
+	}
+}
+
+func setLogLevel() {
+	logLevel = zerolog.Level(logLevelInt)
+}
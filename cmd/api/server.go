@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/metdatasystem/us/internal/api"
+	"github.com/spf13/cobra"
+)
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Serving the HTTP query API",
+	Run: func(cmd *cobra.Command, args []string) {
+		api.Serve(logLevel)
+	},
+}
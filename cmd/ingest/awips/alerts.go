@@ -0,0 +1,17 @@
+package main
+
+import (
+	internal "github.com/metdatasystem/us/internal/ingest/awips"
+	"github.com/spf13/cobra"
+)
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "Ingesting from an alerts feed",
+	Long: `Ingest alerts from api.weather.gov or another CAP ATOM or JSON-LD feed.
+	Polls the feed and sends alerts carrying VTEC to be checked against the products from NWWS,
+	filling in any that are missing. Does not need NWWS credentials.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.Alerts(logLevel)
+	},
+}
@@ -34,6 +34,9 @@ func init() {
 
 	rootCmd.AddCommand(nwwsCmd)
 	rootCmd.AddCommand(localCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(ldmCmd)
+	rootCmd.AddCommand(alertsCmd)
 }
 
 func initConfig() {
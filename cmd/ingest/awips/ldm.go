@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	internal "github.com/metdatasystem/us/internal/ingest/awips"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ldmPath     string
+	ldmInterval time.Duration
+)
+
+var ldmCmd = &cobra.Command{
+	Use:   "ldm",
+	Short: "Ingesting from LDM files",
+	Long: `Ingest text products that an LDM writes to disk.
+	Watches a directory for files written by pqact, reads the NOAAPort framed products from them,
+	and makes them available to other MDS services. Useful without NWWS credentials.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		internal.LDM(ldmPath, ldmInterval, logLevel)
+	},
+}
+
+func init() {
+	ldmCmd.Flags().StringVarP(&ldmPath, "path", "p", ".", "The directory the LDM writes products to.")
+	ldmCmd.Flags().DurationVar(&ldmInterval, "interval", 2*time.Second, "How often to check the directory for new products.")
+}
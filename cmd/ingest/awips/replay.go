@@ -0,0 +1,34 @@
+package main
+
+import (
+	internal "github.com/metdatasystem/us/internal/ingest/awips"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayPath  string
+	replaySpeed string
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replaying archived products",
+	Long: `Replay archived products in the order they were issued.
+	Reads a directory of product files or the NDJSON archives written by the ingest archive, and republishes
+	them to other MDS services with their original timing scaled by the speed. Copies of a product archived
+	from more than one source are only sent once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		speed, err := internal.ParseReplaySpeed(replaySpeed)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to parse replay speed")
+			return
+		}
+		internal.Replay(replayPath, speed, logLevel)
+	},
+}
+
+func init() {
+	replayCmd.Flags().StringVarP(&replayPath, "path", "p", ".", "The path to replay from. Can be a file or a directory.")
+	replayCmd.Flags().StringVar(&replaySpeed, "speed", "1", "How much faster than real time to replay, such as 1, 10x or max.")
+}
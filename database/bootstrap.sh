@@ -1,6 +1,6 @@
 psql -h localhost -U postgres -f "./init.sql" || exit 2
 
-FILES=("public" "postgis" "awips" "vtec" "warnings" "mcd")
+FILES=("public" "postgis" "awips" "vtec" "warnings" "mcd" "watches" "lsr" "outlooks" "tropical")
 
 # Load tables
 for sql_file in ${FILES[@]}; do        
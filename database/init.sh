@@ -5,7 +5,7 @@ echo "Initializing database..."
 
 psql -U postgres -f "/docker-entrypoint-initdb.d/init.sql"
 
-FILES=("public" "postgis" "awips" "vtec" "warnings" "mcd")
+FILES=("public" "postgis" "awips" "vtec" "warnings" "mcd" "watches" "lsr" "outlooks" "tropical")
 
 # Load tables
 for sql_file in ${FILES[@]}; do        
@@ -13,7 +13,7 @@ for sql_file in ${FILES[@]}; do
     psql -U postgres -d mds -f "./schemas/$sql_file.sql"
 done
     
-psql -U postgres -c "ALTER DATABASE mds SET search_path = public, postgis, awips, vtec, warnings, mcd"
+psql -U postgres -c "ALTER DATABASE mds SET search_path = public, postgis, awips, vtec, warnings, mcd, watches, lsr, outlooks, tropical"
 
 # Load data
 for sql_file in states offices vtec cron; do
@@ -0,0 +1,7 @@
+# Bring an existing database up to date with the schemas. Each migration can be run more than once.
+HOST=${1:-localhost}
+
+for sql_file in ./migrations/*.sql; do
+    echo "Running $sql_file"
+    psql -v "ON_ERROR_STOP=1" -h "$HOST" -U postgres -d mds -f "$sql_file" || exit 2
+done
@@ -0,0 +1,18 @@
+-- Hydrologic VTEC columns for databases created before they were added to the schemas
+ALTER TABLE vtec.updates
+    ADD COLUMN IF NOT EXISTS nwsli char(5),
+    ADD COLUMN IF NOT EXISTS flood_severity char(1),
+    ADD COLUMN IF NOT EXISTS flood_cause char(2),
+    ADD COLUMN IF NOT EXISTS flood_begin timestamptz,
+    ADD COLUMN IF NOT EXISTS flood_crest timestamptz,
+    ADD COLUMN IF NOT EXISTS flood_end timestamptz,
+    ADD COLUMN IF NOT EXISTS flood_record char(2);
+
+ALTER TABLE warnings.warnings
+    ADD COLUMN IF NOT EXISTS nwsli char(5),
+    ADD COLUMN IF NOT EXISTS flood_severity char(1),
+    ADD COLUMN IF NOT EXISTS flood_cause char(2),
+    ADD COLUMN IF NOT EXISTS flood_begin timestamptz,
+    ADD COLUMN IF NOT EXISTS flood_crest timestamptz,
+    ADD COLUMN IF NOT EXISTS flood_end timestamptz,
+    ADD COLUMN IF NOT EXISTS flood_record char(2);
@@ -0,0 +1,16 @@
+-- Products are stored once per ID so retransmissions can be recognised. Databases created
+-- before the constraint may hold copies, of which the first stored is kept.
+DELETE FROM awips.products a
+    USING awips.products b
+    WHERE a.product_id = b.product_id AND a.issued = b.issued AND a.id > b.id;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'awips.products'::regclass AND conname = 'products_product_id_issued_key'
+    ) THEN
+        ALTER TABLE awips.products ADD CONSTRAINT products_product_id_issued_key UNIQUE (product_id, issued);
+    END IF;
+END
+$$;
@@ -0,0 +1,3 @@
+-- Mark products rebuilt from alerts for databases created before the column was added to the schemas
+ALTER TABLE awips.products
+    ADD COLUMN IF NOT EXISTS backfilled boolean NOT NULL DEFAULT false;
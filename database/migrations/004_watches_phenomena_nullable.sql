@@ -0,0 +1,2 @@
+-- Watches are created by whichever product arrives first, which may not name the type of watch
+ALTER TABLE watches.watches ALTER COLUMN phenomena DROP NOT NULL;
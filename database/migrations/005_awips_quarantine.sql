@@ -0,0 +1,14 @@
+-- Quarantine for databases created before it was added to the schemas
+CREATE TABLE IF NOT EXISTS awips.quarantine (
+    id serial PRIMARY KEY,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    received_at timestamptz NOT NULL,
+    wmo varchar(6),
+    awips varchar(6),
+    error text NOT NULL,
+    data text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS quarantine_received_at ON awips.quarantine(received_at);
+ALTER TABLE awips.quarantine OWNER TO mds;
+GRANT ALL ON TABLE awips.quarantine TO awips_service;
+GRANT SELECT ON TABLE awips.quarantine TO nobody, api_service;
@@ -0,0 +1,40 @@
+-- Indexes for listing and searching products, for databases whose partitions were created
+-- before they were added to the schemas
+DO $$
+DECLARE
+    partition regclass;
+    suffix text;
+BEGIN
+    FOR partition IN
+        SELECT inhrelid::regclass FROM pg_inherits WHERE inhparent = 'awips.products'::regclass
+    LOOP
+        suffix := substring(partition::text FROM '_(\d+)$');
+        EXECUTE format('CREATE INDEX IF NOT EXISTS product_%s_source_awips ON %s(source, awips, issued);',
+            suffix, partition);
+        EXECUTE format('CREATE INDEX IF NOT EXISTS product_%s_text_search ON %s USING GIN (to_tsvector(''english'', data));',
+            suffix, partition);
+    END LOOP;
+END
+$$;
+
+-- Partitions created later get them too
+CREATE OR REPLACE FUNCTION awips.CREATE_YEARLY_PARTITIONS (starts INTEGER, ends INTEGER) RETURNS VOID AS $$
+BEGIN
+    FOR year IN starts..ends
+    LOOP
+	    -- Products
+        PERFORM create_yearly_range_partition('awips.products', year);
+	    EXECUTE format('
+            	CREATE INDEX product_%s_product_id ON awips.products_%s(product_id);',
+            	year, year);
+	    EXECUTE format('
+            	CREATE INDEX product_%s_source_awips ON awips.products_%s(source, awips, issued);',
+            	year, year);
+	    EXECUTE format('
+            	CREATE INDEX product_%s_text_search ON awips.products_%s USING GIN (to_tsvector(''english'', data));',
+            	year, year);
+        EXECUTE format('GRANT ALL ON TABLE awips.products_%s TO mds;', year);
+        EXECUTE format('GRANT SELECT ON TABLE awips.products_%s TO nobody, api_service;', year);
+    END LOOP;
+END
+$$ LANGUAGE PLPGSQL;
@@ -13,13 +13,32 @@ CREATE TABLE IF NOT EXISTS awips.products (
     wmo char(6) NOT NULL,
     awips char(6) NOT NULL,
     bbb varchar(3),
+    -- Rebuilt from an alert because the product was missing from the stream
+    backfilled boolean NOT NULL DEFAULT false,
 	PRIMARY KEY (id, issued),
-    UNIQUE ( issued, wmo, awips, bbb, id)
+    UNIQUE ( issued, wmo, awips, bbb, id),
+    UNIQUE (product_id, issued)
 ) PARTITION BY RANGE (issued);
 ALTER TABLE awips.products OWNER TO mds;
 GRANT ALL ON TABLE awips.products TO awips_service;
 GRANT SELECT ON TABLE awips.products TO nobody, api_service;
 
+-- Quarantine --
+-- Raw text that could not be parsed or archived
+CREATE TABLE IF NOT EXISTS awips.quarantine (
+    id serial PRIMARY KEY,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    received_at timestamptz NOT NULL,
+    wmo varchar(6),
+    awips varchar(6),
+    error text NOT NULL,
+    data text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS quarantine_received_at ON awips.quarantine(received_at);
+ALTER TABLE awips.quarantine OWNER TO mds;
+GRANT ALL ON TABLE awips.quarantine TO awips_service;
+GRANT SELECT ON TABLE awips.quarantine TO nobody, api_service;
+
 -- Create all yearly table
 CREATE OR REPLACE FUNCTION awips.CREATE_YEARLY_PARTITIONS (starts INTEGER, ends INTEGER) RETURNS VOID AS $$
 BEGIN
@@ -30,6 +49,12 @@ BEGIN
 	    EXECUTE format('
             	CREATE INDEX product_%s_product_id ON awips.products_%s(product_id);',
             	year, year);
+	    EXECUTE format('
+            	CREATE INDEX product_%s_source_awips ON awips.products_%s(source, awips, issued);',
+            	year, year);
+	    EXECUTE format('
+            	CREATE INDEX product_%s_text_search ON awips.products_%s USING GIN (to_tsvector(''english'', data));',
+            	year, year);
         EXECUTE format('GRANT ALL ON TABLE awips.products_%s TO mds;', year);
         EXECUTE format('GRANT SELECT ON TABLE awips.products_%s TO nobody, api_service;', year);
     END LOOP;
@@ -0,0 +1,55 @@
+CREATE SCHEMA IF NOT EXISTS lsr;
+ALTER SCHEMA lsr OWNER TO mds;
+
+-- Local Storm Reports --
+CREATE TABLE IF NOT EXISTS lsr.reports (
+    id serial,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    product varchar(38) NOT NULL,
+    wfo char(4) NOT NULL REFERENCES postgis.offices(icao),
+    valid timestamptz NOT NULL,
+    year int NOT NULL,
+    event varchar(24) NOT NULL,
+    magnitude real,
+    units varchar(12),
+    qualifier char(1),
+    location varchar(64) NOT NULL,
+    county varchar(32),
+    state char(2),
+    source varchar(64),
+    remarks text,
+    geom geometry(Point, 4326),
+	PRIMARY KEY (id, year),
+    -- Summary LSRs repeat earlier reports so they are only stored once
+    UNIQUE (wfo, valid, event, location, year)
+) PARTITION BY LIST (year);
+ALTER TABLE lsr.reports OWNER TO mds;
+GRANT ALL ON TABLE lsr.reports TO awips_service;
+GRANT SELECT ON TABLE lsr.reports TO nobody, api_service;
+
+CREATE OR REPLACE FUNCTION lsr.CREATE_YEARLY_PARTITIONS (starts INTEGER, ends INTEGER) RETURNS VOID AS $$
+BEGIN
+    FOR year IN starts..ends
+    LOOP
+        -- Reports
+	    PERFORM create_yearly_list_partition('lsr.reports', year);
+        EXECUTE format('
+            	CREATE INDEX reports_%s_geom ON lsr.reports_%s USING GIST (geom);',
+            	year, year);
+        EXECUTE format('
+            	CREATE INDEX reports_%s_valid ON lsr.reports_%s(valid);',
+            	year, year);
+        EXECUTE format('ALTER TABLE lsr.reports_%s OWNER TO mds;', year);
+        EXECUTE format('GRANT ALL ON TABLE lsr.reports_%s TO awips_service;', year);
+        EXECUTE format('GRANT SELECT ON TABLE lsr.reports_%s TO nobody, api_service;', year);
+    END LOOP;
+END
+$$ LANGUAGE PLPGSQL;
+
+-- Create the current decade partitions
+DO $$
+BEGIN
+    PERFORM lsr.CREATE_YEARLY_PARTITIONS(2020, 2030);
+END
+$$;
@@ -31,6 +31,9 @@ BEGIN
         EXECUTE format('
             	CREATE INDEX mcd_%s_geom ON mcd.mcd_%s USING GIST (geom);',
             	year, year);
+        EXECUTE format('
+            	CREATE INDEX mcd_%s_expires ON mcd.mcd_%s(expires);',
+            	year, year);
         EXECUTE format('ALTER TABLE mcd.mcd_%s OWNER TO mds;', year);
         EXECUTE format('GRANT ALL ON TABLE mcd.mcd_%s TO awips_service;', year);
         EXECUTE format('GRANT SELECT ON TABLE mcd.mcd_%s TO nobody, api_service;', year);
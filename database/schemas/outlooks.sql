@@ -0,0 +1,76 @@
+CREATE SCHEMA IF NOT EXISTS outlooks;
+ALTER SCHEMA outlooks OWNER TO mds;
+
+-- Outlooks --
+CREATE TABLE IF NOT EXISTS outlooks.outlooks (
+    id serial,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    product varchar(38) NOT NULL,
+    issued timestamptz NOT NULL,
+    day smallint NOT NULL,
+    starts timestamptz NOT NULL,
+    expires timestamptz NOT NULL,
+    year int NOT NULL,
+	PRIMARY KEY (id, year),
+    UNIQUE (product, day, year)
+) PARTITION BY LIST (year);
+ALTER TABLE outlooks.outlooks OWNER TO mds;
+GRANT ALL ON TABLE outlooks.outlooks TO awips_service;
+GRANT SELECT ON TABLE outlooks.outlooks TO nobody, api_service;
+
+-- Areas --
+CREATE TABLE IF NOT EXISTS outlooks.areas (
+    id serial,
+    outlook int NOT NULL,
+    year int NOT NULL,
+    hazard varchar(16) NOT NULL,
+    threshold varchar(8) NOT NULL,
+    geom geometry(MultiPolygon, 4326) NOT NULL,
+    FOREIGN KEY (outlook, year) REFERENCES outlooks.outlooks(id, year) ON DELETE CASCADE,
+	PRIMARY KEY (id, year)
+) PARTITION BY LIST (year);
+ALTER TABLE outlooks.areas OWNER TO mds;
+GRANT ALL ON TABLE outlooks.areas TO awips_service;
+GRANT SELECT ON TABLE outlooks.areas TO nobody, api_service;
+
+-- The latest outlook for the day that was valid at the given time
+CREATE OR REPLACE FUNCTION outlooks.VALID_OUTLOOK (outlook_day INTEGER, valid_at TIMESTAMPTZ) RETURNS SETOF outlooks.outlooks AS $$
+    SELECT * FROM outlooks.outlooks
+    WHERE day = outlook_day AND starts <= valid_at AND expires > valid_at AND issued <= valid_at
+    ORDER BY issued DESC LIMIT 1;
+$$ LANGUAGE SQL STABLE;
+ALTER FUNCTION outlooks.VALID_OUTLOOK OWNER TO mds;
+
+CREATE OR REPLACE FUNCTION outlooks.CREATE_YEARLY_PARTITIONS (starts INTEGER, ends INTEGER) RETURNS VOID AS $$
+BEGIN
+    FOR year IN starts..ends
+    LOOP
+        -- Outlooks
+	    PERFORM create_yearly_list_partition('outlooks.outlooks', year);
+        EXECUTE format('
+            	CREATE INDEX outlooks_%s_valid ON outlooks.outlooks_%s(day, starts, expires);',
+            	year, year);
+        EXECUTE format('ALTER TABLE outlooks.outlooks_%s OWNER TO mds;', year);
+        EXECUTE format('GRANT ALL ON TABLE outlooks.outlooks_%s TO awips_service;', year);
+        EXECUTE format('GRANT SELECT ON TABLE outlooks.outlooks_%s TO nobody, api_service;', year);
+        -- Areas
+	    PERFORM create_yearly_list_partition('outlooks.areas', year);
+        EXECUTE format('
+            	CREATE INDEX areas_%s_geom ON outlooks.areas_%s USING GIST (geom);',
+            	year, year);
+        EXECUTE format('
+            	CREATE INDEX areas_%s_outlook ON outlooks.areas_%s(outlook);',
+            	year, year);
+        EXECUTE format('ALTER TABLE outlooks.areas_%s OWNER TO mds;', year);
+        EXECUTE format('GRANT ALL ON TABLE outlooks.areas_%s TO awips_service;', year);
+        EXECUTE format('GRANT SELECT ON TABLE outlooks.areas_%s TO nobody, api_service;', year);
+    END LOOP;
+END
+$$ LANGUAGE PLPGSQL;
+
+-- Create the current decade partitions
+DO $$
+BEGIN
+    PERFORM outlooks.CREATE_YEARLY_PARTITIONS(2020, 2030);
+END
+$$;
@@ -0,0 +1,98 @@
+CREATE SCHEMA IF NOT EXISTS tropical;
+ALTER SCHEMA tropical OWNER TO mds;
+
+-- Storms --
+CREATE TABLE IF NOT EXISTS tropical.storms (
+    id char(8) PRIMARY KEY, -- ATCF storm ID, e.g. AL142024
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    basin char(2) NOT NULL,
+    number smallint NOT NULL,
+    year smallint NOT NULL,
+    name varchar(64) NOT NULL,
+    classification varchar(32)
+);
+ALTER TABLE tropical.storms OWNER TO mds;
+GRANT ALL ON TABLE tropical.storms TO awips_service;
+GRANT SELECT ON TABLE tropical.storms TO nobody, api_service;
+
+-- Advisories --
+-- The TCM and TCP of the same advisory fill in the same record. Intermediate advisories only have a TCP.
+CREATE TABLE IF NOT EXISTS tropical.advisories (
+    id serial PRIMARY KEY,
+    storm char(8) NOT NULL REFERENCES tropical.storms(id) ON DELETE CASCADE,
+    advisory varchar(4) NOT NULL,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    issued timestamptz NOT NULL,
+    intermediate boolean DEFAULT false,
+    name varchar(64) NOT NULL,
+    classification varchar(32),
+    center geometry(Point, 4326),
+    observed timestamptz,
+    accuracy int,
+    movement_direction int,
+    movement_speed int, -- Knots
+    movement_speed_mph int,
+    pressure int,
+    eye_diameter int,
+    max_wind int, -- Knots
+    max_wind_mph int,
+    gusts int,
+    wind_radii jsonb,
+    sea_radii jsonb,
+    tcm_product varchar(38),
+    tcp_product varchar(38),
+    UNIQUE (storm, advisory)
+);
+CREATE INDEX IF NOT EXISTS advisories_storm_issued ON tropical.advisories(storm, issued);
+ALTER TABLE tropical.advisories OWNER TO mds;
+GRANT ALL ON TABLE tropical.advisories TO awips_service;
+GRANT SELECT ON TABLE tropical.advisories TO nobody, api_service;
+
+-- Forecast points --
+CREATE TABLE IF NOT EXISTS tropical.forecasts (
+    id serial PRIMARY KEY,
+    advisory int NOT NULL REFERENCES tropical.advisories(id) ON DELETE CASCADE,
+    valid timestamptz NOT NULL,
+    outlook boolean DEFAULT false,
+    status varchar(32),
+    center geometry(Point, 4326),
+    max_wind int,
+    gusts int,
+    wind_radii jsonb
+);
+CREATE INDEX IF NOT EXISTS forecasts_advisory ON tropical.forecasts(advisory);
+ALTER TABLE tropical.forecasts OWNER TO mds;
+GRANT ALL ON TABLE tropical.forecasts TO awips_service;
+GRANT SELECT ON TABLE tropical.forecasts TO nobody, api_service;
+
+-- TCV segments --
+CREATE TABLE IF NOT EXISTS tropical.tcv (
+    id serial PRIMARY KEY,
+    storm char(8) NOT NULL REFERENCES tropical.storms(id) ON DELETE CASCADE,
+    advisory varchar(4) NOT NULL,
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    issued timestamptz NOT NULL,
+    wfo char(4) NOT NULL,
+    product varchar(38) NOT NULL,
+    vtec varchar(48)[],
+    ugc char(6)[],
+    locations text[],
+    wind_forecast text,
+    wind_peak text,
+    wind_threat varchar(16),
+    surge_forecast text,
+    surge_peak text,
+    surge_threat varchar(16),
+    rain_forecast text,
+    rain_peak text,
+    rain_threat varchar(16),
+    tornado_forecast text,
+    tornado_threat varchar(16)
+);
+CREATE INDEX IF NOT EXISTS tcv_storm_issued ON tropical.tcv(storm, issued);
+CREATE INDEX IF NOT EXISTS tcv_ugc ON tropical.tcv USING GIN (ugc);
+ALTER TABLE tropical.tcv OWNER TO mds;
+GRANT ALL ON TABLE tropical.tcv TO awips_service;
+GRANT SELECT ON TABLE tropical.tcv TO nobody, api_service;
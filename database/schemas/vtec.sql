@@ -130,6 +130,15 @@ CREATE TABLE IF NOT EXISTS vtec.updates (
     snow_squall varchar(64),
     snow_squall_tag varchar(64),
 
+    -- Hydrologic VTEC
+    nwsli char(5),
+    flood_severity char(1),
+    flood_cause char(2),
+    flood_begin timestamptz,
+    flood_crest timestamptz,
+    flood_end timestamptz,
+    flood_record char(2),
+
     -- Porduct data
     text text NOT NULL,
     product varchar(38) NOT NULL,
@@ -53,6 +53,15 @@ CREATE TABLE IF NOT EXISTS warnings.warnings (
     snow_squall varchar(64),
     snow_squall_tag varchar(64),
 
+    -- Hydrologic VTEC
+    nwsli char(5),
+    flood_severity char(1),
+    flood_cause char(2),
+    flood_begin timestamptz,
+    flood_crest timestamptz,
+    flood_end timestamptz,
+    flood_record char(2),
+
 	PRIMARY KEY (wfo, phenomena, significance, event_number, year, id)
 );
 CREATE INDEX IF NOT EXISTS warnings_issued ON warnings.warnings(issued);
@@ -0,0 +1,89 @@
+CREATE SCHEMA IF NOT EXISTS watches;
+ALTER SCHEMA watches OWNER TO mds;
+
+-- Watches --
+-- A single record per SPC watch combining the SEL, WWP, SAW and WOU products.
+CREATE TABLE IF NOT EXISTS watches.watches (
+    -- Combined key
+    number smallint NOT NULL,
+    year smallint NOT NULL,
+
+    -- Left empty until a product names the type of watch
+    phenomena char(2) REFERENCES vtec.phenomena(id),
+
+    -- State
+    created_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+    action char(3) DEFAULT NULL REFERENCES vtec.action(id),
+    issued timestamptz NOT NULL,
+    expires timestamptz DEFAULT NULL,
+    is_pds boolean DEFAULT false,
+    summary text,
+
+    -- Geospatial
+    states char(2)[],
+    geom geometry(Polygon, 4326),
+    ugc char(6)[] DEFAULT '{}',
+
+    -- Probabilities
+    prob_tornadoes smallint,
+    prob_strong_tornadoes smallint,
+    prob_severe_wind smallint,
+    prob_significant_wind smallint,
+    prob_severe_hail smallint,
+    prob_significant_hail smallint,
+    prob_combined_hail_wind smallint,
+
+    -- Attributes
+    max_hail real,
+    max_wind_gust smallint,
+    max_tops smallint,
+    motion_direction smallint,
+    motion_speed smallint,
+
+    -- Products
+    sel_product varchar(38),
+    wwp_product varchar(38),
+    saw_product varchar(38),
+    wou_product varchar(38),
+
+    PRIMARY KEY (number, year)
+) PARTITION BY LIST (year);
+ALTER TABLE watches.watches OWNER TO mds;
+GRANT ALL ON TABLE watches.watches TO awips_service;
+GRANT SELECT ON TABLE watches.watches TO nobody, api_service;
+
+-- The VTEC events issued by each WFO for a watch share the watch number as their event number
+CREATE OR REPLACE VIEW watches.events AS
+    SELECT w.number, w.year, e.*
+    FROM watches.watches w
+    JOIN vtec.events e ON e.phenomena = w.phenomena AND e.significance = 'A'
+        AND e.event_number = w.number AND e.year = w.year;
+ALTER VIEW watches.events OWNER TO mds;
+GRANT SELECT ON watches.events TO awips_service, nobody, api_service;
+
+CREATE OR REPLACE FUNCTION watches.CREATE_YEARLY_PARTITIONS (starts INTEGER, ends INTEGER) RETURNS VOID AS $$
+BEGIN
+    FOR year IN starts..ends
+    LOOP
+        -- Watches
+	    PERFORM create_yearly_list_partition('watches.watches', year);
+        EXECUTE format('
+            	CREATE INDEX watches_%s_geom ON watches.watches_%s USING GIST (geom);',
+            	year, year);
+        EXECUTE format('
+            	CREATE INDEX watches_%s_expires ON watches.watches_%s(expires);',
+            	year, year);
+        EXECUTE format('ALTER TABLE watches.watches_%s OWNER TO mds;', year);
+        EXECUTE format('GRANT ALL ON TABLE watches.watches_%s TO awips_service;', year);
+        EXECUTE format('GRANT SELECT ON TABLE watches.watches_%s TO nobody, api_service;', year);
+    END LOOP;
+END
+$$ LANGUAGE PLPGSQL;
+
+-- Create the current decade partitions
+DO $$
+BEGIN
+    PERFORM watches.CREATE_YEARLY_PARTITIONS(2020, 2030);
+END
+$$;
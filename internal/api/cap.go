@@ -0,0 +1,450 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/wkb"
+)
+
+const (
+	capNamespace = "urn:oasis:names:tc:emergency:cap:1.2"
+	// CAP times must carry their offset, so Z is not allowed. UTC is written as -00:00.
+	capTimeLayout     = "2006-01-02T15:04:05-07:00"
+	vtecTimeLayout    = "060102T1504Z"
+	defaultCAPSender  = "metdatasystem.us"
+	vtecTimeUndefined = "000000T0000Z"
+)
+
+// A CAP 1.2 alert. See https://docs.oasis-open.org/emergency/cap/v1.2/CAP-v1.2.html
+type capAlert struct {
+	XMLName    xml.Name `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+	Identifier string   `xml:"identifier"`
+	Sender     string   `xml:"sender"`
+	Sent       string   `xml:"sent"`
+	Status     string   `xml:"status"`
+	MsgType    string   `xml:"msgType"`
+	Scope      string   `xml:"scope"`
+	Info       capInfo  `xml:"info"`
+}
+
+type capInfo struct {
+	Language     string     `xml:"language"`
+	Category     string     `xml:"category"`
+	Event        string     `xml:"event"`
+	ResponseType string     `xml:"responseType,omitempty"`
+	Urgency      string     `xml:"urgency"`
+	Severity     string     `xml:"severity"`
+	Certainty    string     `xml:"certainty"`
+	EventCode    []capValue `xml:"eventCode"`
+	Effective    string     `xml:"effective"`
+	Onset        string     `xml:"onset,omitempty"`
+	Expires      string     `xml:"expires"`
+	SenderName   string     `xml:"senderName"`
+	Headline     string     `xml:"headline"`
+	Description  string     `xml:"description"`
+	Web          string     `xml:"web,omitempty"`
+	Parameters   []capValue `xml:"parameter"`
+	Area         capArea    `xml:"area"`
+}
+
+type capValue struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+type capArea struct {
+	AreaDesc string     `xml:"areaDesc"`
+	Polygons []string   `xml:"polygon"`
+	Geocodes []capValue `xml:"geocode"`
+}
+
+// The sender of our CAP alerts, from CAP_SENDER
+func capSender() string {
+	if sender := os.Getenv("CAP_SENDER"); sender != "" {
+		return sender
+	}
+	return defaultCAPSender
+}
+
+func capTime(t time.Time) string {
+	return strings.TrimSuffix(t.UTC().Format(capTimeLayout), "+00:00") + "-00:00"
+}
+
+// Encode the warning as a CAP alert
+func newCAPAlert(w *warning) *capAlert {
+	onset := w.Issued
+	if w.Starts != nil && w.Starts.After(w.Issued) {
+		onset = *w.Starts
+	}
+
+	parameters := []capValue{
+		{"VTEC", w.VTEC()},
+		{"AWIPSidentifier", w.Product},
+	}
+	if w.Ends != nil {
+		parameters = append(parameters, capValue{"eventEndingTime", capTime(*w.Ends)})
+	}
+	parameters = append(parameters, w.capTags()...)
+
+	geocodes := []capValue{}
+	for _, ugc := range w.UGC {
+		geocodes = append(geocodes, capValue{"UGC", ugc})
+	}
+
+	polygons, err := capPolygons(w.Geom)
+	if err != nil {
+		log.Warn().Err(err).Str("warning", w.CompositeID()).Msg("failed to decode warning geometry")
+	}
+
+	return &capAlert{
+		Identifier: fmt.Sprintf("%s.%s", capSender(), w.CompositeID()),
+		Sender:     capSender(),
+		Sent:       capTime(w.Issued),
+		Status:     w.capStatus(),
+		MsgType:    w.capMsgType(),
+		Scope:      "Public",
+		Info: capInfo{
+			Language:     "en-US",
+			Category:     "Met",
+			Event:        w.Title,
+			ResponseType: w.capResponseType(),
+			Urgency:      w.capUrgency(),
+			Severity:     w.capSeverity(),
+			Certainty:    w.capCertainty(),
+			EventCode: []capValue{
+				{"NationalWeatherService", w.Phenomena + w.Significance},
+			},
+			Effective:   capTime(w.Issued),
+			Onset:       capTime(onset),
+			Expires:     capTime(w.Expires),
+			SenderName:  "NWS " + w.WFO,
+			Headline:    fmt.Sprintf("%s issued %s by NWS %s", w.Title, w.Issued.UTC().Format("January 2 at 15:04 UTC"), w.WFO),
+			Description: strings.TrimSpace(w.Text),
+			Parameters:  parameters,
+			Area: capArea{
+				AreaDesc: strings.Join(w.UGC, "; "),
+				Polygons: polygons,
+				Geocodes: geocodes,
+			},
+		},
+	}
+}
+
+// Rebuild the VTEC string of the warning's latest action
+func (w *warning) VTEC() string {
+	starts := vtecTimeUndefined
+	if w.Starts != nil {
+		starts = w.Starts.UTC().Format(vtecTimeLayout)
+	}
+	ends := vtecTimeUndefined
+	if w.Ends != nil {
+		ends = w.Ends.UTC().Format(vtecTimeLayout)
+	}
+
+	return fmt.Sprintf("/%s.%s.%s.%s.%s.%04d.%s-%s/", w.Class, w.Action, w.WFO, w.Phenomena, w.Significance, w.EventNumber, starts, ends)
+}
+
+func (w *warning) capStatus() string {
+	if w.Class == "T" {
+		return "Test"
+	}
+	return "Actual"
+}
+
+func (w *warning) capMsgType() string {
+	switch w.Action {
+	case "NEW":
+		return "Alert"
+	case "CAN", "UPG", "EXP":
+		return "Cancel"
+	}
+	return "Update"
+}
+
+// Whether the warning has ended
+func (w *warning) ended() bool {
+	return w.capMsgType() == "Cancel"
+}
+
+// Short fused warnings that call for action straight away
+func (w *warning) shortFused() bool {
+	switch w.Phenomena {
+	case "TO", "SV", "FF", "EW", "SQ", "MA", "DS":
+		return w.Significance == "W"
+	}
+	return false
+}
+
+func (w *warning) capResponseType() string {
+	if w.ended() {
+		return "AllClear"
+	}
+	switch w.Significance {
+	case "W":
+		if w.Phenomena == "FF" || w.Phenomena == "FA" || w.Phenomena == "FL" {
+			return "Avoid"
+		}
+		if w.shortFused() {
+			return "Shelter"
+		}
+		return "Prepare"
+	case "A":
+		return "Monitor"
+	case "Y", "S":
+		return "Execute"
+	}
+	return ""
+}
+
+func (w *warning) capUrgency() string {
+	if w.ended() {
+		return "Past"
+	}
+	if w.shortFused() {
+		return "Immediate"
+	}
+	switch w.Significance {
+	case "W", "Y", "S":
+		return "Expected"
+	case "A", "O", "F", "N":
+		return "Future"
+	}
+	return "Unknown"
+}
+
+func (w *warning) capSeverity() string {
+	if w.ended() {
+		return "Minor"
+	}
+	switch {
+	case w.IsEmergency, strings.EqualFold(w.Damage, "CATASTROPHIC"), strings.EqualFold(w.Damage, "DESTRUCTIVE"):
+		return "Extreme"
+	case w.IsPDS, strings.EqualFold(w.Damage, "CONSIDERABLE"):
+		if w.Significance == "W" {
+			return "Extreme"
+		}
+		return "Severe"
+	}
+	switch w.Significance {
+	case "W", "A":
+		return "Severe"
+	case "Y":
+		return "Moderate"
+	case "S":
+		return "Minor"
+	}
+	return "Unknown"
+}
+
+func (w *warning) capCertainty() string {
+	if w.ended() {
+		return "Observed"
+	}
+	switch {
+	case w.IsEmergency, strings.HasPrefix(strings.ToUpper(w.Tornado), "OBSERVED"),
+		strings.HasPrefix(strings.ToUpper(w.FlashFlood), "OBSERVED"), strings.HasPrefix(strings.ToUpper(w.SnowSquall), "OBSERVED"):
+		return "Observed"
+	}
+	switch w.Significance {
+	case "W", "Y", "S":
+		return "Likely"
+	case "A", "O":
+		return "Possible"
+	}
+	return "Unknown"
+}
+
+// The impact based warning tags as the parameters the NWS uses in its CAP
+func (w *warning) capTags() []capValue {
+	damage := "thunderstormDamageThreat"
+	switch w.Phenomena {
+	case "TO":
+		damage = "tornadoDamageThreat"
+	case "FF":
+		damage = "flashFloodDamageThreat"
+	}
+
+	tags := []capValue{}
+	for _, tag := range []capValue{
+		{"tornadoDetection", w.Tornado},
+		{damage, w.Damage},
+		{"hailThreat", w.HailThreat},
+		{"maxHailSize", w.HailTag},
+		{"windThreat", w.WindThreat},
+		{"maxWindGust", w.WindTag},
+		{"flashFloodDetection", w.FlashFlood},
+		{"expectedRainfallRate", w.RainfallTag},
+		{"damFailure", w.FloodTagDam},
+		{"waterspoutDetection", w.SpoutTag},
+		{"snowSquallDetection", w.SnowSquall},
+		{"snowSquallImpact", w.SnowSquallTag},
+	} {
+		if tag.Value != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if w.IsPDS {
+		tags = append(tags, capValue{"particularlyDangerousSituation", "true"})
+	}
+
+	return tags
+}
+
+// The outer ring of each polygon in the WKB geometry as CAP polygons of latitude,longitude pairs
+func capPolygons(geometry []byte) ([]string, error) {
+	if len(geometry) == 0 {
+		return nil, nil
+	}
+
+	g, err := wkb.Unmarshal(geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	rings := [][]geom.Coord{}
+	switch g := g.(type) {
+	case *geom.Polygon:
+		rings = append(rings, g.LinearRing(0).Coords())
+	case *geom.MultiPolygon:
+		for i := range g.NumPolygons() {
+			rings = append(rings, g.Polygon(i).LinearRing(0).Coords())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported geometry %T", g)
+	}
+
+	polygons := []string{}
+	for _, ring := range rings {
+		points := make([]string, len(ring))
+		for i, coord := range ring {
+			points[i] = fmt.Sprintf("%.4f,%.4f", coord.Y(), coord.X())
+		}
+		polygons = append(polygons, strings.Join(points, " "))
+	}
+
+	return polygons, nil
+}
+
+// An ATOM feed indexing CAP alerts, in the style of the NWS CAP feeds
+type capFeed struct {
+	XMLName xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	CAP     string         `xml:"xmlns:cap,attr"`
+	ID      string         `xml:"id"`
+	Title   string         `xml:"title"`
+	Updated string         `xml:"updated"`
+	Author  capFeedAuthor  `xml:"author"`
+	Links   []capFeedLink  `xml:"link"`
+	Entries []capFeedEntry `xml:"entry"`
+}
+
+type capFeedAuthor struct {
+	Name string `xml:"name"`
+}
+
+type capFeedLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type capFeedEntry struct {
+	ID         string        `xml:"id"`
+	Title      string        `xml:"title"`
+	Updated    string        `xml:"updated"`
+	Published  string        `xml:"published"`
+	Link       capFeedLink   `xml:"link"`
+	Summary    string        `xml:"summary"`
+	Event      string        `xml:"cap:event"`
+	Effective  string        `xml:"cap:effective"`
+	Expires    string        `xml:"cap:expires"`
+	Status     string        `xml:"cap:status"`
+	MsgType    string        `xml:"cap:msgType"`
+	Urgency    string        `xml:"cap:urgency"`
+	Severity   string        `xml:"cap:severity"`
+	Certainty  string        `xml:"cap:certainty"`
+	AreaDesc   string        `xml:"cap:areaDesc"`
+	Polygons   []string      `xml:"cap:polygon"`
+	Geocode    capFeedValues `xml:"cap:geocode"`
+	Parameters []capValue    `xml:"cap:parameter"`
+}
+
+// Repeated name and value pairs
+type capFeedValues struct {
+	Names  []string `xml:"valueName"`
+	Values []string `xml:"value"`
+}
+
+// Index the warnings as an ATOM feed linking to their CAP alerts
+func newCAPFeed(warnings []warning, base string, updated time.Time) *capFeed {
+	self := base + "/cap/warnings"
+
+	feed := &capFeed{
+		CAP:     capNamespace,
+		ID:      self,
+		Title:   "Active warnings",
+		Updated: capTime(updated),
+		Author:  capFeedAuthor{Name: capSender()},
+		Links:   []capFeedLink{{Rel: "self", Href: self}},
+		Entries: []capFeedEntry{},
+	}
+
+	for i := range warnings {
+		alert := newCAPAlert(&warnings[i])
+		info := alert.Info
+		href := self + "/" + warnings[i].CompositeID()
+
+		updated := warnings[i].UpdatedAt
+		if updated.IsZero() {
+			updated = warnings[i].Issued
+		}
+
+		geocode := capFeedValues{}
+		if len(warnings[i].UGC) > 0 {
+			geocode = capFeedValues{Names: []string{"UGC"}, Values: []string{strings.Join(warnings[i].UGC, " ")}}
+		}
+
+		feed.Entries = append(feed.Entries, capFeedEntry{
+			ID:         href,
+			Title:      info.Headline,
+			Updated:    capTime(updated),
+			Published:  alert.Sent,
+			Link:       capFeedLink{Type: "application/cap+xml", Href: href},
+			Summary:    info.Headline,
+			Event:      info.Event,
+			Effective:  info.Effective,
+			Expires:    info.Expires,
+			Status:     alert.Status,
+			MsgType:    alert.MsgType,
+			Urgency:    info.Urgency,
+			Severity:   info.Severity,
+			Certainty:  info.Certainty,
+			AreaDesc:   info.Area.AreaDesc,
+			Polygons:   info.Area.Polygons,
+			Geocode:    geocode,
+			Parameters: []capValue{{"VTEC", warnings[i].VTEC()}},
+		})
+	}
+
+	return feed
+}
+
+func writeXML(w http.ResponseWriter, status int, contentType string, data any) {
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		log.Error().Err(err).Msg("failed to write xml response")
+		return
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		log.Error().Err(err).Msg("failed to write xml response")
+	}
+}
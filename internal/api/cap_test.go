@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/wkb"
+)
+
+func testWarning(t *testing.T) warning {
+	t.Helper()
+
+	polygon := geom.NewMultiPolygon(geom.XY).MustSetCoords([][][]geom.Coord{{{
+		{-93.63, 41.63}, {-93.52, 41.57}, {-93.70, 41.50}, {-93.63, 41.63},
+	}}})
+	geometry, err := wkb.Marshal(polygon, wkb.NDR)
+	require.NoError(t, err)
+
+	issued := time.Date(2025, 5, 19, 23, 10, 0, 0, time.UTC)
+	ends := time.Date(2025, 5, 19, 23, 45, 0, 0, time.UTC)
+
+	return warning{
+		ID:           17,
+		Phenomena:    "TO",
+		Significance: "W",
+		WFO:          "KDMX",
+		EventNumber:  42,
+		Year:         2025,
+		Action:       "NEW",
+		Current:      true,
+		Issued:       issued,
+		Starts:       &issued,
+		Expires:      ends,
+		Ends:         &ends,
+		Class:        "O",
+		Title:        "Tornado Warning",
+		Text:         "IAC153-169-192345-\n/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/\n\nTornado Warning for Story County\n",
+		Product:      "202505192310-KDMX-WFUS53-TORDMX",
+		Geom:         geometry,
+		UGC:          []string{"IAC153", "IAC169"},
+		Tornado:      "RADAR INDICATED",
+		HailTag:      "1.00",
+	}
+}
+
+func TestNewCAPAlert(t *testing.T) {
+	w := testWarning(t)
+	alert := newCAPAlert(&w)
+
+	assert.Equal(t, "metdatasystem.us.KDMX-TO-W-0042-2025-17", alert.Identifier)
+	assert.Equal(t, "2025-05-19T23:10:00-00:00", alert.Sent)
+	assert.Equal(t, "Actual", alert.Status)
+	assert.Equal(t, "Alert", alert.MsgType)
+
+	info := alert.Info
+	assert.Equal(t, "Tornado Warning", info.Event)
+	assert.Equal(t, "Immediate", info.Urgency)
+	assert.Equal(t, "Severe", info.Severity)
+	assert.Equal(t, "Likely", info.Certainty)
+	assert.Equal(t, "Shelter", info.ResponseType)
+	assert.Equal(t, "2025-05-19T23:45:00-00:00", info.Expires)
+	assert.Contains(t, info.Parameters, capValue{"VTEC", "/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/"})
+	assert.Contains(t, info.Parameters, capValue{"tornadoDetection", "RADAR INDICATED"})
+	assert.Contains(t, info.Parameters, capValue{"maxHailSize", "1.00"})
+	assert.Equal(t, []capValue{{"UGC", "IAC153"}, {"UGC", "IAC169"}}, info.Area.Geocodes)
+	assert.Equal(t, []string{"41.6300,-93.6300 41.5700,-93.5200 41.5000,-93.7000 41.6300,-93.6300"}, info.Area.Polygons)
+
+	data, err := xml.Marshal(alert)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `<alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">`))
+}
+
+func TestCAPDerivedFields(t *testing.T) {
+	w := testWarning(t)
+
+	w.Tornado = "OBSERVED"
+	w.Damage = "CATASTROPHIC"
+	w.IsEmergency = true
+	alert := newCAPAlert(&w)
+	assert.Equal(t, "Extreme", alert.Info.Severity)
+	assert.Equal(t, "Observed", alert.Info.Certainty)
+	assert.Contains(t, alert.Info.Parameters, capValue{"tornadoDamageThreat", "CATASTROPHIC"})
+
+	w = testWarning(t)
+	w.Phenomena, w.Significance = "TO", "A"
+	alert = newCAPAlert(&w)
+	assert.Equal(t, "Future", alert.Info.Urgency)
+	assert.Equal(t, "Possible", alert.Info.Certainty)
+	assert.Equal(t, "Monitor", alert.Info.ResponseType)
+
+	w = testWarning(t)
+	w.Phenomena, w.Significance = "WI", "Y"
+	alert = newCAPAlert(&w)
+	assert.Equal(t, "Expected", alert.Info.Urgency)
+	assert.Equal(t, "Moderate", alert.Info.Severity)
+
+	w = testWarning(t)
+	w.Action = "CAN"
+	w.Starts = nil
+	alert = newCAPAlert(&w)
+	assert.Equal(t, "Cancel", alert.MsgType)
+	assert.Equal(t, "Past", alert.Info.Urgency)
+	assert.Equal(t, "AllClear", alert.Info.ResponseType)
+	assert.Equal(t, "/O.CAN.KDMX.TO.W.0042.000000T0000Z-250519T2345Z/", w.VTEC())
+
+	w = testWarning(t)
+	w.Class = "T"
+	assert.Equal(t, "Test", newCAPAlert(&w).Status)
+}
+
+func TestCAPFeed(t *testing.T) {
+	w := testWarning(t)
+	feed := newCAPFeed([]warning{w}, "https://api.example.com", time.Date(2025, 5, 19, 23, 12, 0, 0, time.UTC))
+
+	require.Len(t, feed.Entries, 1)
+	entry := feed.Entries[0]
+	assert.Equal(t, "https://api.example.com/cap/warnings/KDMX-TO-W-0042-2025-17", entry.ID)
+	assert.Equal(t, "application/cap+xml", entry.Link.Type)
+	assert.Equal(t, "Immediate", entry.Urgency)
+
+	recorder := httptest.NewRecorder()
+	writeXML(recorder, 200, "application/atom+xml", feed)
+	body := recorder.Body.String()
+
+	assert.Equal(t, "application/atom+xml; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, body, `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:cap="urn:oasis:names:tc:emergency:cap:1.2">`)
+	assert.Contains(t, body, "<cap:event>Tornado Warning</cap:event>")
+	assert.Contains(t, body, "<value>IAC153 IAC169</value>")
+}
+
+func TestWarningIDFromComposite(t *testing.T) {
+	id, err := warningIDFromComposite("KDMX-TO-W-0042-2025-17")
+	require.NoError(t, err)
+	assert.Equal(t, 17, id)
+
+	id, err = warningIDFromComposite("17")
+	require.NoError(t, err)
+	assert.Equal(t, 17, id)
+
+	_, err = warningIDFromComposite("KDMX-TO-W")
+	assert.Error(t, err)
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultLimit = 25
+	maxLimit     = 100
+	// The layout of the issuance time at the start of a product ID
+	productIDTimeLayout = "200601021504"
+)
+
+type product struct {
+	ID         int       `json:"id"`
+	ProductID  string    `json:"product_id"`
+	Issued     time.Time `json:"issued"`
+	ReceivedAt time.Time `json:"received_at"`
+	Source     string    `json:"source"`
+	WMO        string    `json:"wmo"`
+	AWIPS      string    `json:"awips"`
+	BBB        string    `json:"bbb,omitempty"`
+	Text       string    `json:"text"`
+}
+
+type productList struct {
+	Products   []product `json:"products"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	NextOffset *int      `json:"next_offset,omitempty"`
+}
+
+// Filters for listing and searching products
+type productQuery struct {
+	AWIPS  string
+	Office string
+	WMO    string
+	Start  *time.Time
+	End    *time.Time
+	Search string
+	Limit  int
+	Offset int
+}
+
+// Build the query from the URL parameters
+func parseProductQuery(values url.Values) (*productQuery, error) {
+	query := &productQuery{
+		AWIPS:  strings.ToUpper(strings.TrimSpace(values.Get("awips"))),
+		Office: strings.ToUpper(strings.TrimSpace(values.Get("office"))),
+		WMO:    strings.ToUpper(strings.TrimSpace(values.Get("wmo"))),
+		Search: strings.TrimSpace(values.Get("q")),
+		Limit:  defaultLimit,
+	}
+
+	if len(query.AWIPS) > 6 {
+		return nil, fmt.Errorf("invalid awips %s", query.AWIPS)
+	}
+	if len(query.Office) > 4 {
+		return nil, fmt.Errorf("invalid office %s", query.Office)
+	}
+	if len(query.WMO) > 6 {
+		return nil, fmt.Errorf("invalid wmo %s", query.WMO)
+	}
+
+	var err error
+	if s := values.Get("start"); s != "" {
+		query.Start, err = parseQueryTime(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start %s", s)
+		}
+	}
+	if s := values.Get("end"); s != "" {
+		query.End, err = parseQueryTime(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end %s", s)
+		}
+	}
+	if query.Start != nil && query.End != nil && query.End.Before(*query.Start) {
+		return nil, errors.New("end is before start")
+	}
+
+	if s := values.Get("limit"); s != "" {
+		query.Limit, err = strconv.Atoi(s)
+		if err != nil || query.Limit < 1 {
+			return nil, fmt.Errorf("invalid limit %s", s)
+		}
+		query.Limit = min(query.Limit, maxLimit)
+	}
+	if s := values.Get("offset"); s != "" {
+		query.Offset, err = strconv.Atoi(s)
+		if err != nil || query.Offset < 0 {
+			return nil, fmt.Errorf("invalid offset %s", s)
+		}
+	}
+
+	return query, nil
+}
+
+// Times can be given as RFC 3339 or as a date
+func parseQueryTime(s string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// Build the SQL and arguments for the query. Products are ordered newest first.
+func (query *productQuery) build() (string, []any) {
+	conditions := []string{}
+	args := []any{}
+
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	// A three character AWIPS ID is the product category so matches any office
+	if query.AWIPS != "" {
+		if len(query.AWIPS) == 3 {
+			add("awips LIKE ($%d || '%%')", query.AWIPS)
+		} else {
+			add("awips = $%d", query.AWIPS)
+		}
+	}
+	if query.Office != "" {
+		add("source = $%d", query.Office)
+	}
+	if query.WMO != "" {
+		add("wmo = $%d", query.WMO)
+	}
+	if query.Start != nil {
+		add("issued >= $%d", *query.Start)
+	}
+	if query.End != nil {
+		add("issued < $%d", *query.End)
+	}
+	if query.Search != "" {
+		add("to_tsvector('english', data) @@ websearch_to_tsquery('english', $%d)", query.Search)
+	}
+
+	sql := "SELECT id, product_id, issued, received_at, source, wmo, awips, COALESCE(bbb, ''), data FROM awips.products"
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sql += fmt.Sprintf(" ORDER BY issued DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	return sql, args
+}
+
+// List products matching the filters and full-text search
+func (server *Server) listProducts(w http.ResponseWriter, r *http.Request) {
+	query, err := parseProductQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sql, args := query.build()
+	rows, err := server.db.Query(r.Context(), sql, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query products")
+		writeError(w, r, http.StatusInternalServerError, "failed to query products")
+		return
+	}
+	products, err := pgx.CollectRows(rows, scanProduct)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to scan products")
+		writeError(w, r, http.StatusInternalServerError, "failed to query products")
+		return
+	}
+
+	if wantsText(r) {
+		texts := make([]string, len(products))
+		for i, p := range products {
+			texts[i] = p.Text
+		}
+		writeText(w, http.StatusOK, strings.Join(texts, "\n\n"))
+		return
+	}
+
+	list := productList{
+		Products: products,
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	}
+	if len(products) == query.Limit {
+		next := query.Offset + query.Limit
+		list.NextOffset = &next
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Get a product by the ID generated when it was parsed
+func (server *Server) getProduct(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	issued, err := productIDTime(id)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p, err := server.findProduct(r.Context(), id, issued)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, "product not found")
+			return
+		}
+		log.Error().Err(err).Str("product", id).Msg("failed to get product")
+		writeError(w, r, http.StatusInternalServerError, "failed to get product")
+		return
+	}
+
+	if wantsText(r) {
+		writeText(w, http.StatusOK, p.Text)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (server *Server) findProduct(ctx context.Context, id string, issued time.Time) (*product, error) {
+	// Product IDs only carry the minute so the issuance time is used to find the partition
+	rows, err := server.db.Query(ctx, `
+	SELECT id, product_id, issued, received_at, source, wmo, awips, COALESCE(bbb, ''), data FROM awips.products
+	WHERE product_id = $1 AND issued >= $2 AND issued < $3 ORDER BY id DESC LIMIT 1
+	`, id, issued, issued.Add(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := pgx.CollectExactlyOneRow(rows, scanProduct)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Product IDs start with their issuance time. See generateAWIPSProductID in the parse service.
+func productIDTime(id string) (time.Time, error) {
+	if len(id) < len(productIDTimeLayout)+1 || id[len(productIDTimeLayout)] != '-' {
+		return time.Time{}, fmt.Errorf("invalid product id %s", id)
+	}
+
+	t, err := time.Parse(productIDTimeLayout, id[:len(productIDTimeLayout)])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid product id %s", id)
+	}
+
+	return t, nil
+}
+
+func scanProduct(row pgx.CollectableRow) (product, error) {
+	p := product{}
+	err := row.Scan(&p.ID, &p.ProductID, &p.Issued, &p.ReceivedAt, &p.Source, &p.WMO, &p.AWIPS, &p.BBB, &p.Text)
+	if err != nil {
+		return p, err
+	}
+
+	// Fixed width columns are padded
+	p.Source = strings.TrimSpace(p.Source)
+	p.WMO = strings.TrimSpace(p.WMO)
+	p.AWIPS = strings.TrimSpace(p.AWIPS)
+
+	return p, nil
+}
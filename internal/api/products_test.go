@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProductQuery(t *testing.T) {
+	values := url.Values{}
+	values.Set("awips", "afd")
+	values.Set("office", "koun")
+	values.Set("start", "2025-05-01")
+	values.Set("end", "2025-06-01T00:00:00Z")
+	values.Set("q", "EF-2")
+	values.Set("limit", "500")
+
+	query, err := parseProductQuery(values)
+	require.NoError(t, err)
+
+	assert.Equal(t, "AFD", query.AWIPS)
+	assert.Equal(t, "KOUN", query.Office)
+	assert.Equal(t, "EF-2", query.Search)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), *query.Start)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), *query.End)
+	assert.Equal(t, maxLimit, query.Limit)
+	assert.Equal(t, 0, query.Offset)
+
+	query, err = parseProductQuery(url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultLimit, query.Limit)
+
+	for _, invalid := range []url.Values{
+		{"limit": {"0"}},
+		{"offset": {"-1"}},
+		{"start": {"yesterday"}},
+		{"start": {"2025-06-01"}, "end": {"2025-05-01"}},
+		{"awips": {"AFDOUNX"}},
+	} {
+		_, err := parseProductQuery(invalid)
+		assert.Error(t, err, invalid.Encode())
+	}
+}
+
+func TestProductQueryBuild(t *testing.T) {
+	query := &productQuery{AWIPS: "AFD", Office: "KOUN", Limit: 5}
+
+	sql, args := query.build()
+	assert.Equal(t, "SELECT id, product_id, issued, received_at, source, wmo, awips, COALESCE(bbb, ''), data FROM awips.products"+
+		" WHERE awips LIKE ($1 || '%') AND source = $2 ORDER BY issued DESC, id DESC LIMIT $3 OFFSET $4", sql)
+	assert.Equal(t, []any{"AFD", "KOUN", 5, 0}, args)
+
+	query = &productQuery{AWIPS: "PNSOUN", Search: "EF-2", Limit: 25, Offset: 25}
+
+	sql, args = query.build()
+	assert.Contains(t, sql, "awips = $1")
+	assert.Contains(t, sql, "websearch_to_tsquery('english', $2)")
+	assert.Equal(t, []any{"PNSOUN", "EF-2", 25, 25}, args)
+}
+
+func TestProductIDTime(t *testing.T) {
+	issued, err := productIDTime("202505191215-KOAX-NWUS53-LSROAX")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 5, 19, 12, 15, 0, 0, time.UTC), issued)
+
+	_, err = productIDTime("KOAX-NWUS53-LSROAX")
+	assert.Error(t, err)
+}
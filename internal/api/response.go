@@ -0,0 +1,48 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Whether the client asked for plain text, either with the format parameter or the Accept header
+func wantsText(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "text":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/plain")
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Error().Err(err).Msg("failed to write json response")
+	}
+}
+
+func writeText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(text)); err != nil {
+		log.Error().Err(err).Msg("failed to write text response")
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if wantsText(r) {
+		writeText(w, status, message+"\n")
+		return
+	}
+	writeJSON(w, status, errorResponse{Error: message})
+}
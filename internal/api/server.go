@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultAddress = ":8080"
+
+type Server struct {
+	db *pgxpool.Pool
+}
+
+// Serve the HTTP query API until interrupted
+func Serve(logLevel zerolog.Level) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	zerolog.SetGlobalLevel(logLevel)
+
+	db, err := newDatabasePool(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialise database")
+		return
+	}
+	defer db.Close()
+
+	address := os.Getenv("API_ADDRESS")
+	if address == "" {
+		address = defaultAddress
+	}
+
+	server := &Server{db: db}
+
+	httpServer := &http.Server{
+		Addr:              address,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("address", address).Msg("serving api")
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("failed to serve api")
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	log.Warn().Msg("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shutdown api")
+	}
+}
+
+func (server *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /products", server.listProducts)
+	mux.HandleFunc("GET /products/{id}", server.getProduct)
+	mux.HandleFunc("GET /cap/warnings", server.listWarningsCAP)
+	mux.HandleFunc("GET /cap/warnings/{id}", server.getWarningCAP)
+
+	return mux
+}
+
+func newDatabasePool(url string) (*pgxpool.Pool, error) {
+	ctx := context.Background()
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pool.Ping(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// A warning as stored by the parse service in warnings.warnings. Like the live service, the API
+// keeps its own read-only view of the row rather than sharing the parse service's type, which is
+// built for writing and carries the storm motion columns the API does not serve.
+type warning struct {
+	ID            int        `json:"id"`
+	Phenomena     string     `json:"phenomena"`
+	Significance  string     `json:"significance"`
+	WFO           string     `json:"wfo"`
+	EventNumber   int        `json:"event_number"`
+	Year          int        `json:"year"`
+	Action        string     `json:"action"`
+	Current       bool       `json:"current"`
+	UpdatedAt     time.Time  `json:"updated_at,omitzero"`
+	Issued        time.Time  `json:"issued"`
+	Starts        *time.Time `json:"starts,omitzero"`
+	Expires       time.Time  `json:"expires"`
+	Ends          *time.Time `json:"ends,omitzero"`
+	Class         string     `json:"class"`
+	Title         string     `json:"title"`
+	IsEmergency   bool       `json:"is_emergency"`
+	IsPDS         bool       `json:"is_pds"`
+	Text          string     `json:"text"`
+	Product       string     `json:"product"`
+	Geom          []byte     `json:"geom"`
+	UGC           []string   `json:"ugc"`
+	Tornado       string     `json:"tornado,omitempty"`
+	Damage        string     `json:"damage,omitempty"`
+	HailThreat    string     `json:"hail_threat,omitempty"`
+	HailTag       string     `json:"hail_tag,omitempty"`
+	WindThreat    string     `json:"wind_threat,omitempty"`
+	WindTag       string     `json:"wind_tag,omitempty"`
+	FlashFlood    string     `json:"flash_flood,omitempty"`
+	RainfallTag   string     `json:"rainfall_tag,omitempty"`
+	FloodTagDam   string     `json:"flood_tag_dam,omitempty"`
+	SpoutTag      string     `json:"spout_tag,omitempty"`
+	SnowSquall    string     `json:"snow_squall,omitempty"`
+	SnowSquallTag string     `json:"snow_squall_tag,omitempty"`
+}
+
+// The ID used for the warning in live events, such as KOUN-SV-W-0001-2025-1
+func (warning *warning) CompositeID() string {
+	return fmt.Sprintf("%v-%v-%v-%04v-%v-%v", warning.WFO, warning.Phenomena, warning.Significance, warning.EventNumber, warning.Year, warning.ID)
+}
+
+const warningColumns = `id, phenomena, significance, wfo, event_number, year, action, current, updated_at, issued, starts,
+	expires, ends, class, title, is_emergency, is_pds, text, product, ST_AsBinary(geom), COALESCE(ugc, '{}'),
+	COALESCE(tornado, ''), COALESCE(damage, ''), COALESCE(hail_threat, ''), COALESCE(hail_tag, ''),
+	COALESCE(wind_threat, ''), COALESCE(wind_tag, ''), COALESCE(flash_flood, ''), COALESCE(rainfall_tag, ''),
+	COALESCE(flood_tag_dam, ''), COALESCE(spout_tag, ''), COALESCE(snow_squall, ''), COALESCE(snow_squall_tag, '')`
+
+func scanWarning(row pgx.CollectableRow) (warning, error) {
+	w := warning{}
+	err := row.Scan(&w.ID, &w.Phenomena, &w.Significance, &w.WFO, &w.EventNumber, &w.Year, &w.Action, &w.Current,
+		&w.UpdatedAt, &w.Issued, &w.Starts, &w.Expires, &w.Ends, &w.Class, &w.Title, &w.IsEmergency, &w.IsPDS,
+		&w.Text, &w.Product, &w.Geom, &w.UGC, &w.Tornado, &w.Damage, &w.HailThreat, &w.HailTag, &w.WindThreat,
+		&w.WindTag, &w.FlashFlood, &w.RainfallTag, &w.FloodTagDam, &w.SpoutTag, &w.SnowSquall, &w.SnowSquallTag)
+	if err != nil {
+		return w, err
+	}
+
+	// Fixed width columns are padded
+	w.WFO = strings.TrimSpace(w.WFO)
+	for i, ugc := range w.UGC {
+		w.UGC[i] = strings.TrimSpace(ugc)
+	}
+
+	return w, nil
+}
+
+// Warnings that are in effect, newest first
+func (server *Server) activeWarnings(ctx context.Context) ([]warning, error) {
+	rows, err := server.db.Query(ctx, `
+	SELECT `+warningColumns+` FROM warnings.warnings
+	WHERE current = true AND action NOT IN ('CAN', 'UPG', 'EXP') AND expires > CURRENT_TIMESTAMP
+	ORDER BY issued DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, scanWarning)
+}
+
+func (server *Server) findWarning(ctx context.Context, id int) (*warning, error) {
+	rows, err := server.db.Query(ctx, `SELECT `+warningColumns+` FROM warnings.warnings WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := pgx.CollectExactlyOneRow(rows, scanWarning)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// The database ID at the end of a composite ID. A plain database ID is also accepted.
+func warningIDFromComposite(id string) (int, error) {
+	n, err := strconv.Atoi(id[strings.LastIndex(id, "-")+1:])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid warning id %s", id)
+	}
+	return n, nil
+}
+
+// The base of links in CAP feeds, from CAP_BASE_URL or the request
+func capBaseURL(r *http.Request) string {
+	if base := os.Getenv("CAP_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// An ATOM index of active warnings linking to each as CAP
+func (server *Server) listWarningsCAP(w http.ResponseWriter, r *http.Request) {
+	warnings, err := server.activeWarnings(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query warnings")
+		writeError(w, r, http.StatusInternalServerError, "failed to query warnings")
+		return
+	}
+
+	feed := newCAPFeed(warnings, capBaseURL(r), time.Now())
+	writeXML(w, http.StatusOK, "application/atom+xml", feed)
+}
+
+// A warning as a CAP alert
+func (server *Server) getWarningCAP(w http.ResponseWriter, r *http.Request) {
+	id, err := warningIDFromComposite(r.PathValue("id"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	warning, err := server.findWarning(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, r, http.StatusNotFound, "warning not found")
+			return
+		}
+		log.Error().Err(err).Int("warning", id).Msg("failed to get warning")
+		writeError(w, r, http.StatusInternalServerError, "failed to get warning")
+		return
+	}
+
+	writeXML(w, http.StatusOK, "application/cap+xml", newCAPAlert(warning))
+}
@@ -0,0 +1,456 @@
+package awips
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAlertsURL      = "https://api.weather.gov/alerts/active"
+	defaultAlertsInterval = time.Minute
+	// Alerts usually appear after the product is on NWWS, so give it time to arrive first
+	defaultAlertsGrace     = 2 * time.Minute
+	defaultAlertsUserAgent = "(metdatasystem.us, ingest)"
+	alertsTimeout          = 30 * time.Second
+)
+
+type AlertsConfig struct {
+	URL       string
+	UserAgent string
+	Interval  time.Duration
+	Grace     time.Duration
+}
+
+// Read the alerts configuration from ALERTS_URL, ALERTS_USER_AGENT, ALERTS_INTERVAL and ALERTS_GRACE
+func alertsConfigFromEnv() (AlertsConfig, error) {
+	config := AlertsConfig{
+		URL:       os.Getenv("ALERTS_URL"),
+		UserAgent: os.Getenv("ALERTS_USER_AGENT"),
+		Interval:  defaultAlertsInterval,
+		Grace:     defaultAlertsGrace,
+	}
+	if config.URL == "" {
+		config.URL = defaultAlertsURL
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = defaultAlertsUserAgent
+	}
+
+	for name, d := range map[string]*time.Duration{"ALERTS_INTERVAL": &config.Interval, "ALERTS_GRACE": &config.Grace} {
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		var err error
+		*d, err = time.ParseDuration(s)
+		if err != nil || *d < 0 || (name == "ALERTS_INTERVAL" && *d == 0) {
+			return config, fmt.Errorf("invalid %s %s", name, s)
+		}
+	}
+
+	return config, nil
+}
+
+// Ingest alerts from a CAP ATOM or JSON-LD feed such as api.weather.gov. Alerts carrying VTEC are
+// sent to the parse service to check against, or fill in for, the products from NWWS.
+func Alerts(logLevel zerolog.Level) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	zerolog.SetGlobalLevel(logLevel)
+
+	config, err := alertsConfigFromEnv()
+	if err != nil {
+		log.Error().Err(err).Msg("alerts configuration is invalid")
+		return
+	}
+
+	producer, err := NewProducer()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create producer")
+		return
+	}
+
+	alerts := newAlertsClient(config, producer.messages, prometheus.DefaultRegisterer)
+	go func() {
+		alerts.run(ctx)
+		log.Warn().Msg("stopped polling alerts")
+		close(producer.messages)
+	}()
+
+	go producer.Run()
+
+	http.Handle("/metrics", promhttp.Handler())
+	go http.ListenAndServe(":2112", nil)
+
+	log.Info().Msg("prometheus metrics up")
+
+	<-ctx.Done()
+	log.Warn().Msg("shutting down")
+	producer.Stop()
+}
+
+// Polls the alerts feed, sending each alert once
+type alertsClient struct {
+	config   AlertsConfig
+	client   *http.Client
+	messages chan<- Message
+	// Alerts already sent or without VTEC, forgotten once they leave the feed
+	seen map[string]bool
+
+	polls    *prometheus.CounterVec
+	produced prometheus.Counter
+}
+
+func newAlertsClient(config AlertsConfig, messages chan<- Message, registerer prometheus.Registerer) *alertsClient {
+	factory := promauto.With(registerer)
+
+	return &alertsClient{
+		config:   config,
+		client:   &http.Client{Timeout: alertsTimeout},
+		messages: messages,
+		seen:     map[string]bool{},
+		polls: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "alerts_polls",
+			Help: "Total number of times the alerts feed was polled",
+		}, []string{"result"}),
+		produced: factory.NewCounter(prometheus.CounterOpts{
+			Name: "alerts_produced",
+			Help: "Total number of alerts produced",
+		}),
+	}
+}
+
+// Poll the feed until the context is done
+func (c *alertsClient) run(ctx context.Context) {
+	log.Info().Str("url", c.config.URL).Msg("polling alerts")
+
+	for {
+		if err := c.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Str("url", c.config.URL).Msg("failed to poll alerts")
+		}
+		if !sleep(ctx, c.config.Interval) {
+			return
+		}
+	}
+}
+
+// Fetch the feed and send the alerts that have been out for longer than the grace period
+func (c *alertsClient) poll(ctx context.Context) error {
+	alerts, err := c.fetch(ctx)
+	if err != nil {
+		c.polls.WithLabelValues("failure").Inc()
+		return err
+	}
+	c.polls.WithLabelValues("success").Inc()
+
+	current := map[string]bool{}
+	for _, alert := range alerts {
+		current[alert.ID] = true
+		if c.seen[alert.ID] {
+			continue
+		}
+		// Only alerts with VTEC can be matched to products
+		if len(alert.VTEC) == 0 {
+			c.seen[alert.ID] = true
+			continue
+		}
+		if time.Since(alert.Sent) < c.config.Grace {
+			continue
+		}
+
+		data, err := alert.Marshal()
+		if err != nil {
+			log.Error().Err(err).Str("alert", alert.ID).Msg("failed to marshal alert")
+			continue
+		}
+
+		select {
+		case c.messages <- Message{streaming.AlertContentType, data, "alerts"}:
+		case <-ctx.Done():
+			return nil
+		}
+		c.seen[alert.ID] = true
+		c.produced.Inc()
+		log.Debug().Str("alert", alert.ID).Strs("vtec", alert.VTEC).Msg("sent alert")
+	}
+
+	for id := range c.seen {
+		if !current[id] {
+			delete(c.seen, id)
+		}
+	}
+
+	return nil
+}
+
+// Request the feed and decode it by its content type
+func (c *alertsClient) fetch(ctx context.Context) ([]streaming.Alert, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+	req.Header.Set("Accept", "application/geo+json, application/ld+json, application/atom+xml")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if strings.HasSuffix(contentType, "xml") {
+		return parseAlertsATOM(body, c.config.URL)
+	}
+	return parseAlertsJSON(body, c.config.URL)
+}
+
+// The properties of an api.weather.gov alert, shared by the GeoJSON and JSON-LD formats
+type alertProperties struct {
+	ID          string    `json:"id"`
+	Sent        time.Time `json:"sent"`
+	Expires     time.Time `json:"expires"`
+	Event       string    `json:"event"`
+	Headline    string    `json:"headline"`
+	Description string    `json:"description"`
+	Instruction string    `json:"instruction"`
+	Geocode     struct {
+		UGC []string `json:"UGC"`
+	} `json:"geocode"`
+	Parameters struct {
+		VTEC            []string `json:"VTEC"`
+		AWIPSIdentifier []string `json:"AWIPSidentifier"`
+		WMOIdentifier   []string `json:"WMOidentifier"`
+	} `json:"parameters"`
+	// A WKT polygon in JSON-LD
+	Geometry json.RawMessage `json:"geometry"`
+}
+
+// Decode a GeoJSON feature collection or a JSON-LD graph
+func parseAlertsJSON(data []byte, source string) ([]streaming.Alert, error) {
+	feed := struct {
+		Features []struct {
+			Properties alertProperties `json:"properties"`
+			Geometry   *struct {
+				Type        string         `json:"type"`
+				Coordinates [][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+		Graph []alertProperties `json:"@graph"`
+	}{}
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to decode alerts: %w", err)
+	}
+
+	alerts := []streaming.Alert{}
+	for _, feature := range feed.Features {
+		alert := feature.Properties.alert(source)
+		if feature.Geometry != nil && feature.Geometry.Type == "Polygon" && len(feature.Geometry.Coordinates) > 0 {
+			alert.Polygon = feature.Geometry.Coordinates[0]
+		}
+		alerts = append(alerts, alert)
+	}
+	for _, properties := range feed.Graph {
+		alert := properties.alert(source)
+		wkt := ""
+		if json.Unmarshal(properties.Geometry, &wkt) == nil && wkt != "" {
+			polygon, err := parseWKTPolygon(wkt)
+			if err != nil {
+				log.Warn().Err(err).Str("alert", alert.ID).Msg("failed to parse alert geometry")
+			}
+			alert.Polygon = polygon
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+func (p *alertProperties) alert(source string) streaming.Alert {
+	return streaming.Alert{
+		ID:          p.ID,
+		Source:      source,
+		Sent:        p.Sent.UTC(),
+		Expires:     p.Expires.UTC(),
+		Event:       p.Event,
+		WMO:         first(p.Parameters.WMOIdentifier),
+		AWIPS:       first(p.Parameters.AWIPSIdentifier),
+		VTEC:        p.Parameters.VTEC,
+		UGC:         p.Geocode.UGC,
+		Headline:    p.Headline,
+		Description: p.Description,
+		Instruction: p.Instruction,
+	}
+}
+
+// Parse a WKT polygon such as POLYGON ((-93.63 41.63, -93.52 41.57, ...)), keeping the outer ring
+func parseWKTPolygon(wkt string) ([][2]float64, error) {
+	wkt = strings.TrimSpace(wkt)
+	if !strings.HasPrefix(strings.ToUpper(wkt), "POLYGON") {
+		return nil, fmt.Errorf("unsupported geometry %s", wkt)
+	}
+
+	ring := strings.Trim(strings.TrimSpace(wkt[len("POLYGON"):]), "()")
+	ring, _, _ = strings.Cut(ring, ")")
+
+	polygon := [][2]float64{}
+	for _, point := range strings.Split(ring, ",") {
+		fields := strings.Fields(point)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid point %q", point)
+		}
+		lon, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		lat, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		polygon = append(polygon, [2]float64{lon, lat})
+	}
+
+	return polygon, nil
+}
+
+// CAP name and value pairs, as in cap:parameter and cap:geocode
+type capValues struct {
+	Names  []string `xml:"valueName"`
+	Values []string `xml:"value"`
+}
+
+// The values with the name. Space separated values are split.
+func (v capValues) get(name string) []string {
+	values := []string{}
+	for i, n := range v.Names {
+		if n == name && i < len(v.Values) {
+			values = append(values, strings.Fields(v.Values[i])...)
+		}
+	}
+	return values
+}
+
+// Decode an ATOM feed of CAP entries
+func parseAlertsATOM(data []byte, source string) ([]streaming.Alert, error) {
+	feed := struct {
+		Entries []struct {
+			ID          string      `xml:"id"`
+			Summary     string      `xml:"summary"`
+			Event       string      `xml:"event"`
+			Sent        time.Time   `xml:"sent"`
+			Expires     time.Time   `xml:"expires"`
+			Headline    string      `xml:"headline"`
+			Description string      `xml:"description"`
+			Instruction string      `xml:"instruction"`
+			Polygon     string      `xml:"polygon"`
+			Geocode     []capValues `xml:"geocode"`
+			Parameters  []capValues `xml:"parameter"`
+		} `xml:"entry"`
+	}{}
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to decode alerts: %w", err)
+	}
+
+	alerts := []streaming.Alert{}
+	for _, entry := range feed.Entries {
+		geocode := capValues{}
+		for _, g := range entry.Geocode {
+			geocode.Names = append(geocode.Names, g.Names...)
+			geocode.Values = append(geocode.Values, g.Values...)
+		}
+		parameters := capValues{}
+		for _, p := range entry.Parameters {
+			parameters.Names = append(parameters.Names, p.Names...)
+			parameters.Values = append(parameters.Values, p.Values...)
+		}
+
+		description := entry.Description
+		if description == "" {
+			description = entry.Summary
+		}
+
+		alert := streaming.Alert{
+			ID:          entry.ID,
+			Source:      source,
+			Sent:        entry.Sent.UTC(),
+			Expires:     entry.Expires.UTC(),
+			Event:       entry.Event,
+			WMO:         strings.Join(parameters.get("WMOidentifier"), " "),
+			AWIPS:       first(parameters.get("AWIPSidentifier")),
+			VTEC:        parameters.get("VTEC"),
+			UGC:         geocode.get("UGC"),
+			Headline:    entry.Headline,
+			Description: description,
+			Instruction: entry.Instruction,
+		}
+
+		if entry.Polygon != "" {
+			polygon, err := parseCAPPolygon(entry.Polygon)
+			if err != nil {
+				log.Warn().Err(err).Str("alert", alert.ID).Msg("failed to parse alert polygon")
+			}
+			alert.Polygon = polygon
+		}
+
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+// Parse a CAP polygon of space separated latitude,longitude pairs
+func parseCAPPolygon(text string) ([][2]float64, error) {
+	polygon := [][2]float64{}
+	for _, point := range strings.Fields(text) {
+		latString, lonString, ok := strings.Cut(point, ",")
+		if !ok {
+			return nil, errors.New("invalid point " + point)
+		}
+		lat, err := strconv.ParseFloat(latString, 64)
+		if err != nil {
+			return nil, err
+		}
+		lon, err := strconv.ParseFloat(lonString, 64)
+		if err != nil {
+			return nil, err
+		}
+		polygon = append(polygon, [2]float64{lon, lat})
+	}
+	return polygon, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
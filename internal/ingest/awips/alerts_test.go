@@ -0,0 +1,212 @@
+package awips
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const alertsGeoJSON = `{
+	"type": "FeatureCollection",
+	"features": [{
+		"id": "https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.1",
+		"type": "Feature",
+		"geometry": {"type": "Polygon", "coordinates": [[[-93.63, 41.63], [-93.52, 41.57], [-93.70, 41.50], [-93.63, 41.63]]]},
+		"properties": {
+			"id": "urn:oid:2.49.0.1.840.0.1",
+			"geocode": {"SAME": ["019153"], "UGC": ["IAC153", "IAC169"]},
+			"sent": "%s",
+			"expires": "2025-05-19T18:45:00-05:00",
+			"event": "Tornado Warning",
+			"headline": "Tornado Warning issued May 19 at 6:10PM CDT",
+			"description": "At 610 PM CDT, a tornado was located near Ames.",
+			"instruction": null,
+			"parameters": {
+				"AWIPSidentifier": ["TORDMX"],
+				"WMOidentifier": ["WFUS53 KDMX 192310"],
+				"VTEC": ["/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/"]
+			}
+		}
+	}, {
+		"id": "https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.2",
+		"type": "Feature",
+		"geometry": null,
+		"properties": {
+			"id": "urn:oid:2.49.0.1.840.0.2",
+			"sent": "2025-05-19T18:00:00-05:00",
+			"event": "Special Weather Statement",
+			"parameters": {"AWIPSidentifier": ["SPSDMX"]}
+		}
+	}]
+}`
+
+const alertsJSONLD = `{
+	"@context": {"@version": "1.1"},
+	"@graph": [{
+		"id": "urn:oid:2.49.0.1.840.0.1",
+		"geometry": "POLYGON((-93.63 41.63,-93.52 41.57,-93.70 41.50,-93.63 41.63))",
+		"geocode": {"UGC": ["IAC153"]},
+		"sent": "2025-05-19T18:10:00-05:00",
+		"expires": "2025-05-19T18:45:00-05:00",
+		"event": "Tornado Warning",
+		"parameters": {"VTEC": ["/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/"]}
+	}]
+}`
+
+const alertsATOM = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:cap="urn:oasis:names:tc:emergency:cap:1.2">
+	<entry>
+		<id>https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.1</id>
+		<summary>At 610 PM CDT, a tornado was located near Ames.</summary>
+		<cap:event>Tornado Warning</cap:event>
+		<cap:sent>2025-05-19T18:10:00-05:00</cap:sent>
+		<cap:expires>2025-05-19T18:45:00-05:00</cap:expires>
+		<cap:polygon>41.63,-93.63 41.57,-93.52 41.50,-93.70 41.63,-93.63</cap:polygon>
+		<cap:geocode>
+			<valueName>FIPS6</valueName>
+			<value>019153 019169</value>
+			<valueName>UGC</valueName>
+			<value>IAC153 IAC169</value>
+		</cap:geocode>
+		<cap:parameter>
+			<valueName>VTEC</valueName>
+			<value>/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/</value>
+		</cap:parameter>
+		<cap:parameter>
+			<valueName>AWIPSidentifier</valueName>
+			<value>TORDMX</value>
+		</cap:parameter>
+		<cap:parameter>
+			<valueName>WMOidentifier</valueName>
+			<value>WFUS53 KDMX 192310</value>
+		</cap:parameter>
+	</entry>
+</feed>`
+
+func checkAlert(t *testing.T, alert streaming.Alert) {
+	t.Helper()
+
+	if alert.ID != "https://api.weather.gov/alerts/urn:oid:2.49.0.1.840.0.1" && alert.ID != "urn:oid:2.49.0.1.840.0.1" {
+		t.Errorf("unexpected id %s", alert.ID)
+	}
+	if len(alert.VTEC) != 1 || alert.VTEC[0] != "/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/" {
+		t.Errorf("unexpected vtec %v", alert.VTEC)
+	}
+	if len(alert.UGC) == 0 || alert.UGC[0] != "IAC153" {
+		t.Errorf("unexpected ugc %v", alert.UGC)
+	}
+	if !alert.Expires.Equal(time.Date(2025, 5, 19, 23, 45, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiry %s", alert.Expires)
+	}
+	if len(alert.Polygon) != 4 || alert.Polygon[1] != [2]float64{-93.52, 41.57} {
+		t.Errorf("unexpected polygon %v", alert.Polygon)
+	}
+}
+
+func TestParseAlerts(t *testing.T) {
+	alerts, err := parseAlertsJSON([]byte(fmt.Sprintf(alertsGeoJSON, "2025-05-19T18:10:00-05:00")), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(alerts))
+	}
+	checkAlert(t, alerts[0])
+	if alerts[0].AWIPS != "TORDMX" || alerts[0].WMO != "WFUS53 KDMX 192310" || alerts[0].Source != "test" {
+		t.Errorf("unexpected product %+v", alerts[0])
+	}
+
+	alerts, err = parseAlertsJSON([]byte(alertsJSONLD), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	checkAlert(t, alerts[0])
+
+	alerts, err = parseAlertsATOM([]byte(alertsATOM), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	checkAlert(t, alerts[0])
+	if alerts[0].AWIPS != "TORDMX" || alerts[0].WMO != "WFUS53 KDMX 192310" || alerts[0].Description == "" {
+		t.Errorf("unexpected product %+v", alerts[0])
+	}
+	if len(alerts[0].UGC) != 2 {
+		t.Errorf("expected only the UGC geocodes, got %v", alerts[0].UGC)
+	}
+}
+
+func TestAlertsPoll(t *testing.T) {
+	sent := time.Now().Add(-time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		fmt.Fprintf(w, alertsGeoJSON, sent.Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	messages := make(chan Message, 10)
+	config := AlertsConfig{URL: server.URL, UserAgent: "test", Interval: time.Minute, Grace: 2 * time.Minute}
+	c := newAlertsClient(config, messages, prometheus.NewRegistry())
+	ctx := context.Background()
+
+	// The alert is held back until the grace period has passed
+	if err := c.poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("expected the alert to be held back, got %d messages", len(messages))
+	}
+
+	c.config.Grace = 0
+	for range 2 {
+		if err := c.poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected the alert with vtec once, got %d messages", len(messages))
+	}
+
+	message := <-messages
+	if message.contentType != streaming.AlertContentType {
+		t.Errorf("unexpected content type %s", message.contentType)
+	}
+	alert := streaming.Alert{}
+	if err := alert.Unmarshal(message.data); err != nil {
+		t.Fatal(err)
+	}
+	if alert.AWIPS != "TORDMX" || alert.Source != server.URL {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+}
+
+func TestAlertsConfig(t *testing.T) {
+	t.Setenv("ALERTS_INTERVAL", "30s")
+	config, err := alertsConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.URL != defaultAlertsURL || config.Interval != 30*time.Second || config.Grace != defaultAlertsGrace {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	t.Setenv("ALERTS_GRACE", "soon")
+	if _, err := alertsConfigFromEnv(); err == nil {
+		t.Error("expected an invalid grace period to fail")
+	}
+}
@@ -0,0 +1,227 @@
+package awips
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+)
+
+const (
+	// Files are rotated every hour into a directory for each day
+	archiveDirLayout  = "2006/01/02"
+	archiveFileLayout = "2006010215"
+	archiveExtension  = ".ndjson.gz"
+	indexExtension    = ".index.ndjson"
+)
+
+// A product as it was received, one per line of an archive file
+type archiveRecord struct {
+	Received    time.Time `json:"received"`
+	ContentType string    `json:"content_type"`
+	// Which source the product arrived from
+	Source string `json:"source,omitempty"`
+	streaming.AWIPSRaw
+}
+
+// Points to a record in an archive file
+type archiveIndexEntry struct {
+	// The line of the uncompressed archive file, starting at 0
+	Line     int       `json:"line"`
+	Received time.Time `json:"received"`
+	Source   string    `json:"source,omitempty"`
+	Issued   time.Time `json:"issued,omitzero"`
+	TTAAII   string    `json:"ttaaii,omitempty"`
+	CCCC     string    `json:"cccc,omitempty"`
+	AWIPS    string    `json:"awips,omitempty"`
+	SHA256   string    `json:"sha256"`
+}
+
+// Writes every product to hourly gzipped NDJSON files with an index alongside each
+type archive struct {
+	dir string
+
+	hour  time.Time
+	file  *os.File
+	gzip  *gzip.Writer
+	index *os.File
+	lines int
+}
+
+// Configure the archive from INGEST_ARCHIVE_DIR. Returns nil if it is not set.
+func newArchive() *archive {
+	dir := os.Getenv("INGEST_ARCHIVE_DIR")
+	if dir == "" {
+		return nil
+	}
+	return &archive{dir: dir}
+}
+
+// The archive and index paths for the hour. Each process writes its own part of the hour so a
+// file is never appended to after another process has stopped writing it.
+func (a *archive) paths(hour time.Time, part int) (string, string) {
+	name := "awips-" + hour.Format(archiveFileLayout)
+	if part > 0 {
+		name += fmt.Sprintf("-%d", part)
+	}
+	base := filepath.Join(a.dir, hour.Format(archiveDirLayout), name)
+	return base + archiveExtension, base + indexExtension
+}
+
+// Append the message to the file for the hour it was received
+func (a *archive) write(message Message, received time.Time) error {
+	received = received.UTC()
+	if err := a.rotate(received.Truncate(time.Hour)); err != nil {
+		return err
+	}
+
+	record := archiveRecord{
+		Received:    received,
+		ContentType: message.contentType,
+		Source:      message.source,
+	}
+	if message.contentType == "application/json" {
+		if err := record.AWIPSRaw.Unmarshal(message.data); err != nil {
+			return fmt.Errorf("failed to unmarshal awips raw: %w", err)
+		}
+	} else {
+		record.Text = string(message.data)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive record: %w", err)
+	}
+	if _, err := a.gzip.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	// Flush so a crash loses as little as possible
+	if err := a.gzip.Flush(); err != nil {
+		return fmt.Errorf("failed to flush archive: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(record.Text))
+	entry, err := json.Marshal(archiveIndexEntry{
+		Line:     a.lines,
+		Received: received,
+		Source:   record.Source,
+		Issued:   record.Issued,
+		TTAAII:   record.TTAAII,
+		CCCC:     record.CCCC,
+		AWIPS:    record.AWIPS,
+		SHA256:   hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal index entry: %w", err)
+	}
+	if _, err := a.index.Write(append(entry, '\n')); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	a.lines++
+
+	return nil
+}
+
+// Switch to new files for the hour. Files already there, such as from before a restart, are left
+// as they are and the next part is used.
+func (a *archive) rotate(hour time.Time) error {
+	if a.file != nil && a.hour.Equal(hour) {
+		return nil
+	}
+	if err := a.close(); err != nil {
+		return err
+	}
+
+	archivePath, _ := a.paths(hour, 0)
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	var file *os.File
+	var indexPath string
+	for part := 0; ; part++ {
+		archivePath, indexPath = a.paths(hour, part)
+		var err error
+		file, err = os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		break
+	}
+	index, err := os.OpenFile(indexPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open index: %w", err)
+	}
+
+	a.hour = hour
+	a.file = file
+	a.gzip = gzip.NewWriter(file)
+	a.index = index
+	a.lines = 0
+
+	return nil
+}
+
+func (a *archive) close() error {
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.gzip.Close()
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := a.index.Close(); err == nil {
+		err = cerr
+	}
+	a.file = nil
+	a.gzip = nil
+	a.index = nil
+
+	if err != nil {
+		return fmt.Errorf("failed to close archive: %w", err)
+	}
+	return nil
+}
+
+// Read every record in an archive file
+func readArchive(path string) ([]archiveRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	records := []archiveRecord{}
+	decoder := json.NewDecoder(reader)
+	for {
+		record := archiveRecord{}
+		err := decoder.Decode(&record)
+		// A writer that was killed leaves the file without the end of the gzip stream
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("failed to decode record %d: %w", len(records), err)
+		}
+		records = append(records, record)
+	}
+}
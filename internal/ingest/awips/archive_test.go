@@ -0,0 +1,136 @@
+package awips
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	a := &archive{dir: dir}
+
+	raw := testProduct.raw(time.Now())
+	data, err := raw.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := time.Date(2025, 5, 19, 23, 5, 30, 0, time.UTC)
+	if err := a.write(Message{"application/json", data, "test"}, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.write(Message{"text/plain", []byte("WWUS83 KDMX 192310\nSPSDMX\n"), "test"}, received.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// The next hour rotates to a new file
+	if err := a.write(Message{"application/json", data, "test"}, received.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restarting writes another part of the hour
+	a = &archive{dir: dir}
+	if err := a.write(Message{"application/json", data, "test"}, received.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath, indexPath := a.paths(received.Truncate(time.Hour), 0)
+	if archivePath != filepath.Join(dir, "2025/05/19/awips-2025051923.ndjson.gz") {
+		t.Errorf("unexpected archive path %s", archivePath)
+	}
+
+	records, err := readArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records in the first part of the hour, got %d", len(records))
+	}
+	if records[0].AWIPS != "TOROAX" || records[0].Text != raw.Text || !records[0].Received.Equal(received) || records[0].Source != "test" {
+		t.Errorf("unexpected first record %+v", records[0])
+	}
+	if records[1].ContentType != "text/plain" || records[1].Text != "WWUS83 KDMX 192310\nSPSDMX\n" {
+		t.Errorf("unexpected text record %+v", records[1])
+	}
+
+	file, err := os.Open(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines := []int{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := archiveIndexEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, entry.Line)
+	}
+	if len(lines) != 2 || lines[0] != 0 || lines[1] != 1 {
+		t.Errorf("expected index lines 0 and 1, got %v", lines)
+	}
+
+	part, _ := a.paths(received.Truncate(time.Hour), 1)
+	if part != filepath.Join(dir, "2025/05/19/awips-2025051923-1.ndjson.gz") {
+		t.Errorf("unexpected archive part path %s", part)
+	}
+	if records, err := readArchive(part); err != nil || len(records) != 1 {
+		t.Errorf("expected 1 record in the second part of the hour, got %d %v", len(records), err)
+	}
+
+	next, _ := a.paths(received.Add(time.Hour).Truncate(time.Hour), 0)
+	if records, err := readArchive(next); err != nil || len(records) != 1 {
+		t.Errorf("expected 1 record in the next hour, got %d %v", len(records), err)
+	}
+}
+
+func TestArchiveAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	received := time.Date(2025, 5, 19, 23, 5, 30, 0, time.UTC)
+	hour := received.Truncate(time.Hour)
+
+	// A writer that is killed has flushed its records but never ends the gzip stream
+	crashed := &archive{dir: dir}
+	if err := crashed.write(Message{"text/plain", []byte("first"), "test"}, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := crashed.write(Message{"text/plain", []byte("second"), "test"}, received); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &archive{dir: dir}
+	if err := a.write(Message{"text/plain", []byte("third"), "test"}, received.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := a.paths(hour, 0)
+	records, err := readArchive(first)
+	if err != nil || len(records) != 2 || records[1].Text != "second" {
+		t.Errorf("expected the crashed part to keep 2 records, got %v %v", records, err)
+	}
+
+	second, _ := a.paths(hour, 1)
+	records, err = readArchive(second)
+	if err != nil || len(records) != 1 || records[0].Text != "third" {
+		t.Errorf("expected the restarted part to have 1 record, got %v %v", records, err)
+	}
+
+	products, err := loadReplay(dir)
+	if err != nil || len(products) != 3 {
+		t.Errorf("expected 3 products to replay, got %d %v", len(products), err)
+	}
+}
@@ -0,0 +1,29 @@
+package awips
+
+import "time"
+
+// Exponential backoff between reconnection attempts
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newBackoff(min time.Duration, max time.Duration) *backoff {
+	return &backoff{min: min, max: max}
+}
+
+// The duration to wait before the next attempt, doubling each time up to the maximum
+func (b *backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	} else {
+		b.current = min(b.current*2, b.max)
+	}
+	return b.current
+}
+
+// Start again from the minimum after a successful attempt
+func (b *backoff) Reset() {
+	b.current = 0
+}
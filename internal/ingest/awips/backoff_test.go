@@ -0,0 +1,22 @@
+package awips
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Second, 5*time.Second)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if d := b.Next(); d != e {
+			t.Errorf("attempt %d: expected %s, got %s", i, e, d)
+		}
+	}
+
+	b.Reset()
+	if d := b.Next(); d != time.Second {
+		t.Errorf("expected %s after reset, got %s", time.Second, d)
+	}
+}
@@ -0,0 +1,183 @@
+package awips
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultDedupWindow = 10 * time.Minute
+	dedupKeyPrefix     = "us:ingest:awips:"
+	dedupStoreTimeout  = 2 * time.Second
+)
+
+// Records which products have been sent
+type dedupStore interface {
+	// Mark the key as seen for the window, returning true if it was already seen
+	seen(ctx context.Context, key string, window time.Duration) (bool, error)
+}
+
+// Drops products already sent by any ingest source within the window
+type dedup struct {
+	store      dedupStore
+	window     time.Duration
+	duplicates prometheus.Counter
+}
+
+// Configure de-duplication from the environment. Products are remembered in memory unless
+// INGEST_DEDUP_REDIS is set, which lets several ingest processes share what they have sent.
+// A window of 0 disables de-duplication.
+func newDedup() (*dedup, error) {
+	window := defaultDedupWindow
+	if s := os.Getenv("INGEST_DEDUP_WINDOW"); s != "" {
+		var err error
+		window, err = time.ParseDuration(s)
+		if err != nil || window < 0 {
+			return nil, fmt.Errorf("invalid INGEST_DEDUP_WINDOW %s", s)
+		}
+	}
+	if window == 0 {
+		return nil, nil
+	}
+
+	var store dedupStore = newMemoryStore()
+	if address := os.Getenv("INGEST_DEDUP_REDIS"); address != "" {
+		redis, err := newRedisStore(address)
+		if err != nil {
+			return nil, err
+		}
+		store = redis
+	}
+
+	return &dedup{
+		store:  store,
+		window: window,
+		duplicates: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "ingest_duplicates",
+			Help: "Total number of products dropped because another source already sent them",
+		}),
+	}, nil
+}
+
+// Whether the message has already been sent. Messages are let through if the store fails.
+func (d *dedup) duplicate(message Message) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), dedupStoreTimeout)
+	defer cancel()
+
+	key := dedupKey(message)
+	seen, err := d.store.seen(ctx, key, d.window)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check for duplicate product")
+		return false
+	}
+	if seen {
+		log.Debug().Str("key", key).Msg("dropping duplicate product")
+		d.duplicates.Inc()
+	}
+
+	return seen
+}
+
+// Key a message on its WMO header, AWIPS ID, issue time and a hash of the text.
+// Plain text carries its header in the text so is keyed on the hash alone.
+func dedupKey(message Message) string {
+	if message.contentType == "application/json" {
+		raw := streaming.AWIPSRaw{}
+		if err := raw.Unmarshal(message.data); err == nil {
+			return fmt.Sprintf("%s%s:%s:%s:%s:%s", dedupKeyPrefix, raw.TTAAII, raw.CCCC, raw.AWIPS,
+				raw.Issued.UTC().Format("200601021504"), hashText(raw.Text))
+		}
+	}
+
+	return dedupKeyPrefix + hashText(string(message.data))
+}
+
+// Hash the text ignoring line endings, the NOAAPort sequence number and surrounding whitespace,
+// which differ between feeds
+func hashText(text string) string {
+	text = strings.ReplaceAll(text, "\r", "")
+	_, text = noaaportSequence(text)
+	text = strings.TrimSpace(text)
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Remembers keys in this process
+type memoryStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	// Expired keys are removed every so often rather than on every call
+	nextPrune time.Time
+	now       func() time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		expires: map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+func (store *memoryStore) seen(ctx context.Context, key string, window time.Duration) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	if now.After(store.nextPrune) {
+		for k, expires := range store.expires {
+			if !now.Before(expires) {
+				delete(store.expires, k)
+			}
+		}
+		store.nextPrune = now.Add(window)
+	}
+
+	if expires, ok := store.expires[key]; ok && now.Before(expires) {
+		return true, nil
+	}
+	store.expires[key] = now.Add(window)
+
+	return false, nil
+}
+
+// Remembers keys in Redis, or anything speaking its protocol, using SET NX with an expiry
+type redisStore struct {
+	client *redis.Client
+}
+
+// The address is either host:port or a redis:// URL with an optional password and database
+func newRedisStore(address string) (*redisStore, error) {
+	options := &redis.Options{Addr: address}
+
+	if strings.Contains(address, "://") {
+		var err error
+		options, err = redis.ParseURL(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INGEST_DEDUP_REDIS %s: %w", address, err)
+		}
+	}
+
+	return &redisStore{client: redis.NewClient(options)}, nil
+}
+
+func (store *redisStore) seen(ctx context.Context, key string, window time.Duration) (bool, error) {
+	set, err := store.client.SetNX(ctx, key, 1, window).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set dedup key: %w", err)
+	}
+
+	// The key is only set when it did not already exist
+	return !set, nil
+}
@@ -0,0 +1,164 @@
+package awips
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func testMessage(t *testing.T, raw streaming.AWIPSRaw) Message {
+	t.Helper()
+
+	data, err := raw.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Message{"application/json", data, "test"}
+}
+
+func TestDedupKey(t *testing.T) {
+	raw := testProduct.raw(time.Now())
+	key := dedupKey(testMessage(t, raw))
+
+	// Another feed may have different line endings
+	other := raw
+	other.Text = strings.ReplaceAll(raw.Text, "\n", "\r\n") + "\n"
+	if k := dedupKey(testMessage(t, other)); k != key {
+		t.Errorf("expected the same key for the same product, got %s and %s", key, k)
+	}
+
+	// NWWS keeps the NOAAPort sequence number which LDM splits off
+	ldm := raw
+	_, ldm.Text = noaaportSequence(raw.Text)
+	if k := dedupKey(testMessage(t, ldm)); k != key || ldm.Text == raw.Text {
+		t.Errorf("expected the same key without the sequence number, got %s and %s", key, k)
+	}
+
+	// A correction has the same header but different text
+	corrected := raw
+	corrected.Text = raw.Text + "CORRECTED\n"
+	if k := dedupKey(testMessage(t, corrected)); k == key {
+		t.Error("expected a different key for different text")
+	}
+
+	reissued := raw
+	reissued.Issued = raw.Issued.Add(time.Minute)
+	if k := dedupKey(testMessage(t, reissued)); k == key {
+		t.Error("expected a different key for a different issue time")
+	}
+
+	text := Message{"text/plain", []byte(raw.Text), "test"}
+	if k := dedupKey(text); !strings.HasPrefix(k, dedupKeyPrefix) || k == key {
+		t.Errorf("unexpected key for plain text %s", k)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2025, 5, 19, 23, 5, 0, 0, time.UTC)
+	store := newMemoryStore()
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	check := func(key string, expected bool) {
+		t.Helper()
+		seen, err := store.seen(ctx, key, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != expected {
+			t.Errorf("expected seen %v for %s at %s, got %v", expected, key, now.Format(time.TimeOnly), seen)
+		}
+	}
+
+	check("a", false)
+	check("a", true)
+	check("b", false)
+
+	now = now.Add(2 * time.Minute)
+	check("a", false)
+	if len(store.expires) != 1 {
+		t.Errorf("expected expired keys to be pruned, got %d keys", len(store.expires))
+	}
+}
+
+func TestDedupDuplicate(t *testing.T) {
+	d := &dedup{
+		store:      newMemoryStore(),
+		window:     time.Minute,
+		duplicates: prometheus.NewCounter(prometheus.CounterOpts{Name: "test_duplicates"}),
+	}
+
+	message := testMessage(t, testProduct.raw(time.Now()))
+	if d.duplicate(message) {
+		t.Error("expected the first message to be sent")
+	}
+	if !d.duplicate(message) {
+		t.Error("expected the second message to be dropped")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	server.Select(2)
+
+	store, err := newRedisStore(fmt.Sprintf("redis://:secret@%s/2", server.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i, expected := range []bool{false, true} {
+		seen, err := store.seen(ctx, "key", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != expected {
+			t.Errorf("call %d: expected seen %v, got %v", i, expected, seen)
+		}
+	}
+
+	if ttl := server.TTL("key"); ttl != time.Minute {
+		t.Errorf("expected the key to expire after the window, got %v", ttl)
+	}
+
+	// The key is forgotten once the window has passed
+	server.FastForward(time.Minute)
+	seen, err := store.seen(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen {
+		t.Error("expected the key to have expired")
+	}
+}
+
+func TestNewRedisStore(t *testing.T) {
+	store, err := newRedisStore("redis://cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := store.client.Options().Addr; address != "cache:6379" {
+		t.Errorf("expected the default port, got %s", address)
+	}
+
+	store, err = newRedisStore("cache:6380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := store.client.Options().Addr; address != "cache:6380" {
+		t.Errorf("expected the address as it was given, got %s", address)
+	}
+
+	if _, err := newRedisStore("redis://cache/db"); err == nil {
+		t.Error("expected an invalid database to fail")
+	}
+}
@@ -6,24 +6,46 @@ import (
 )
 
 type Health struct {
-	NWWSReceived prometheus.Counter
-	NWWSProduced prometheus.Counter
-	NWWSPing     prometheus.Gauge
+	NWWSReceived    prometheus.Counter
+	NWWSProduced    prometheus.Counter
+	NWWSPing        prometheus.Gauge
+	NWWSConnected   prometheus.Gauge
+	NWWSReconnects  prometheus.Counter
+	NWWSLastProduct prometheus.Gauge
+	NWWSGapSeconds  prometheus.Counter
 }
 
-func NewHealth() *Health {
+func newHealth(registerer prometheus.Registerer) *Health {
+	factory := promauto.With(registerer)
+
 	return &Health{
-		NWWSReceived: promauto.NewCounter(prometheus.CounterOpts{
+		NWWSReceived: factory.NewCounter(prometheus.CounterOpts{
 			Name: "nwws_received",
 			Help: "Total number of NWWS messages received",
 		}),
-		NWWSProduced: promauto.NewCounter(prometheus.CounterOpts{
+		NWWSProduced: factory.NewCounter(prometheus.CounterOpts{
 			Name: "nwws_produced",
 			Help: "Total number of NWWS messages produced",
 		}),
-		NWWSPing: promauto.NewGauge(prometheus.GaugeOpts{
+		NWWSPing: factory.NewGauge(prometheus.GaugeOpts{
 			Name: "nwws_ping",
 			Help: "Indicates the NWWS ping status",
 		}),
+		NWWSConnected: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "nwws_connected",
+			Help: "Indicates if the NWWS session is connected and joined to the room",
+		}),
+		NWWSReconnects: factory.NewCounter(prometheus.CounterOpts{
+			Name: "nwws_reconnects",
+			Help: "Total number of times the NWWS session was lost and reconnected",
+		}),
+		NWWSLastProduct: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "nwws_last_product_timestamp_seconds",
+			Help: "Unix time of the last product received from NWWS",
+		}),
+		NWWSGapSeconds: factory.NewCounter(prometheus.CounterOpts{
+			Name: "nwws_gap_seconds",
+			Help: "Total seconds of the NWWS feed missed while disconnected",
+		}),
 	}
 }
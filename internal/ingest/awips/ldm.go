@@ -0,0 +1,323 @@
+package awips
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// NOAAPort wraps each product between these
+	noaaportSOH = 0x01
+	noaaportETX = 0x03
+)
+
+// Ingest products that an LDM writes to files in the directory, such as with a pqact FILE action.
+// The directory is polled at the interval and only products written after starting are sent.
+func LDM(dir string, interval time.Duration, logLevel zerolog.Level) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	zerolog.SetGlobalLevel(logLevel)
+
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		log.Error().Err(err).Str("dir", dir).Msg("LDM path must be a directory")
+		return
+	}
+
+	producer, err := NewProducer()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create producer")
+		return
+	}
+
+	watcher := newLDMWatcher(dir, interval, producer.messages, prometheus.DefaultRegisterer)
+	go func() {
+		watcher.run(ctx)
+		log.Warn().Msg("stopped watching LDM directory")
+		close(producer.messages)
+	}()
+
+	go producer.Run()
+
+	http.Handle("/metrics", promhttp.Handler())
+	go http.ListenAndServe(":2112", nil)
+
+	log.Info().Msg("prometheus metrics up")
+
+	<-ctx.Done()
+	log.Warn().Msg("shutting down")
+	producer.Stop()
+}
+
+// Follows every file in a directory tree, sending products as they are written
+type ldmWatcher struct {
+	dir      string
+	interval time.Duration
+	messages chan<- Message
+	files    map[string]*ldmFile
+
+	received prometheus.Counter
+	produced prometheus.Counter
+}
+
+// How much of a file has been read
+type ldmFile struct {
+	// Bytes already sent or skipped
+	offset int64
+	// The size and modification time at the last poll, to tell when the file has stopped changing
+	size    int64
+	modTime time.Time
+	// Whether the file holds NOAAPort framed products
+	framed bool
+}
+
+func newLDMWatcher(dir string, interval time.Duration, messages chan<- Message, registerer prometheus.Registerer) *ldmWatcher {
+	factory := promauto.With(registerer)
+
+	return &ldmWatcher{
+		dir:      dir,
+		interval: interval,
+		messages: messages,
+		files:    map[string]*ldmFile{},
+		received: factory.NewCounter(prometheus.CounterOpts{
+			Name: "ldm_received",
+			Help: "Total number of products read from LDM files",
+		}),
+		produced: factory.NewCounter(prometheus.CounterOpts{
+			Name: "ldm_produced",
+			Help: "Total number of LDM products produced",
+		}),
+	}
+}
+
+// Poll the directory until the context is done
+func (w *ldmWatcher) run(ctx context.Context) {
+	// Products already on disk were written before we started
+	w.scan(ctx, true)
+	log.Info().Str("dir", w.dir).Int("files", len(w.files)).Msg("watching LDM directory")
+
+	for sleep(ctx, w.interval) {
+		w.scan(ctx, false)
+	}
+}
+
+// Read whatever has been written to each file since the last poll. The first scan only records
+// where each file ends.
+func (w *ldmWatcher) scan(ctx context.Context, initial bool) {
+	found := map[string]bool{}
+
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("failed to read LDM path")
+			return nil
+		}
+		// Skip temporary and hidden files
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		found[path] = true
+
+		file, ok := w.files[path]
+		if !ok {
+			file = &ldmFile{}
+			w.files[path] = file
+			if initial {
+				file.offset = info.Size()
+				file.size = info.Size()
+				file.modTime = info.ModTime()
+				return nil
+			}
+		}
+
+		w.follow(ctx, path, file, info)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Error().Err(err).Str("dir", w.dir).Msg("failed to scan LDM directory")
+	}
+
+	// Forget files that have been removed
+	for path := range w.files {
+		if !found[path] {
+			delete(w.files, path)
+		}
+	}
+}
+
+// Send the products written to the file since it was last read
+func (w *ldmWatcher) follow(ctx context.Context, path string, file *ldmFile, info fs.FileInfo) {
+	size := info.Size()
+	stable := size == file.size && info.ModTime().Equal(file.modTime)
+	file.size = size
+	file.modTime = info.ModTime()
+
+	// The file was truncated or replaced
+	if size < file.offset {
+		file.offset = 0
+		file.framed = false
+	}
+	if size == file.offset {
+		return
+	}
+
+	data, err := readRange(path, file.offset, size)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to read LDM file")
+		return
+	}
+
+	products, consumed := splitNOAAPort(data)
+	if len(products) > 0 || bytes.IndexByte(data, noaaportSOH) >= 0 {
+		file.framed = true
+	}
+
+	// Whatever is left over is either a product still being written or, once the file stops
+	// changing, a product without framing or trailing bytes after the last frame
+	if stable && consumed < len(data) {
+		rest := data[consumed:]
+		if !file.framed {
+			products = append(products, rest)
+		} else if len(bytes.TrimSpace(rest)) > 0 {
+			log.Warn().Str("path", path).Int("bytes", len(rest)).Msg("skipping incomplete NOAAPort product")
+		}
+		consumed = len(data)
+	}
+	file.offset += int64(consumed)
+
+	for _, product := range products {
+		w.produce(ctx, path, product)
+	}
+}
+
+// Share a product read from a file
+func (w *ldmWatcher) produce(ctx context.Context, path string, product []byte) {
+	now := time.Now()
+	w.received.Inc()
+
+	raw, sequence, err := ldmRaw(product, now)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("skipping LDM product")
+		return
+	}
+	log.Debug().Str("path", path).Str("sequence", sequence).Str("awips", raw.AWIPS).Msg("received LDM product")
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal awips raw message")
+		return
+	}
+
+	select {
+	case w.messages <- Message{"application/json", data, "ldm"}:
+		w.produced.Inc()
+	case <-ctx.Done():
+	}
+}
+
+// Read the bytes of the file from start to end
+func readRange(path string, start int64, end int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data := make([]byte, end-start)
+	n, err := file.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// Split complete SOH ... ETX frames from the data. Returns the products inside the frames and how
+// many bytes were used, which stops at the start of an unfinished frame.
+func splitNOAAPort(data []byte) ([][]byte, int) {
+	products := [][]byte{}
+	consumed := 0
+
+	for {
+		start := bytes.IndexByte(data[consumed:], noaaportSOH)
+		if start < 0 {
+			return products, consumed
+		}
+		start += consumed
+
+		end := bytes.IndexByte(data[start:], noaaportETX)
+		if end < 0 {
+			return products, start
+		}
+		end += start
+
+		products = append(products, data[start+1:end])
+		consumed = end + 1
+	}
+}
+
+// Convert a product to the raw message shared with the parse service. Returns the NOAAPort
+// sequence number separately so the text starts at the WMO heading.
+func ldmRaw(product []byte, received time.Time) (streaming.AWIPSRaw, string, error) {
+	// NOAAPort lines end with CRCRLF. Blank lines are part of the product so are kept.
+	text := strings.ReplaceAll(string(product), "\r\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	sequence, text := noaaportSequence(text)
+
+	wmo, err := awips.ParseWMO(text)
+	if err != nil {
+		return streaming.AWIPSRaw{}, "", err
+	}
+
+	// The AWIPS identifier, when there is one, is the line after the WMO heading
+	after := text[strings.Index(text, wmo.Original)+len(wmo.Original):]
+	line, _, _ := strings.Cut(strings.TrimPrefix(after, "\n"), "\n")
+
+	return streaming.AWIPSRaw{
+		Issued: awips.MergeDayTime(received, wmo.Issued),
+		TTAAII: wmo.Datatype,
+		CCCC:   wmo.Office,
+		AWIPS:  awips.FindAWIPS(line + "\n"),
+		Text:   text,
+	}, sequence, nil
+}
+
+// Split off the sequence number NOAAPort puts on the line before the WMO heading. Returns an
+// empty sequence and the text unchanged when there is none.
+func noaaportSequence(text string) (string, string) {
+	line, rest, _ := strings.Cut(strings.TrimLeft(text, "\n"), "\n")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", text
+	}
+	for _, r := range line {
+		if r < '0' || r > '9' {
+			return "", text
+		}
+	}
+	return line, rest
+}
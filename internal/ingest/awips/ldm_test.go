@@ -0,0 +1,126 @@
+package awips
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A tornado warning as pqact writes it from NOAAPort
+const noaaportProduct = "\x01\r\r\n123 \r\r\nWFUS53 KDMX 192310\r\r\nTORDMX\r\r\n\r\r\nBULLETIN - EAS ACTIVATION REQUESTED\r\r\nTornado Warning\r\r\n\x03"
+
+func TestSplitNOAAPort(t *testing.T) {
+	data := []byte(noaaportProduct + "\n" + noaaportProduct + "\x01\r\r\n124 \r\r\nWFUS53")
+
+	products, consumed := splitNOAAPort(data)
+	if len(products) != 2 {
+		t.Fatalf("expected 2 complete products, got %d", len(products))
+	}
+	if consumed != 2*len(noaaportProduct)+1 {
+		t.Errorf("expected to stop at the unfinished product, consumed %d", consumed)
+	}
+	if string(products[0]) != noaaportProduct[1:len(noaaportProduct)-1] {
+		t.Errorf("unexpected product %q", products[0])
+	}
+}
+
+func TestLDMRaw(t *testing.T) {
+	received := time.Date(2025, 5, 19, 23, 10, 30, 0, time.UTC)
+	products, _ := splitNOAAPort([]byte(noaaportProduct))
+
+	raw, sequence, err := ldmRaw(products[0], received)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw.TTAAII != "WFUS53" || raw.CCCC != "KDMX" || raw.AWIPS != "TORDMX" {
+		t.Errorf("unexpected product attributes %+v", raw)
+	}
+	if !raw.Issued.Equal(time.Date(2025, 5, 19, 23, 10, 0, 0, time.UTC)) {
+		t.Errorf("expected issued 2025-05-19T23:10:00Z, got %s", raw.Issued)
+	}
+	if raw.Text != "WFUS53 KDMX 192310\nTORDMX\n\nBULLETIN - EAS ACTIVATION REQUESTED\nTornado Warning\n" {
+		t.Errorf("unexpected text %q", raw.Text)
+	}
+	if sequence != "123" {
+		t.Errorf("expected sequence 123, got %q", sequence)
+	}
+
+	if _, _, err := ldmRaw([]byte("no heading here"), received); err == nil {
+		t.Error("expected a product without a WMO heading to fail")
+	}
+}
+
+func TestLDMWatcher(t *testing.T) {
+	dir := t.TempDir()
+	messages := make(chan Message, 10)
+	w := newLDMWatcher(dir, time.Millisecond, messages, prometheus.NewRegistry())
+	ctx := context.Background()
+
+	write := func(name string, data string) {
+		file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func() []string {
+		awips := []string{}
+		for {
+			select {
+			case message := <-messages:
+				raw := streaming.AWIPSRaw{}
+				if err := raw.Unmarshal(message.data); err != nil {
+					t.Fatal(err)
+				}
+				awips = append(awips, raw.AWIPS)
+			default:
+				return awips
+			}
+		}
+	}
+
+	// Products written before starting are not sent
+	write("WFUS53.txt", noaaportProduct)
+	w.scan(ctx, true)
+	if received := receive(); len(received) != 0 {
+		t.Errorf("expected nothing from existing files, got %v", received)
+	}
+
+	// Products appended to a file are sent once their frame is complete
+	write("WFUS53.txt", noaaportProduct[:20])
+	w.scan(ctx, false)
+	if received := receive(); len(received) != 0 {
+		t.Errorf("expected nothing from an unfinished product, got %v", received)
+	}
+	write("WFUS53.txt", noaaportProduct[20:])
+	w.scan(ctx, false)
+	if received := receive(); len(received) != 1 || received[0] != "TORDMX" {
+		t.Errorf("expected TORDMX, got %v", received)
+	}
+
+	// Files without framing are sent once they stop changing
+	write("SPSDMX.txt", "WWUS83 KDMX 192320\r\r\nSPSDMX\r\r\n\r\r\nSpecial Weather Statement\r\r\n")
+	w.scan(ctx, false)
+	if received := receive(); len(received) != 0 {
+		t.Errorf("expected to wait for the file to stop changing, got %v", received)
+	}
+	w.scan(ctx, false)
+	if received := receive(); len(received) != 1 || received[0] != "SPSDMX" {
+		t.Errorf("expected SPSDMX, got %v", received)
+	}
+
+	// Nothing is sent twice
+	w.scan(ctx, false)
+	if received := receive(); len(received) != 0 {
+		t.Errorf("expected nothing new, got %v", received)
+	}
+}
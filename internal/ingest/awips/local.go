@@ -21,7 +21,8 @@ func Local(path string, loglevel zerolog.Level) {
 
 	process(path)
 
-	producer.Stop()
+	// Messages are sent directly so there is nothing running to stop
+	producer.close()
 
 }
 
@@ -65,7 +66,7 @@ func processFile(file *os.File, size int64) {
 		log.Error().Err(err).Msg("producer is nil")
 		return
 	}
-	err = producer.SendMessage(Message{"text/plain", data})
+	err = producer.SendMessage(Message{"text/plain", data, "local"})
 	if err != nil {
 		log.Error().Err(err).Msg("failed to send message")
 	}
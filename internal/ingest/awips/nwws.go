@@ -4,19 +4,33 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/xmppo/go-xmpp"
+	"mellium.im/xmpp/ping"
+)
+
+const (
+	nwwsDialTimeout  = 60 * time.Second
+	nwwsPingInterval = time.Minute
+	nwwsPingTimeout  = 30 * time.Second
+	// NWWS carries many products a minute so this long without one means the session is broken
+	nwwsStaleAfter = 10 * time.Minute
+	nwwsBackoffMin = time.Second
+	nwwsBackoffMax = 5 * time.Minute
 )
 
 type XmppConfig struct {
@@ -27,170 +41,82 @@ type XmppConfig struct {
 	Resource string
 }
 
-func NWWS(logLevel zerolog.Level) {
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+// Read the XMPP configuration with the given environment prefix
+func xmppConfigFromEnv(prefix string) XmppConfig {
+	return XmppConfig{
+		Server:   os.Getenv(prefix + "_SERVER"),
+		Room:     os.Getenv(prefix + "_ROOM"),
+		User:     os.Getenv(prefix + "_USER"),
+		Pass:     os.Getenv(prefix + "_PASS"),
+		Resource: os.Getenv(prefix + "_RESOURCE"),
+	}
+}
 
-	zerolog.SetGlobalLevel(logLevel)
+// The NWWS sources to ingest from, keyed by name. The first is configured with NWWSOI_*
+// and more can be added for redundancy with NWWSOI_2_*, NWWSOI_3_* and so on.
+func nwwsSources() (map[string]XmppConfig, error) {
+	sources := map[string]XmppConfig{}
 
-	// Configure the XMPP client
-	xmppConfig := XmppConfig{
-		Server:   os.Getenv("NWWSOI_SERVER") + ":5222",
-		Room:     os.Getenv("NWWSOI_ROOM"),
-		User:     os.Getenv("NWWSOI_USER"),
-		Pass:     os.Getenv("NWWSOI_PASS"),
-		Resource: os.Getenv("NWWSOI_RESOURCE"),
-	}
+	for i := 1; ; i++ {
+		prefix := "NWWSOI"
+		if i > 1 {
+			prefix = fmt.Sprintf("NWWSOI_%d", i)
+		}
 
-	err := xmppConfig.check()
-	if err != nil {
-		log.Error().Err(err).Msg("NWWS configuration is invalid")
-		return
-	}
+		config := xmppConfigFromEnv(prefix)
+		// Stop at the first source that is not configured at all
+		if i > 1 && config == (XmppConfig{}) {
+			break
+		}
 
-	xmpp.DefaultConfig = &tls.Config{
-		ServerName:         xmppConfig.serverName(),
-		InsecureSkipVerify: false,
+		if err := config.check(); err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+		sources[strings.ToLower(prefix)] = config
 	}
 
-	options := xmpp.Options{
-		Host:        xmppConfig.Server,
-		User:        xmppConfig.User + "@" + xmppConfig.serverName(),
-		Password:    xmppConfig.Pass,
-		Resource:    xmppConfig.Resource,
-		NoTLS:       true,
-		StartTLS:    true,
-		Debug:       false, // Set to true if you want to see debug information
-		Session:     true,
-		DialTimeout: 60 * time.Second,
-	}
+	return sources, nil
+}
 
-	// Create the XMPP client
-	client, err := options.NewClient()
-	if err != nil {
-		log.Error().Err(err).Msg("failed to create XMPP client")
-		return
-	}
+func NWWS(logLevel zerolog.Level) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	log.Info().Msgf("connected to %s", xmppConfig.Server)
+	zerolog.SetGlobalLevel(logLevel)
 
-	// Send presence to the room
-	_, err = client.SendOrg(fmt.Sprintf(`<presence xml:lang='en' from='%s@%s' to='%s@%s/%s'><x></x></presence>`, xmppConfig.User, xmppConfig.Server, xmppConfig.Resource, xmppConfig.Room, xmppConfig.User))
+	// Configure the XMPP clients
+	sources, err := nwwsSources()
 	if err != nil {
-		log.Error().Err(err).Msg("failed to send presence")
+		log.Error().Err(err).Msg("NWWS configuration is invalid")
 		return
 	}
 
-	// Monitoring
-	health := NewHealth()
-
 	producer, err := NewProducer()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to create producer")
 		return
 	}
-
-	timer := time.NewTimer(time.Minute)
+	if len(sources) > 1 && producer.dedup == nil {
+		log.Warn().Int("sources", len(sources)).Msg("de-duplication is disabled so products will be sent once per source")
+	}
 
 	// XMPP listening
-	go func() {
-		defer client.Close()
+	wg := sync.WaitGroup{}
+	for name, config := range sources {
+		// Monitoring is labelled by source
+		health := newHealth(prometheus.WrapRegistererWith(prometheus.Labels{"source": name}, prometheus.DefaultRegisterer))
+		nwws := newNWWSClient(name, config, health, producer)
 
-		var pingId string
-
-		log.Info().Msgf("listening to %s", xmppConfig.Server)
-		for {
-			select {
-			// Stop
-			case <-ctx.Done():
-				log.Warn().Msg("shutting down XMPP client")
-				close(producer.messages)
-				return
-			case <-timer.C:
-				t := time.Now().Format(time.RFC3339)
-				pingId = fmt.Sprintf("ping-%s-%s", xmppConfig.User, t)
-				_, err := client.SendOrg(fmt.Sprintf("<iq type='get' id='%s' from='%s@%s' to='%s@%s/%s'><ping xmlns='urn:xmpp:ping'></ping></iq>", pingId, xmppConfig.User, xmppConfig.Server, xmppConfig.Resource, xmppConfig.Room, xmppConfig.User))
-				if err != nil {
-					log.Error().Err(err).Msg("failed to send ping")
-					return
-				}
-			// Receive messages
-			default:
-				chat, err := client.Recv()
-				if err != nil {
-					log.Error().Err(err).Msg("failed to receive message")
-					continue
-				}
-				health.NWWSReceived.Inc()
-
-				switch v := chat.(type) {
-				case xmpp.IQ:
-					if pingId != "" && v.ID == pingId {
-						switch v.Type {
-						case "result":
-							log.Debug().Msg("received ping response")
-							health.NWWSPing.Set(1)
-						case "error":
-							log.Warn().Msg("received ping error")
-							health.NWWSPing.Set(0)
-						}
-						// Reset timer
-						timer.Reset(time.Minute)
-					}
-				case xmpp.Chat:
-					for _, elem := range v.OtherElem {
-						// NWWS-OI uses 'x' as the XML element containing the raw text
-						if elem.XMLName.Local == "x" {
-							now := time.Now()
-							log.Debug().Time("received", now).Msg("received message")
-							// Get attributes
-							var issued *time.Time = nil
-							var ttaaii string
-							var cccc string
-							var awips string
-							for _, attr := range elem.Attr {
-								switch attr.Name.Local {
-								case "issue":
-									t, err := time.Parse("2006-01-02T15:04:05Z", attr.Value)
-									if err != nil {
-										log.Error().Err(err).Msg("failed to parse x element issue time")
-										continue
-									}
-									issued = &t
-								case "ttaaii":
-									ttaaii = attr.Value
-								case "cccc":
-									cccc = attr.Value
-								case "awipsid":
-									awips = attr.Value
-								}
-							}
-
-							// Remove extra newlines
-							text := strings.ReplaceAll(elem.String(), "\n\n", "\n")
-
-							// Build the message
-							message := streaming.AWIPSRaw{
-								Issued: *issued,
-								TTAAII: ttaaii,
-								CCCC:   cccc,
-								AWIPS:  awips,
-								Text:   text,
-							}
-							data, err := json.Marshal(message)
-							if err != nil {
-								log.Error().Err(err).Msg("failed to marshal awips raw message")
-								continue
-							}
-
-							// Share the message
-							producer.messages <- Message{"application/json", data}
-							health.NWWSProduced.Inc()
-						}
-					}
-				}
-			}
-		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nwws.run(ctx)
+			log.Warn().Str("source", name).Msg("shutting down XMPP client")
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(producer.messages)
 	}()
 
 	go producer.Run()
@@ -205,6 +131,179 @@ func NWWS(logLevel zerolog.Level) {
 	producer.Stop()
 }
 
+// Supervises the NWWS session, reconnecting when it is lost
+type nwwsClient struct {
+	// The name of the source, such as nwwsoi_2
+	name     string
+	config   XmppConfig
+	tls      *tls.Config
+	dial     func(ctx context.Context, conf XmppConfig) (net.Conn, error)
+	health   *Health
+	producer *Producer
+
+	// Unix nanoseconds of the last product, set while the session is served
+	lastProduct atomic.Int64
+	// When the feed was last known to be complete before the session was lost
+	gapFrom time.Time
+}
+
+func newNWWSClient(name string, config XmppConfig, health *Health, producer *Producer) *nwwsClient {
+	return &nwwsClient{
+		name:     name,
+		config:   config,
+		tls:      config.tlsConfig(),
+		dial:     dialNWWS,
+		health:   health,
+		producer: producer,
+	}
+}
+
+// Connect and listen until the context is done, reconnecting with backoff whenever the session is lost
+func (nwws *nwwsClient) run(ctx context.Context) {
+	backoff := newBackoff(nwwsBackoffMin, nwwsBackoffMax)
+
+	for ctx.Err() == nil {
+		session, err := nwws.connect(ctx)
+		if err != nil {
+			wait := backoff.Next()
+			log.Error().Err(err).Dur("retry", wait).Msg("failed to connect to NWWS")
+			if !sleep(ctx, wait) {
+				return
+			}
+			continue
+		}
+
+		connectedAt := time.Now()
+		nwws.connected(connectedAt)
+
+		err = nwws.listen(ctx, session, connectedAt)
+		session.Close()
+		nwws.health.NWWSConnected.Set(0)
+		nwws.health.NWWSPing.Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Products after the last one we received will be missed
+		nwws.gapFrom = nwws.lastProductTime()
+		if nwws.gapFrom.IsZero() {
+			nwws.gapFrom = time.Now()
+		}
+		nwws.health.NWWSReconnects.Inc()
+
+		// Only keep backing off if the session did not last
+		if time.Since(connectedAt) > nwwsPingInterval {
+			backoff.Reset()
+		}
+		wait := backoff.Next()
+		log.Error().Err(err).Dur("retry", wait).Msg("lost NWWS session")
+		if !sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+// Record the new session and log any gap in the feed since the previous one
+func (nwws *nwwsClient) connected(t time.Time) {
+	nwws.health.NWWSConnected.Set(1)
+
+	if nwws.gapFrom.IsZero() {
+		return
+	}
+
+	gap := t.Sub(nwws.gapFrom)
+	nwws.health.NWWSGapSeconds.Add(gap.Seconds())
+	log.Warn().Time("from", nwws.gapFrom).Time("to", t).Str("gap", gap.Round(time.Second).String()).Msg("reconnected to NWWS. Products in this period were missed")
+	nwws.gapFrom = time.Time{}
+}
+
+// Listen to the session until it fails, returning the reason
+func (nwws *nwwsClient) listen(ctx context.Context, session *nwwsSession, connectedAt time.Time) error {
+	ticker := time.NewTicker(nwwsPingInterval)
+	defer ticker.Stop()
+
+	log.Info().Msgf("listening to %s", nwws.config.Server)
+	for {
+		select {
+		// Stop
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-session.served:
+			if err == nil {
+				err = errors.New("session closed by server")
+			}
+			return fmt.Errorf("failed to receive message: %w", err)
+		case <-ticker.C:
+			// NWWS always has something to send so a quiet feed has gone deaf
+			last := connectedAt
+			if t := nwws.lastProductTime(); t.After(last) {
+				last = t
+			}
+			if time.Since(last) > nwwsStaleAfter {
+				return fmt.Errorf("no products received since %s", last.Format(time.RFC3339))
+			}
+
+			if err := nwws.ping(ctx, session); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Ping the server with XEP-0199
+func (nwws *nwwsClient) ping(ctx context.Context, session *nwwsSession) error {
+	err := ping.Send(sessionTimeout(ctx, nwwsPingTimeout), session.session, session.session.LocalAddr().Domain())
+	if err != nil {
+		nwws.health.NWWSPing.Set(0)
+		return fmt.Errorf("failed to ping: %w", err)
+	}
+
+	log.Debug().Msg("received ping response")
+	nwws.health.NWWSPing.Set(1)
+
+	return nil
+}
+
+// Share the raw text of a product
+func (nwws *nwwsClient) produce(product *nwwsProduct) {
+	now := time.Now()
+	log.Debug().Time("received", now).Msg("received message")
+
+	nwws.health.NWWSReceived.Inc()
+	nwws.lastProduct.Store(now.UnixNano())
+	nwws.health.NWWSLastProduct.Set(float64(now.Unix()))
+
+	data, err := json.Marshal(product.raw(now))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal awips raw message")
+		return
+	}
+
+	nwws.producer.messages <- Message{"application/json", data, nwws.name}
+	nwws.health.NWWSProduced.Inc()
+}
+
+func (nwws *nwwsClient) lastProductTime() time.Time {
+	n := nwws.lastProduct.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// Wait for the duration, returning false if the context finished first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
 func (conf *XmppConfig) check() error {
 	item := ""
 	switch "" {
@@ -0,0 +1,401 @@
+package awips
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/xml"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"mellium.im/sasl"
+	"mellium.im/xmlstream"
+	"mellium.im/xmpp"
+	"mellium.im/xmpp/jid"
+	"mellium.im/xmpp/muc"
+	"mellium.im/xmpp/mux"
+	"mellium.im/xmpp/ping"
+	"mellium.im/xmpp/stanza"
+)
+
+const testNWWSServer = "nwws-oi.test"
+
+var testXmppConfig = XmppConfig{
+	Server:   testNWWSServer,
+	Room:     "conference." + testNWWSServer,
+	User:     "forecaster",
+	Pass:     "secret",
+	Resource: "nwws",
+}
+
+// An in-process NWWS-OI server. Each connection joins the room and is sent the next product.
+type fakeNWWS struct {
+	tls *tls.Config
+
+	mu          sync.Mutex
+	products    []nwwsProduct
+	joins       []string
+	history     []string
+	connections int
+	// Close the connection after sending the product
+	drop bool
+	// Refuse to let anyone join the room
+	refuse bool
+
+	pings atomic.Int32
+}
+
+func newFakeNWWS(t *testing.T, products ...nwwsProduct) (*fakeNWWS, *tls.Config) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	return &fakeNWWS{tls: serverTLS, products: products}, clientTLS
+}
+
+// Dial the fake server over an in-memory pipe
+func (f *fakeNWWS) dial(ctx context.Context, conf XmppConfig) (net.Conn, error) {
+	client, server := net.Pipe()
+
+	f.mu.Lock()
+	f.connections++
+	f.mu.Unlock()
+
+	go f.serve(server)
+
+	return client, nil
+}
+
+func (f *fakeNWWS) serve(conn net.Conn) {
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := xmpp.ReceiveClientSession(ctx, jid.MustParse(testNWWSServer), conn,
+		xmpp.StartTLS(f.tls),
+		xmpp.SASLServer(func(n *sasl.Negotiator) bool {
+			user, pass, _ := n.Credentials()
+			return string(user) == testXmppConfig.User && string(pass) == testXmppConfig.Pass
+		}, sasl.Plain),
+		xmpp.BindResource(),
+	)
+	if err != nil {
+		// The client sees the failure
+		return
+	}
+
+	handler := mux.New(stanza.NSClient,
+		mux.IQFunc(stanza.GetIQ, xml.Name{Space: ping.NS, Local: "ping"}, func(iq stanza.IQ, t xmlstream.TokenReadEncoder, start *xml.StartElement) error {
+			f.pings.Add(1)
+			_, err := xmlstream.Copy(t, iq.Result(nil))
+			return err
+		}),
+		mux.PresenceFunc(stanza.AvailablePresence, xml.Name{Space: muc.NS, Local: "x"}, f.join),
+	)
+	session.Serve(handler)
+}
+
+// Accept the join and send the next product to the room
+func (f *fakeNWWS) join(p stanza.Presence, t xmlstream.TokenReadEncoder) error {
+	presence := struct {
+		stanza.Presence
+		X struct {
+			History struct {
+				MaxStanzas string `xml:"maxstanzas,attr"`
+			} `xml:"history"`
+		} `xml:"http://jabber.org/protocol/muc x"`
+	}{}
+	if err := xml.NewTokenDecoder(t).Decode(&presence); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.joins = append(f.joins, p.To.String())
+	f.history = append(f.history, presence.X.History.MaxStanzas)
+	var product *nwwsProduct
+	if len(f.products) > 0 {
+		product = &f.products[0]
+		f.products = f.products[1:]
+	}
+	drop := f.drop
+	refuse := f.refuse
+	f.mu.Unlock()
+
+	if refuse {
+		refused := struct {
+			XMLName xml.Name     `xml:"jabber:client presence"`
+			From    string       `xml:"from,attr"`
+			Type    string       `xml:"type,attr"`
+			Error   stanza.Error `xml:"error"`
+		}{From: p.To.String(), Type: "error", Error: stanza.Error{Type: stanza.Auth, Condition: stanza.Forbidden}}
+		return t.Encode(refused)
+	}
+
+	self := struct {
+		XMLName xml.Name `xml:"jabber:client presence"`
+		From    string   `xml:"from,attr"`
+		X       struct {
+			Item struct {
+				Affiliation string `xml:"affiliation,attr"`
+				Role        string `xml:"role,attr"`
+			} `xml:"item"`
+			Status struct {
+				Code int `xml:"code,attr"`
+			} `xml:"status"`
+		} `xml:"http://jabber.org/protocol/muc#user x"`
+	}{From: p.To.String()}
+	self.X.Item.Affiliation = "none"
+	self.X.Item.Role = "visitor"
+	self.X.Status.Code = 110
+	if err := t.Encode(self); err != nil {
+		return err
+	}
+
+	if product == nil {
+		return nil
+	}
+
+	message := struct {
+		XMLName xml.Name `xml:"jabber:client message"`
+		From    string   `xml:"from,attr"`
+		Type    string   `xml:"type,attr"`
+		Body    string   `xml:"body"`
+		Product nwwsProduct
+	}{
+		From:    p.To.Bare().String() + "/nwws-oi",
+		Type:    "groupchat",
+		Body:    product.CCCC + " issues " + product.AWIPSID,
+		Product: *product,
+	}
+	if err := t.Encode(message); err != nil {
+		return err
+	}
+
+	if drop {
+		return net.ErrClosed
+	}
+	return nil
+}
+
+func testTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: testNWWSServer},
+		DNSNames:     []string{testNWWSServer},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	client := &tls.Config{
+		ServerName: testNWWSServer,
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return server, client
+}
+
+func newTestNWWSClient(fake *fakeNWWS, clientTLS *tls.Config) *nwwsClient {
+	nwws := newNWWSClient("test", testXmppConfig, newHealth(prometheus.NewRegistry()), &Producer{messages: make(chan Message, 10)})
+	nwws.tls = clientTLS
+	nwws.dial = fake.dial
+	return nwws
+}
+
+func receiveRaw(t *testing.T, nwws *nwwsClient) streaming.AWIPSRaw {
+	t.Helper()
+
+	select {
+	case message := <-nwws.producer.messages:
+		raw := streaming.AWIPSRaw{}
+		if err := json.Unmarshal(message.data, &raw); err != nil {
+			t.Fatalf("failed to unmarshal message: %v", err)
+		}
+		return raw
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for product")
+	}
+	return streaming.AWIPSRaw{}
+}
+
+var testProduct = nwwsProduct{
+	Issue:   "2025-05-19T23:05:00Z",
+	TTAAII:  "WUUS53",
+	CCCC:    "KOAX",
+	AWIPSID: "TOROAX",
+	ID:      "14425.5",
+	Text:    "\n\n555\nWUUS53 KOAX 192305\nTOROAX\n\nBULLETIN - EAS ACTIVATION REQUESTED\nTornado Warning\n",
+}
+
+func TestNWWSIngest(t *testing.T) {
+	fake, clientTLS := newFakeNWWS(t, testProduct)
+	nwws := newTestNWWSClient(fake, clientTLS)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nwws.run(ctx)
+
+	raw := receiveRaw(t, nwws)
+
+	if raw.TTAAII != "WUUS53" || raw.CCCC != "KOAX" || raw.AWIPS != "TOROAX" {
+		t.Errorf("unexpected product attributes %+v", raw)
+	}
+	if !raw.Issued.Equal(time.Date(2025, 5, 19, 23, 5, 0, 0, time.UTC)) {
+		t.Errorf("expected issued 2025-05-19T23:05:00Z, got %s", raw.Issued)
+	}
+	if raw.Text != "\n555\nWUUS53 KOAX 192305\nTOROAX\nBULLETIN - EAS ACTIVATION REQUESTED\nTornado Warning\n" {
+		t.Errorf("unexpected product text %q", raw.Text)
+	}
+
+	// The product can arrive before the join completes
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(nwws.health.NWWSConnected) != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := testutil.ToFloat64(nwws.health.NWWSConnected); v != 1 {
+		t.Errorf("expected connected gauge to be 1, got %v", v)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.joins) != 1 || fake.joins[0] != "nwws@conference.nwws-oi.test/forecaster" {
+		t.Errorf("unexpected room joins %v", fake.joins)
+	}
+	if fake.history[0] != "0" {
+		t.Errorf("expected history to be suppressed, got maxstanzas %q", fake.history[0])
+	}
+}
+
+func TestNWWSPing(t *testing.T) {
+	fake, clientTLS := newFakeNWWS(t)
+	nwws := newTestNWWSClient(fake, clientTLS)
+
+	session, err := nwws.connect(context.Background())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer session.Close()
+
+	if err := nwws.ping(context.Background(), session); err != nil {
+		t.Errorf("failed to ping: %v", err)
+	}
+	if n := fake.pings.Load(); n != 1 {
+		t.Errorf("expected 1 ping, got %d", n)
+	}
+	if v := testutil.ToFloat64(nwws.health.NWWSPing); v != 1 {
+		t.Errorf("expected ping gauge to be 1, got %v", v)
+	}
+}
+
+func TestNWWSJoinRefused(t *testing.T) {
+	fake, clientTLS := newFakeNWWS(t)
+	fake.refuse = true
+	nwws := newTestNWWSClient(fake, clientTLS)
+
+	_, err := nwws.connect(context.Background())
+	if err == nil {
+		t.Fatal("expected the refused join to fail")
+	}
+	if !strings.Contains(err.Error(), string(stanza.Forbidden)) {
+		t.Errorf("expected the room's error, got %v", err)
+	}
+}
+
+func TestNWWSReconnect(t *testing.T) {
+	second := testProduct
+	second.AWIPSID = "SVSOAX"
+
+	fake, clientTLS := newFakeNWWS(t, testProduct, second)
+	fake.drop = true
+	nwws := newTestNWWSClient(fake, clientTLS)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nwws.run(ctx)
+
+	if raw := receiveRaw(t, nwws); raw.AWIPS != "TOROAX" {
+		t.Errorf("expected TOROAX first, got %s", raw.AWIPS)
+	}
+	if raw := receiveRaw(t, nwws); raw.AWIPS != "SVSOAX" {
+		t.Errorf("expected SVSOAX after reconnecting, got %s", raw.AWIPS)
+	}
+
+	if v := testutil.ToFloat64(nwws.health.NWWSReconnects); v < 1 {
+		t.Errorf("expected a reconnect to be counted, got %v", v)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.connections < 2 {
+		t.Errorf("expected the client to dial again, got %d connections", fake.connections)
+	}
+}
+
+func TestNWWSBadCredentials(t *testing.T) {
+	fake, clientTLS := newFakeNWWS(t)
+	nwws := newTestNWWSClient(fake, clientTLS)
+	nwws.config.Pass = "wrong"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := nwws.connect(ctx); err == nil {
+		t.Error("expected connecting with the wrong password to fail")
+	}
+}
+
+func TestNWWSSources(t *testing.T) {
+	for _, prefix := range []string{"NWWSOI", "NWWSOI_2"} {
+		t.Setenv(prefix+"_SERVER", testNWWSServer)
+		t.Setenv(prefix+"_ROOM", testXmppConfig.Room)
+		t.Setenv(prefix+"_USER", testXmppConfig.User)
+		t.Setenv(prefix+"_PASS", testXmppConfig.Pass)
+		t.Setenv(prefix+"_RESOURCE", testXmppConfig.Resource)
+	}
+
+	sources, err := nwwsSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources["nwwsoi"] != testXmppConfig || sources["nwwsoi_2"] != testXmppConfig {
+		t.Errorf("unexpected sources %+v", sources)
+	}
+
+	// A partly configured source is an error rather than being ignored
+	t.Setenv("NWWSOI_3_SERVER", testNWWSServer)
+	if _, err := nwwsSources(); err == nil {
+		t.Error("expected an incomplete source to fail")
+	}
+}
@@ -2,83 +2,273 @@ package awips
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/metdatasystem/us/shared/streaming"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	publishTimeout = 5 * time.Second
+	// Messages replayed from the spool before checking for new ones
+	spoolReplayBatch = 100
+	defaultSpoolDir  = "spool"
+)
+
 type Message struct {
 	contentType string
 	data        []byte
+	// Where the message came from, such as the name of the NWWS source
+	source string
+}
+
+// The part of the transport the producer uses. Publishing waits for the broker to accept the message.
+type publisher interface {
+	PublishAWIPS(ctx context.Context, message streaming.Message) error
+	Closed() <-chan error
+	Close() error
 }
 
 type Producer struct {
-	channel  *amqp.Channel
+	connect  func() (publisher, error)
+	channel  publisher
+	spool    *spool
 	messages chan Message
-	done     bool
+	// Closed by Stop to end Run, which closes stopped once the spool and archive are closed
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	initOnce sync.Once
+	// Drops products already sent by another source. Nil when disabled.
+	dedup *dedup
+	// Keeps a copy of every product. Nil when disabled.
+	archive *archive
 }
 
+// Create a producer spooling to INGEST_SPOOL_DIR. If the broker cannot be reached messages are
+// spooled until it can. The broker is configured as described by streaming.NewTransport.
 func NewProducer() (*Producer, error) {
-
-	conn, err := amqp.Dial(os.Getenv("RABBIT_URL"))
-	if err != nil {
-		return nil, err
+	dir := os.Getenv("INGEST_SPOOL_DIR")
+	if dir == "" {
+		dir = defaultSpoolDir
 	}
 
-	ch, err := conn.Channel()
+	spool, err := openSpool(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = streaming.DeclareAWIPSQueue(ch)
-
 	producer := &Producer{
-		channel:  ch,
+		connect: func() (publisher, error) {
+			return streaming.NewTransport("us.ingest.awips")
+		},
+		spool:    spool,
 		messages: make(chan Message),
-		done:     false,
 	}
+
+	producer.dedup, err = newDedup()
 	if err != nil {
+		spool.close()
 		return nil, err
 	}
+	producer.archive = newArchive()
+
+	producer.channel, err = producer.connect()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to connect to the broker. Messages will be spooled until it is available")
+	}
 
 	return producer, nil
 }
 
+func (p *Producer) init() {
+	p.initOnce.Do(func() {
+		p.stop = make(chan struct{})
+		p.stopped = make(chan struct{})
+	})
+}
+
+// Publish messages until the messages channel is closed or Stop is called, spooling them while the
+// broker is unavailable
 func (p *Producer) Run() {
-	for message := range p.messages {
-		if p.done {
-			p.channel.Close()
+	p.init()
+	defer close(p.stopped)
+
+	backoff := newBackoff(nwwsBackoffMin, nwwsBackoffMax)
+	retry := time.NewTimer(0)
+	defer retry.Stop()
+	retrying := true
+	if p.channel != nil && p.spool.empty() {
+		retry.Stop()
+		retrying = false
+	}
+
+	schedule := func(wait time.Duration) {
+		if !retrying {
+			retry.Reset(wait)
+			retrying = true
+		}
+	}
+
+	for {
+		var closed <-chan error
+		if p.channel != nil {
+			closed = p.channel.Closed()
+		}
+
+		select {
+		case <-p.stop:
+			p.close()
 			return
+
+		case message, ok := <-p.messages:
+			if !ok {
+				p.close()
+				return
+			}
+
+			// Keep exactly what arrived from every source, including copies dropped below
+			if p.archive != nil {
+				if err := p.archive.write(message, time.Now()); err != nil {
+					log.Error().Err(err).Msg("failed to archive message")
+				}
+			}
+
+			// Several sources can be running so the same product may arrive more than once
+			if p.dedup != nil && p.dedup.duplicate(message) {
+				continue
+			}
+
+			if !p.send(message) {
+				schedule(backoff.Next())
+			}
+
+		case err := <-closed:
+			log.Error().Err(err).Msg("lost broker connection")
+			p.disconnect()
+			schedule(backoff.Next())
+
+		case <-retry.C:
+			retrying = false
+
+			if p.channel == nil {
+				var err error
+				p.channel, err = p.connect()
+				if err != nil {
+					wait := backoff.Next()
+					log.Error().Err(err).Dur("retry", wait).Msg("failed to reconnect to the broker")
+					schedule(wait)
+					continue
+				}
+				log.Info().Msg("reconnected to the broker")
+			}
+
+			more, err := p.replay(spoolReplayBatch)
+			if err != nil {
+				wait := backoff.Next()
+				log.Error().Err(err).Dur("retry", wait).Msg("failed to replay spool")
+				p.disconnect()
+				schedule(wait)
+				continue
+			}
+
+			backoff.Reset()
+			// Keep going between new messages until the spool is empty
+			if more {
+				schedule(0)
+			}
 		}
+	}
+}
 
+// Publish the message, or spool it if the broker is unavailable. Messages go to the spool while it
+// has anything in it so they stay in order. Returns false if the message was spooled.
+func (p *Producer) send(message Message) bool {
+	if p.channel != nil && p.spool.empty() {
 		err := p.SendMessage(message)
+		if err == nil {
+			return true
+		}
+		log.Error().Err(err).Msg("failed to send message. Spooling it")
+		p.disconnect()
+	}
+
+	if err := p.spool.append(message); err != nil {
+		log.Error().Err(err).Msg("failed to spool message. It has been lost")
+	}
+	return false
+}
+
+// Publish spooled messages in order, returning whether any are left
+func (p *Producer) replay(limit int) (bool, error) {
+	for range limit {
+		message, next, err := p.spool.peek()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
 		if err != nil {
-			log.Error().Err(err).Msg("failed to send message")
+			return false, fmt.Errorf("failed to read spool: %w", err)
+		}
+
+		if err := p.SendMessage(message); err != nil {
+			return false, err
 		}
 
+		if err := p.spool.ack(next); err != nil {
+			return false, err
+		}
 	}
+
+	return !p.spool.empty(), nil
 }
 
+func (p *Producer) disconnect() {
+	if p.channel != nil {
+		if err := p.channel.Close(); err != nil {
+			log.Debug().Err(err).Msg("failed to close broker connection")
+		}
+		p.channel = nil
+	}
+}
+
+func (p *Producer) close() {
+	p.disconnect()
+	if p.archive != nil {
+		if err := p.archive.close(); err != nil {
+			log.Error().Err(err).Msg("failed to close archive")
+		}
+	}
+	if err := p.spool.close(); err != nil {
+		log.Error().Err(err).Msg("failed to close spool")
+	}
+}
+
+// End Run and wait for it to close the spool and archive. Run must have been started.
 func (p *Producer) Stop() {
-	p.done = true
+	p.init()
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.stopped
 }
 
+// Publish the message and wait for the broker to accept it
 func (p *Producer) SendMessage(message Message) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if p.channel == nil {
+		return errors.New("not connected to the broker")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
 	defer cancel()
 
-	return p.channel.PublishWithContext(ctx,
-		"",                   // exchange
-		streaming.QueueAWIPS, // routing key
-		false,                // mandatory
-		false,                // immediate
-		amqp.Publishing{
-			ContentType: message.contentType,
-			Timestamp:   time.Now(),
-			AppId:       "us.ingest.awips",
-			Body:        message.data,
-		})
+	return p.channel.PublishAWIPS(ctx, streaming.Message{
+		ContentType: message.contentType,
+		Timestamp:   time.Now(),
+		Body:        message.data,
+	})
 }
@@ -0,0 +1,200 @@
+package awips
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A broker that can be taken down
+type fakeBroker struct {
+	mu        sync.Mutex
+	up        bool
+	published []string
+}
+
+func (b *fakeBroker) connect() (publisher, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.up {
+		return nil, errors.New("connection refused")
+	}
+	return &fakePublisher{broker: b}, nil
+}
+
+func (b *fakeBroker) setUp(up bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.up = up
+}
+
+func (b *fakeBroker) messages() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.published...)
+}
+
+type fakePublisher struct {
+	broker *fakeBroker
+}
+
+func (f *fakePublisher) PublishAWIPS(ctx context.Context, message streaming.Message) error {
+	f.broker.mu.Lock()
+	defer f.broker.mu.Unlock()
+
+	if !f.broker.up {
+		return errors.New("channel closed")
+	}
+	f.broker.published = append(f.broker.published, string(message.Body))
+	return nil
+}
+
+func (f *fakePublisher) Closed() <-chan error {
+	return nil
+}
+
+func (f *fakePublisher) Close() error {
+	return nil
+}
+
+func TestProducerSpoolsWhileBrokerIsDown(t *testing.T) {
+	broker := &fakeBroker{up: true}
+
+	spool, err := openSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &Producer{
+		connect:  broker.connect,
+		spool:    spool,
+		messages: make(chan Message),
+	}
+	producer.channel, _ = broker.connect()
+
+	done := make(chan struct{})
+	go func() {
+		producer.Run()
+		close(done)
+	}()
+
+	send := func(i int) {
+		producer.messages <- Message{"text/plain", []byte(fmt.Sprintf("product %d", i)), "test"}
+	}
+
+	send(0)
+	broker.setUp(false)
+	send(1)
+	send(2)
+	broker.setUp(true)
+	send(3)
+
+	// The spool is replayed once the producer reconnects
+	expected := "[product 0 product 1 product 2 product 3]"
+	deadline := time.Now().Add(10 * time.Second)
+	for fmt.Sprint(broker.messages()) != expected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if published := fmt.Sprint(broker.messages()); published != expected {
+		t.Errorf("expected %s in order, got %s", expected, published)
+	}
+
+	close(producer.messages)
+	<-done
+}
+
+func TestProducerTransport(t *testing.T) {
+	transport := streaming.NewMemoryTransport()
+	defer transport.Close()
+
+	spool, err := openSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &Producer{
+		channel:  transport,
+		spool:    spool,
+		messages: make(chan Message),
+	}
+	go producer.Run()
+	defer close(producer.messages)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deliveries, err := transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := testProduct.raw(time.Now())
+	data, err := raw.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer.messages <- Message{"application/json", data, "test"}
+
+	select {
+	case d := <-deliveries:
+		raw := streaming.AWIPSRaw{}
+		if err := raw.Unmarshal(d.Body); err != nil {
+			t.Fatal(err)
+		}
+		if d.ContentType != "application/json" || raw.AWIPS != "TOROAX" {
+			t.Errorf("unexpected delivery %s %+v", d.ContentType, raw)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the product")
+	}
+}
+
+func TestProducerStopArchivesDuplicates(t *testing.T) {
+	transport := streaming.NewMemoryTransport()
+	defer transport.Close()
+
+	spool, err := openSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	producer := &Producer{
+		channel:  transport,
+		spool:    spool,
+		messages: make(chan Message),
+		dedup: &dedup{
+			store:      newMemoryStore(),
+			window:     time.Minute,
+			duplicates: prometheus.NewCounter(prometheus.CounterOpts{Name: "test_duplicates"}),
+		},
+		archive: &archive{dir: dir},
+	}
+	go producer.Run()
+
+	raw := testProduct.raw(time.Now())
+	data, err := raw.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer.messages <- Message{"application/json", data, "nwwsoi"}
+	producer.messages <- Message{"application/json", data, "nwwsoi_2"}
+
+	// Stop returns once the archive has been closed
+	producer.Stop()
+	if producer.archive.file != nil {
+		t.Fatal("expected the archive to be closed")
+	}
+
+	path, _ := producer.archive.paths(producer.archive.hour, 0)
+	records, err := readArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Source != "nwwsoi" || records[1].Source != "nwwsoi_2" {
+		t.Errorf("expected both copies to be archived with their source, got %+v", records)
+	}
+}
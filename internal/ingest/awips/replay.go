@@ -0,0 +1,277 @@
+package awips
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// A product to replay with when it was originally issued and received
+type replayProduct struct {
+	issued time.Time
+	// Zero when the product was not read from an archive
+	received time.Time
+	message  Message
+	source   string
+}
+
+// When the product arrived, or was issued when that is not known
+func (product replayProduct) arrived() time.Time {
+	if product.received.IsZero() {
+		return product.issued
+	}
+	return product.received
+}
+
+// Republish the products at the path in the order they were issued. The speed scales the time
+// between their arrivals, so 10 replays ten times faster, and 0 sends them as fast as possible.
+func Replay(path string, speed float64, logLevel zerolog.Level) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	zerolog.SetGlobalLevel(logLevel)
+
+	products, err := loadReplay(path)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load products to replay")
+		return
+	}
+	products, duplicates := dropReplayDuplicates(products)
+	if duplicates > 0 {
+		log.Info().Int("duplicates", duplicates).Msg("dropped products archived by more than one source")
+	}
+	if len(products) == 0 {
+		log.Warn().Str("path", path).Msg("no products to replay")
+		return
+	}
+
+	transport, err := streaming.NewTransport("us.ingest.awips")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to initialise stream transport")
+		return
+	}
+	defer transport.Close()
+
+	// Duplicates are dropped as the products are loaded so replays go straight to the broker
+	// without spooling or de-duplication
+	producer := &Producer{channel: transport}
+
+	log.Info().Int("products", len(products)).Time("from", products[0].issued).Time("to", products[len(products)-1].issued).
+		Float64("speed", speed).Msg("replaying products")
+
+	sent := replay(ctx, products, speed, producer.SendMessage)
+
+	log.Info().Int("sent", sent).Int("products", len(products)).Msg("finished replay")
+}
+
+// Send the products with their original spacing scaled by the speed, returning how many were sent
+func replay(ctx context.Context, products []replayProduct, speed float64, send func(Message) error) int {
+	start := time.Now()
+	var offset time.Duration
+
+	sent := 0
+	for i, product := range products {
+		if ctx.Err() != nil {
+			return sent
+		}
+		if i > 0 {
+			offset += replayGap(products[i-1], product)
+		}
+		if speed > 0 {
+			// Schedule from the start so time spent sending does not add up
+			due := start.Add(time.Duration(float64(offset) / speed))
+			if !sleep(ctx, time.Until(due)) {
+				return sent
+			}
+		}
+
+		if err := send(product.message); err != nil {
+			log.Error().Err(err).Str("source", product.source).Msg("failed to send product")
+			continue
+		}
+		sent++
+		log.Debug().Str("source", product.source).Time("issued", product.issued).Msg("replayed product")
+	}
+
+	return sent
+}
+
+// The time between two products as they arrived. Issue times only carry the minute so are
+// used only when either was not received from an archive.
+func replayGap(previous, next replayProduct) time.Duration {
+	gap := next.issued.Sub(previous.issued)
+	if !previous.received.IsZero() && !next.received.IsZero() {
+		gap = next.received.Sub(previous.received)
+	}
+	return max(gap, 0)
+}
+
+// Drop copies of a product that arrived within the de-duplication window of the first, as the
+// ingest would have. The archive keeps the copy from every source. Returns how many were dropped.
+func dropReplayDuplicates(products []replayProduct) ([]replayProduct, int) {
+	first := map[string]time.Time{}
+	kept := make([]replayProduct, 0, len(products))
+
+	for _, product := range products {
+		key := dedupKey(product.message)
+		if arrived, ok := first[key]; ok && product.arrived().Sub(arrived).Abs() < defaultDedupWindow {
+			continue
+		}
+		first[key] = product.arrived()
+		kept = append(kept, product)
+	}
+
+	return kept, len(products) - len(kept)
+}
+
+// Parse a speed such as 1, 10x or max
+func ParseReplaySpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return 0, nil
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed < 0 {
+		return 0, fmt.Errorf("invalid replay speed %s", s)
+	}
+	return speed, nil
+}
+
+// Read products from a file or directory, ordered by issue time. NDJSON archives written by the
+// archive sink, compressed or not, are read record by record and anything else is a product's text.
+func loadReplay(path string) ([]replayProduct, error) {
+	products := []replayProduct{}
+
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, indexExtension) {
+			return nil
+		}
+
+		var loaded []replayProduct
+		if strings.HasSuffix(p, ".ndjson") || strings.HasSuffix(p, archiveExtension) {
+			loaded, err = loadReplayArchive(p)
+		} else {
+			loaded, err = loadReplayText(p)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+
+		products = append(products, loaded...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		return products[i].issued.Before(products[j].issued)
+	})
+
+	return products, nil
+}
+
+func loadReplayArchive(path string) ([]replayProduct, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	products := []replayProduct{}
+	decoder := json.NewDecoder(reader)
+	for line := 0; ; line++ {
+		record := archiveRecord{}
+		err := decoder.Decode(&record)
+		// A writer that was killed leaves the file without the end of the gzip stream
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return products, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode line %d: %w", line, err)
+		}
+
+		message := Message{record.ContentType, []byte(record.Text), record.Source}
+		if record.ContentType == "" {
+			message.contentType = "text/plain"
+		}
+		if record.ContentType == "application/json" {
+			data, err := record.AWIPSRaw.Marshal()
+			if err != nil {
+				return nil, err
+			}
+			message = Message{"application/json", data, record.Source}
+		}
+
+		issued := record.Issued
+		if issued.IsZero() {
+			issued = productIssued(record.Text, record.Received)
+		}
+
+		products = append(products, replayProduct{
+			issued:   issued,
+			received: record.Received,
+			message:  message,
+			source:   fmt.Sprintf("%s:%d", path, line),
+		})
+	}
+}
+
+func loadReplayText(path string) ([]replayProduct, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return []replayProduct{{
+		issued:  productIssued(string(data), stat.ModTime()),
+		message: Message{"text/plain", data, path},
+		source:  path,
+	}}, nil
+}
+
+// When the product was issued, from the issuance line or the WMO header near the reference time
+func productIssued(text string, reference time.Time) time.Time {
+	if issued, err := awips.GetIssuedTime(text); err == nil && !issued.IsZero() {
+		return issued.UTC()
+	}
+	if wmo, err := awips.ParseWMO(text); err == nil {
+		return awips.MergeDayTime(reference, wmo.Issued)
+	}
+	return reference.UTC()
+}
@@ -0,0 +1,165 @@
+package awips
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	// An archive received out of order alongside loose product files
+	a := &archive{dir: filepath.Join(dir, "archive")}
+	received := time.Date(2025, 5, 19, 23, 30, 0, 0, time.UTC)
+	late := testProduct.raw(received)
+	data, err := late.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.write(Message{"application/json", data, "test"}, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.write(Message{"text/plain", []byte("WWUS83 KDMX 192250\nSPSDMX\n"), "test"}, received.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	text := "WFUS53 KDMX 192310\nTORDMX\n\nTornado Warning\nNational Weather Service Des Moines IA\n610 PM CDT Mon May 19 2025\n"
+	if err := os.WriteFile(filepath.Join(dir, "TORDMX.txt"), []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	products, err := loadReplay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 3 {
+		t.Fatalf("expected 3 products, got %d", len(products))
+	}
+
+	expected := []time.Time{
+		time.Date(2025, 5, 19, 22, 50, 0, 0, time.UTC),
+		time.Date(2025, 5, 19, 23, 5, 0, 0, time.UTC),
+		time.Date(2025, 5, 19, 23, 10, 0, 0, time.UTC),
+	}
+	for i, product := range products {
+		if !product.issued.Equal(expected[i]) {
+			t.Errorf("expected product %d issued at %s, got %s", i, expected[i], product.issued)
+		}
+	}
+	if products[1].message.contentType != "application/json" || products[2].message.contentType != "text/plain" {
+		t.Errorf("unexpected content types %s and %s", products[1].message.contentType, products[2].message.contentType)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	issued := time.Date(2025, 5, 19, 23, 0, 0, 0, time.UTC)
+	products := []replayProduct{
+		{issued: issued, message: Message{"text/plain", []byte("0"), "test"}},
+		{issued: issued.Add(time.Minute), message: Message{"text/plain", []byte("1"), "test"}},
+		{issued: issued.Add(2 * time.Minute), message: Message{"text/plain", []byte("2"), "test"}},
+	}
+
+	sentAt := []time.Duration{}
+	start := time.Now()
+	send := func(message Message) error {
+		sentAt = append(sentAt, time.Since(start))
+		return nil
+	}
+
+	// A minute apart at 600x is 100ms apart
+	if sent := replay(context.Background(), products, 600, send); sent != 3 {
+		t.Fatalf("expected 3 products sent, got %d", sent)
+	}
+	if sentAt[1] < 100*time.Millisecond || sentAt[2] < 200*time.Millisecond {
+		t.Errorf("expected products spaced 100ms apart, got %v", sentAt)
+	}
+
+	// As fast as possible ignores the spacing
+	sentAt = sentAt[:0]
+	start = time.Now()
+	replay(context.Background(), products, 0, send)
+	if sentAt[2] > 100*time.Millisecond {
+		t.Errorf("expected products sent immediately, got %v", sentAt)
+	}
+
+	// Stopping part way sends no more
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if sent := replay(ctx, products, 600, send); sent != 1 {
+		t.Errorf("expected only the first product before stopping, got %d", sent)
+	}
+}
+
+func TestReplayArrivalTiming(t *testing.T) {
+	issued := time.Date(2025, 5, 19, 23, 0, 0, 0, time.UTC)
+	products := []replayProduct{
+		{issued: issued, received: issued.Add(5 * time.Second), message: Message{"text/plain", []byte("0"), "test"}},
+		{issued: issued, received: issued.Add(11 * time.Second), message: Message{"text/plain", []byte("1"), "test"}},
+		// Without a received time the issue times are used
+		{issued: issued.Add(time.Minute), message: Message{"text/plain", []byte("2"), "test"}},
+	}
+
+	if gap := replayGap(products[0], products[1]); gap != 6*time.Second {
+		t.Errorf("expected the products in the same minute to be 6s apart, got %v", gap)
+	}
+	if gap := replayGap(products[1], products[2]); gap != time.Minute {
+		t.Errorf("expected the issue times to be used, got %v", gap)
+	}
+
+	sentAt := []time.Duration{}
+	start := time.Now()
+	send := func(message Message) error {
+		sentAt = append(sentAt, time.Since(start))
+		return nil
+	}
+
+	// 6s apart at 60x is 100ms apart rather than sent together
+	replay(context.Background(), products[:2], 60, send)
+	if sentAt[1] < 100*time.Millisecond {
+		t.Errorf("expected products spaced 100ms apart, got %v", sentAt)
+	}
+}
+
+func TestDropReplayDuplicates(t *testing.T) {
+	received := time.Date(2025, 5, 19, 23, 6, 0, 0, time.UTC)
+	raw := testProduct.raw(received)
+	data, err := raw.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued := time.Date(2025, 5, 19, 23, 5, 0, 0, time.UTC)
+	products := []replayProduct{
+		{issued: issued, received: received, message: Message{"application/json", data, "nwws"}},
+		// The same product archived from another source
+		{issued: issued, received: received.Add(2 * time.Second), message: Message{"application/json", data, "ldm"}},
+		// Sent again long after
+		{issued: issued, received: received.Add(time.Hour), message: Message{"application/json", data, "nwws"}},
+	}
+
+	kept, duplicates := dropReplayDuplicates(products)
+	if duplicates != 1 || len(kept) != 2 {
+		t.Fatalf("expected 1 duplicate dropped, got %d with %d kept", duplicates, len(kept))
+	}
+	if kept[0].message.source != "nwws" || !kept[1].received.Equal(received.Add(time.Hour)) {
+		t.Errorf("expected the first copy and the later resend to be kept, got %+v", kept)
+	}
+}
+
+func TestParseReplaySpeed(t *testing.T) {
+	for input, expected := range map[string]float64{"1": 1, "10x": 10, "0.5": 0.5, "max": 0, "MAX": 0} {
+		speed, err := ParseReplaySpeed(input)
+		if err != nil || speed != expected {
+			t.Errorf("expected %s to be %v, got %v %v", input, expected, speed, err)
+		}
+	}
+	if _, err := ParseReplaySpeed("fast"); err == nil {
+		t.Error("expected an invalid speed to fail")
+	}
+}
@@ -0,0 +1,209 @@
+package awips
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	spoolFile       = "awips.spool"
+	spoolOffsetFile = "awips.spool.offset"
+	// CRC, content type length and data length
+	spoolHeaderSize = 4 + 2 + 4
+	// Anything bigger is corrupt. The largest products are a few hundred kilobytes.
+	spoolMaxRecord = 64 << 20
+)
+
+// An append-only file of messages waiting to be published. Messages are replayed in the order
+// they were spooled and only removed once acknowledged, so a crash can cause a message to be
+// sent twice but never lost.
+type spool struct {
+	dir  string
+	file *os.File
+	// Where the next message to replay starts
+	offset int64
+	// Where the next message will be appended
+	size int64
+}
+
+// Open the spool in the directory, creating it if needed. A partly written message at the end,
+// left by a crash while spooling, is discarded.
+func openSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, spoolFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+
+	s := &spool{dir: dir, file: file}
+
+	s.offset, err = s.readOffset()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Find the end of the last complete message
+	s.size = s.offset
+	count := 0
+	for {
+		_, next, err := s.read(s.size)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warn().Err(err).Int64("offset", s.size).Msg("discarding corrupt end of spool")
+			}
+			break
+		}
+		s.size = next
+		count++
+	}
+	if err := file.Truncate(s.size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate spool: %w", err)
+	}
+
+	if count > 0 {
+		log.Info().Int("messages", count).Msg("spool has messages to replay")
+	}
+
+	return s, nil
+}
+
+func (s *spool) readOffset() (int64, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolOffsetFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spool offset: %w", err)
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid spool offset %q", data)
+	}
+
+	stat, err := s.file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat spool: %w", err)
+	}
+	// The spool was truncated after the offset was written
+	if offset > stat.Size() {
+		return 0, nil
+	}
+
+	return offset, nil
+}
+
+// Persist the offset by replacing the file so it is never partly written
+func (s *spool) writeOffset() error {
+	path := filepath.Join(s.dir, spoolOffsetFile)
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(s.offset, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *spool) empty() bool {
+	return s.offset >= s.size
+}
+
+// Append the message and sync it to disk
+func (s *spool) append(message Message) error {
+	if len(message.contentType) > 0xffff || len(message.data) > spoolMaxRecord {
+		return errors.New("message is too large to spool")
+	}
+
+	record := make([]byte, spoolHeaderSize, spoolHeaderSize+len(message.contentType)+len(message.data))
+	binary.BigEndian.PutUint16(record[4:], uint16(len(message.contentType)))
+	binary.BigEndian.PutUint32(record[6:], uint32(len(message.data)))
+	record = append(record, message.contentType...)
+	record = append(record, message.data...)
+	binary.BigEndian.PutUint32(record[0:], crc32.ChecksumIEEE(record[4:]))
+
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return fmt.Errorf("failed to write to spool: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+	s.size += int64(len(record))
+
+	return nil
+}
+
+// The oldest message that has not been acknowledged and the offset after it
+func (s *spool) peek() (Message, int64, error) {
+	if s.empty() {
+		return Message{}, s.offset, io.EOF
+	}
+	return s.read(s.offset)
+}
+
+func (s *spool) read(offset int64) (Message, int64, error) {
+	header := make([]byte, spoolHeaderSize)
+	if _, err := s.file.ReadAt(header, offset); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Message{}, offset, errors.New("partial message header")
+		}
+		return Message{}, offset, err
+	}
+
+	typeLen := int(binary.BigEndian.Uint16(header[4:]))
+	dataLen := int(binary.BigEndian.Uint32(header[6:]))
+	if dataLen > spoolMaxRecord {
+		return Message{}, offset, fmt.Errorf("message length %d is too large", dataLen)
+	}
+
+	body := make([]byte, typeLen+dataLen)
+	if _, err := s.file.ReadAt(body, offset+spoolHeaderSize); err != nil {
+		return Message{}, offset, fmt.Errorf("partial message: %w", err)
+	}
+	if crc32.Update(crc32.ChecksumIEEE(header[4:]), crc32.IEEETable, body) != binary.BigEndian.Uint32(header) {
+		return Message{}, offset, errors.New("message checksum mismatch")
+	}
+
+	message := Message{
+		contentType: string(body[:typeLen]),
+		data:        body[typeLen:],
+	}
+
+	return message, offset + spoolHeaderSize + int64(len(body)), nil
+}
+
+// Remove messages up to the offset. The file is emptied once everything has been acknowledged.
+func (s *spool) ack(offset int64) error {
+	s.offset = offset
+
+	if s.empty() {
+		if err := s.file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate spool: %w", err)
+		}
+		s.offset = 0
+		s.size = 0
+	}
+
+	if err := s.writeOffset(); err != nil {
+		return fmt.Errorf("failed to write spool offset: %w", err)
+	}
+
+	return nil
+}
+
+func (s *spool) close() error {
+	return s.file.Close()
+}
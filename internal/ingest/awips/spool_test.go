@@ -0,0 +1,103 @@
+package awips
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.empty() {
+		t.Fatal("expected a new spool to be empty")
+	}
+
+	for i := range 3 {
+		if err := s.append(Message{"text/plain", []byte(fmt.Sprintf("product %d", i)), "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Acknowledge the first message then reopen as if restarting
+	message, next, err := s.peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(message.data) != "product 0" || message.contentType != "text/plain" {
+		t.Errorf("unexpected first message %s %q", message.contentType, message.data)
+	}
+	if err := s.ack(next); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+
+	s, err = openSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	for i := 1; i < 3; i++ {
+		message, next, err := s.peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := fmt.Sprintf("product %d", i); string(message.data) != expected {
+			t.Errorf("expected %q, got %q", expected, message.data)
+		}
+		if err := s.ack(next); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := s.peek(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the spool to be empty, got %v", err)
+	}
+	if stat, _ := os.Stat(filepath.Join(dir, spoolFile)); stat.Size() != 0 {
+		t.Errorf("expected the spool file to be truncated, got %d bytes", stat.Size())
+	}
+}
+
+func TestSpoolTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.append(Message{"text/plain", []byte("complete"), "test"}); err != nil {
+		t.Fatal(err)
+	}
+	size := s.size
+	if err := s.append(Message{"text/plain", []byte("partial"), "test"}); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+
+	// Cut the second message short
+	if err := os.Truncate(filepath.Join(dir, spoolFile), size+spoolHeaderSize+2); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = openSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	if s.size != size {
+		t.Errorf("expected the partial message to be discarded, got size %d", s.size)
+	}
+	message, _, err := s.peek()
+	if err != nil || string(message.data) != "complete" {
+		t.Errorf("expected the complete message, got %q %v", message.data, err)
+	}
+}
@@ -3,121 +3,286 @@ package awips
 import (
 	"context"
 	"crypto/tls"
+	"encoding/xml"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
+	"net"
+	"strings"
 	"time"
 
+	"github.com/metdatasystem/us/shared/streaming"
 	"github.com/rs/zerolog/log"
 	"mellium.im/sasl"
+	"mellium.im/xmlstream"
 	"mellium.im/xmpp"
 	"mellium.im/xmpp/dial"
 	"mellium.im/xmpp/jid"
 	"mellium.im/xmpp/muc"
 	"mellium.im/xmpp/mux"
+	"mellium.im/xmpp/ping"
+	"mellium.im/xmpp/stanza"
 )
 
-func X() {
-	fmt.Println("yeah")
+// NWWS-OI carries the product in an x element of this namespace in each groupchat message
+const nwwsNS = "nwws-oi"
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+// The NWWS-OI x element
+type nwwsProduct struct {
+	XMLName xml.Name `xml:"nwws-oi x"`
+	Issue   string   `xml:"issue,attr"`
+	TTAAII  string   `xml:"ttaaii,attr"`
+	CCCC    string   `xml:"cccc,attr"`
+	AWIPSID string   `xml:"awipsid,attr"`
+	ID      string   `xml:"id,attr"`
+	Text    string   `xml:",chardata"`
+}
+
+// Convert the product to the raw message shared with the parse service
+func (product *nwwsProduct) raw(received time.Time) streaming.AWIPSRaw {
+	issued, err := time.Parse("2006-01-02T15:04:05Z", product.Issue)
+	if err != nil {
+		log.Error().Err(err).Str("issue", product.Issue).Msg("failed to parse x element issue time")
+		issued = received.UTC()
+	}
+
+	return streaming.AWIPSRaw{
+		Issued: issued,
+		TTAAII: product.TTAAII,
+		CCCC:   product.CCCC,
+		AWIPS:  product.AWIPSID,
+		Text:   normaliseText(product.Text),
+	}
+}
+
+// Remove the extra newlines products carry so every source shares the same text
+func normaliseText(text string) string {
+	return strings.ReplaceAll(text, "\n\n", "\n")
+}
+
+// An XMPP session joined to the NWWS room.
+//
+// The room is joined here rather than with muc.Client, whose presence handler and Join race on
+// the channel they share.
+type nwwsSession struct {
+	session *xmpp.Session
+	room    jid.JID
+	// Receives our own presence from the room, or the error the room sent instead
+	joins  chan error
+	joined bool
+	// Receives the error once the session stops being served
+	served chan error
+}
+
+// Close leaves the room and closes the session
+func (s *nwwsSession) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if s.joined {
+		leave := stanza.Presence{To: s.room, Type: stanza.UnavailablePresence}
+		if err := s.session.Send(ctx, leave.Wrap(nil)); err != nil {
+			log.Debug().Err(err).Msg("failed to leave NWWS room")
+		}
+	}
+	if err := s.session.Close(); err != nil {
+		log.Debug().Err(err).Msg("failed to close NWWS session")
+	}
+	if err := s.session.Conn().Close(); err != nil {
+		log.Debug().Err(err).Msg("failed to close NWWS connection")
+	}
+}
+
+// The JID we log in with
+func (conf *XmppConfig) jid() (jid.JID, error) {
+	return jid.New(conf.User, conf.serverName(), conf.Resource)
+}
+
+// The room JID uses our user as the nickname
+func (conf *XmppConfig) roomJID() (jid.JID, error) {
+	return jid.New(conf.Resource, conf.Room, conf.User)
+}
 
-	xmppConfig := XmppConfig{
-		Server:   os.Getenv("NWWSOI_SERVER"),
-		Room:     os.Getenv("NWWSOI_ROOM"),
-		User:     os.Getenv("NWWSOI_USER"),
-		Pass:     os.Getenv("NWWSOI_PASS"),
-		Resource: os.Getenv("NWWSOI_RESOURCE"),
+func (conf *XmppConfig) tlsConfig() *tls.Config {
+	return &tls.Config{
+		ServerName: conf.serverName(),
+		MinVersion: tls.VersionTLS12,
 	}
-	err := xmppConfig.check()
+}
+
+// A context for a call on the session that is only ever done by timing out. mellium clears the
+// connection deadline from a goroutine once the context of a call is done, and that goroutine can
+// still be waiting after the call returns, so cancelling the context then fails the next write.
+func sessionTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	time.AfterFunc(timeout, cancel)
+	return ctx
+}
+
+// Dial the NWWS server over TCP
+func dialNWWS(ctx context.Context, conf XmppConfig) (net.Conn, error) {
+	j, err := conf.jid()
 	if err != nil {
-		log.Error().Err(err).Msg("NWWS configuration is invalid")
-		return
+		return nil, fmt.Errorf("failed to parse jid: %w", err)
 	}
 
-	j, err := jid.Parse(fmt.Sprintf("%s@%s", xmppConfig.User, xmppConfig.Server))
+	return dial.Client(ctx, "tcp", j)
+}
+
+// Dial, authenticate and join the room without history so products are only received once
+func (nwws *nwwsClient) connect(ctx context.Context) (*nwwsSession, error) {
+	origin, err := nwws.config.jid()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jid: %w", err)
+	}
+	room, err := nwws.config.roomJID()
 	if err != nil {
-		log.Error().Err(err).Msg("failed to parse jid")
-		return
-	}
-
-	// ctx, cancel := context.WithCancel(context.Background())
-	// defer cancel()
-
-	// dialCtx, dialCtxCancel := context.WithTimeout(ctx, 30*time.Second)
-	// session, err := xmpp.DialClientSession(
-	// 	dialCtx, j,
-	// 	xmpp.BindResource(),
-	// 	xmpp.StartTLS(&tls.Config{
-	// 		ServerName: j.Domain().String(),
-	// 		MinVersion: tls.VersionTLS12,
-	// 	}),
-	// 	xmpp.SASL("", xmppConfig.Pass, sasl.ScramSha1Plus, sasl.ScramSha1, sasl.Plain),
-	// )
-	// dialCtxCancel()
-	// if err != nil {
-	// 	log.Error().Err(err).Msg("failed to create client session")
-	// }
-
-	// defer func() {
-	// 	if err := session.Close(); err != nil {
-	// 		log.Error().Err(err).Msg("failed to close session")
-	// 	}
-	// 	if err := session.Conn().Close(); err != nil {
-	// 		log.Error().Err(err).Msg("failed to close connection")
-	// 	}
-	// }()
-
-	dialCtx, dialCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer dialCancel()
-
-	log.Info().Msg("dialling tcp...")
-
-	conn, err := dial.Client(dialCtx, "tcp", j)
+		return nil, fmt.Errorf("failed to parse room jid: %w", err)
+	}
+
+	dialCtx := sessionTimeout(ctx, nwwsDialTimeout)
+
+	conn, err := nwws.dial(dialCtx, nwws.config)
 	if err != nil {
-		log.Error().Err(err).Msg("could not dial tcp")
-		return
+		return nil, fmt.Errorf("failed to dial: %w", err)
 	}
 
-	negotiator := xmpp.NewNegotiator(func(s *xmpp.Session, sc *xmpp.StreamConfig) xmpp.StreamConfig {
+	session, err := xmpp.NewSession(dialCtx, origin.Domain(), origin, conn, 0, xmpp.NewNegotiator(func(*xmpp.Session, *xmpp.StreamConfig) xmpp.StreamConfig {
 		return xmpp.StreamConfig{
 			Features: []xmpp.StreamFeature{
 				xmpp.BindResource(),
-				xmpp.StartTLS(&tls.Config{
-					ServerName: j.Domain().String(),
-					MinVersion: tls.VersionTLS12,
-				}),
-				xmpp.SASL(xmppConfig.User, xmppConfig.Pass,
+				xmpp.StartTLS(nwws.tls),
+				xmpp.SASL("", nwws.config.Pass,
 					sasl.ScramSha256Plus, sasl.ScramSha256,
 					sasl.ScramSha1Plus, sasl.ScramSha1,
 					sasl.Plain,
 				),
 			},
 		}
-	})
+	}))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to negotiate session: %w", err)
+	}
 
-	log.Info().Msg("attempting to connect")
+	log.Info().Msgf("connected to %s", nwws.config.Server)
 
-	session, err := xmpp.NewSession(dialCtx, j.Domain(), j, conn, 0, negotiator)
-	dialCancel()
-	if err != nil {
-		log.Error().Err(err).Msg("failed to create session")
-		return
+	s := &nwwsSession{
+		session: session,
+		room:    room,
+		joins:   make(chan error, 1),
+		served:  make(chan error, 1),
 	}
 
-	mucClient := &muc.Client{}
-	muxx := mux.New("jabber:client", muc.HandleClient(mucClient))
+	handler := mux.New(stanza.NSClient,
+		mux.PresenceFunc(stanza.AvailablePresence, xml.Name{Space: muc.NSUser, Local: "x"}, s.handleJoin),
+		mux.PresenceFunc(stanza.ErrorPresence, xml.Name{Local: "error"}, s.handleJoinError),
+		ping.Handle(),
+		mux.MessageFunc(stanza.GroupChatMessage, xml.Name{Space: nwwsNS, Local: "x"}, nwws.handleMessage),
+	)
 	go func() {
-		err := session.Serve(muxx)
+		s.served <- session.Serve(handler)
+	}()
+
+	if err := s.join(sessionTimeout(ctx, nwwsDialTimeout)); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to join room %s: %w", room, err)
+	}
+
+	log.Info().Msgf("joined %s", room)
+
+	return s, nil
+}
+
+// Ask to join the room without history and wait for the room to send our own presence back
+func (s *nwwsSession) join(ctx context.Context) error {
+	history := xml.StartElement{
+		Name: xml.Name{Local: "history"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "maxstanzas"}, Value: "0"}},
+	}
+	x := xmlstream.Wrap(xmlstream.Wrap(nil, history), xml.StartElement{Name: xml.Name{Space: muc.NS, Local: "x"}})
+
+	if err := s.session.Send(ctx, stanza.Presence{To: s.room}.Wrap(x)); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-s.joins:
 		if err != nil {
-			log.Error().Err(err).Msg("failed to serve")
+			return err
 		}
-	}()
+		s.joined = true
+		return nil
+	case err := <-s.served:
+		// Put it back for whoever waits on the session next
+		s.served <- err
+		return fmt.Errorf("session ended: %w", err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Our own presence from the room, which means we have joined. The room marks it with status 110
+// as it may have changed our nickname.
+func (s *nwwsSession) handleJoin(p stanza.Presence, t xmlstream.TokenReadEncoder) error {
+	if !p.From.Bare().Equal(s.room.Bare()) {
+		return nil
+	}
+
+	presence := struct {
+		stanza.Presence
+		Status []struct {
+			Code int `xml:"code,attr"`
+		} `xml:"http://jabber.org/protocol/muc#user x>status"`
+	}{}
+	if err := xml.NewTokenDecoder(t).Decode(&presence); err != nil {
+		return err
+	}
+
+	self := p.From.Equal(s.room)
+	for _, status := range presence.Status {
+		self = self || status.Code == 110
+	}
+	if self {
+		select {
+		case s.joins <- nil:
+		default:
+		}
+	}
+	return nil
+}
+
+// The room refused to let us join
+func (s *nwwsSession) handleJoinError(p stanza.Presence, t xmlstream.TokenReadEncoder) error {
+	if !p.From.Bare().Equal(s.room.Bare()) {
+		return nil
+	}
+
+	presence := struct {
+		stanza.Presence
+		Error stanza.Error `xml:"error"`
+	}{}
+	if err := xml.NewTokenDecoder(t).Decode(&presence); err != nil {
+		return err
+	}
+
+	select {
+	case s.joins <- presence.Error:
+	default:
+	}
+	return nil
+}
+
+// Handle a groupchat message carrying a product
+func (nwws *nwwsClient) handleMessage(msg stanza.Message, t xmlstream.TokenReadEncoder) error {
+	message := struct {
+		stanza.Message
+		Product nwwsProduct `xml:"nwws-oi x"`
+	}{}
+	if err := xml.NewTokenDecoder(t).Decode(&message); err != nil {
+		log.Error().Err(err).Msg("failed to decode NWWS message")
+		return nil
+	}
 
-	log.Info().Msg("yes?")
+	nwws.produce(&message.Product)
 
-	<-ctx.Done()
-	log.Info().Msg("shutting down")
+	return nil
 }
@@ -2,6 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,6 +22,8 @@ var (
 	PingPeriod = (PongWait * 9) / 10
 	// Maximum message size allowed from peer.
 	MaxMessageSize int64 = 64 * 1024
+	// Longest a poll waits for something to be sent.
+	PollTimeout = 30 * time.Second
 )
 
 const (
@@ -28,6 +35,13 @@ type subscription struct {
 	client *client
 	Type   string   `json:"type"`
 	Topics []string `json:"topics"`
+	// Only used by the warnings topic
+	Filter *warningFilter `json:"filter,omitempty"`
+	// The sequence number of the last envelope received, to be sent what was missed instead
+	// of a snapshot. Sequence numbers are per topic, so resume one topic at a time.
+	Since *uint64 `json:"since,omitempty"`
+	// The epoch of the last envelope received. A snapshot is sent when it is not the current one.
+	Epoch string `json:"epoch,omitempty"`
 }
 
 type client struct {
@@ -47,6 +61,62 @@ func NewClient(ws *websocket.Conn, hub *Hub) *client {
 	}
 }
 
+// A client for an HTTP request rather than a WebSocket. Whatever serves the request reads from send.
+func newHTTPClient(hub *Hub) *client {
+	return &client{
+		send:          make(chan []byte),
+		hub:           hub,
+		subscriptions: map[string]struct{}{},
+	}
+}
+
+// Read the subscriptions for an HTTP request from its query. Topics are separated by commas and
+// the filter parameters are those of [warningFilterFromQuery]. since is either one sequence
+// number for every topic or topic:sequence pairs, such as warnings:120,mcds:4, and epoch is the
+// epoch of the envelopes they came from.
+func subscriptionsFromQuery(query url.Values) ([]*subscription, error) {
+	topics := queryList(query, "topics")
+	if len(topics) == 0 {
+		return nil, errors.New("no topics")
+	}
+
+	filter, err := warningFilterFromQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	since := map[string]uint64{}
+	for _, v := range queryList(query, "since") {
+		topic, sequence, ok := strings.Cut(v, ":")
+		if !ok {
+			topic, sequence = "", v
+		}
+		n, err := strconv.ParseUint(sequence, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since: %s", v)
+		}
+		since[topic] = n
+	}
+
+	subscriptions := []*subscription{}
+	for _, topic := range topics {
+		s := &subscription{
+			Type:   SUBSCRIBE,
+			Topics: []string{topic},
+			Filter: filter,
+			Epoch:  query.Get("epoch"),
+		}
+		if n, ok := since[topic]; ok {
+			s.Since = &n
+		} else if n, ok := since[""]; ok {
+			s.Since = &n
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, nil
+}
+
 func (c *client) close() {
 	if !c.closed {
 		if err := c.ws.Close(); err != nil {
@@ -81,6 +151,12 @@ func (c *client) listenRead() {
 			log.Error().Err(err).Str("data", string(message)).Msg("invalid data sent for subscription")
 			continue
 		}
+		if s.Filter != nil {
+			if err := s.Filter.prepare(); err != nil {
+				log.Error().Err(err).Str("data", string(message)).Msg("invalid filter sent for subscription")
+				continue
+			}
+		}
 		c.hub.subscription <- s
 	}
 }
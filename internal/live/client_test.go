@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionsFromQuery(t *testing.T) {
+	query, err := url.ParseQuery("topics=warnings,mcds&topics=lsrs&since=warnings:12,7&epoch=lx3k&state=ok&phenomena=TO.W,SV.W&point=-97.4,35.2&radius=20")
+	require.NoError(t, err)
+
+	subscriptions, err := subscriptionsFromQuery(query)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 3)
+
+	topics := []string{}
+	since := []uint64{}
+	for _, s := range subscriptions {
+		assert.Equal(t, SUBSCRIBE, s.Type)
+		require.Len(t, s.Topics, 1)
+		topics = append(topics, s.Topics[0])
+		require.NotNil(t, s.Since)
+		since = append(since, *s.Since)
+		assert.Equal(t, "lx3k", s.Epoch)
+	}
+	assert.Equal(t, []string{"warnings", "mcds", "lsrs"}, topics)
+	assert.Equal(t, []uint64{12, 7, 7}, since)
+
+	filter := subscriptions[0].Filter
+	require.NotNil(t, filter)
+	assert.Equal(t, []string{"OK"}, filter.State)
+	assert.Equal(t, []string{"TO.W", "SV.W"}, filter.Phenomena)
+	assert.Equal(t, []float64{-97.4, 35.2}, filter.Point)
+	assert.NotNil(t, filter.fence)
+}
+
+func TestSubscriptionsFromQueryInvalid(t *testing.T) {
+	for _, q := range []string{
+		"",
+		"topics=warnings&since=latest",
+		"topics=warnings&pds=maybe",
+		"topics=warnings&point=-97.4",
+		"topics=warnings&bbox=-98,north,-97,36",
+	} {
+		query, err := url.ParseQuery(q)
+		require.NoError(t, err)
+
+		_, err = subscriptionsFromQuery(query)
+		assert.Error(t, err, q)
+	}
+}
+
+func TestSubscriptionsFromQueryWithoutFilter(t *testing.T) {
+	subscriptions, err := subscriptionsFromQuery(url.Values{"topics": {"warnings"}})
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	assert.Nil(t, subscriptions[0].Filter)
+	assert.Nil(t, subscriptions[0].Since)
+}
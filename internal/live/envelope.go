@@ -18,4 +18,10 @@ type Envelope struct {
 	ID        string          `json:"id"`
 	Timestamp time.Time       `json:"timestamp"`
 	Data      json.RawMessage `json:"data"`
+	// Increases with each envelope sent on the topic. INIT envelopes carry the sequence number
+	// of the last envelope sent before the snapshot.
+	Sequence uint64 `json:"sequence"`
+	// Identifies the process numbering the envelopes, so sequence numbers from before a restart
+	// are not mistaken for current ones
+	Epoch string `json:"epoch"`
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Narrows the warnings a subscriber receives. Each list that is set must match and an empty
+// filter matches every warning.
+type warningFilter struct {
+	// Issuing offices, such as KOUN or OUN
+	WFO []string `json:"wfo,omitempty"`
+	// Two letter state codes taken from the warning's UGC
+	State []string `json:"state,omitempty"`
+	// UGC codes, such as OKC109 or OKZ025
+	UGC []string `json:"ugc,omitempty"`
+	// Phenomena with an optional significance, such as TO.W or SV
+	Phenomena []string `json:"phenomena,omitempty"`
+	// Only tornado and flash flood emergencies
+	Emergency bool `json:"emergency,omitempty"`
+	// Only particularly dangerous situations
+	PDS bool `json:"pds,omitempty"`
+	// A point as longitude and latitude, with how many kilometres around it
+	Point  []float64 `json:"point,omitempty"`
+	Radius float64   `json:"radius,omitempty"`
+	// A box as west, south, east and north
+	BBox []float64 `json:"bbox,omitempty"`
+	// A GeoJSON Polygon or MultiPolygon
+	Polygon json.RawMessage `json:"polygon,omitempty"`
+
+	fence *geofence
+}
+
+// Upper case and trim the filter values so matching does not have to, and build the geofence
+func (f *warningFilter) prepare() error {
+	for _, list := range [][]string{f.WFO, f.State, f.UGC, f.Phenomena} {
+		for i, v := range list {
+			list[i] = strings.ToUpper(strings.TrimSpace(v))
+		}
+	}
+
+	fence, err := newGeofence(f)
+	if err != nil {
+		return err
+	}
+	f.fence = fence
+
+	return nil
+}
+
+// Read a filter from query parameters of the same names, with lists and coordinates separated
+// by commas. Returns nil when there are none.
+func warningFilterFromQuery(query url.Values) (*warningFilter, error) {
+	f := &warningFilter{
+		WFO:       queryList(query, "wfo"),
+		State:     queryList(query, "state"),
+		UGC:       queryList(query, "ugc"),
+		Phenomena: queryList(query, "phenomena"),
+	}
+	set := len(f.WFO) > 0 || len(f.State) > 0 || len(f.UGC) > 0 || len(f.Phenomena) > 0
+
+	for name, value := range map[string]*bool{"emergency": &f.Emergency, "pds": &f.PDS} {
+		if v := query.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", name, v)
+			}
+			*value = b
+			set = true
+		}
+	}
+
+	for name, value := range map[string]*[]float64{"point": &f.Point, "bbox": &f.BBox} {
+		for _, v := range queryList(query, name) {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %s", name, query.Get(name))
+			}
+			*value = append(*value, n)
+			set = true
+		}
+	}
+
+	if v := query.Get("radius"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid radius: %s", v)
+		}
+		f.Radius = n
+		set = true
+	}
+
+	if v := query.Get("polygon"); v != "" {
+		f.Polygon = json.RawMessage(v)
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	if err := f.prepare(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// The values of a query parameter, which may be repeated or separated by commas
+func queryList(query url.Values, name string) []string {
+	values := []string{}
+	for _, value := range query[name] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// Whether the warning passes the filter. A nil filter passes everything.
+func (f *warningFilter) matches(w *warning) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.Emergency && !w.IsEmergency {
+		return false
+	}
+	if f.PDS && !w.IsPDS {
+		return false
+	}
+
+	if len(f.WFO) > 0 && !anyOf(f.WFO, func(wfo string) bool {
+		// Allow the three letter identifier without the ICAO prefix
+		return wfo == w.WFO || (len(w.WFO) == 4 && wfo == w.WFO[1:])
+	}) {
+		return false
+	}
+
+	if len(f.Phenomena) > 0 && !anyOf(f.Phenomena, func(p string) bool {
+		phenomena, significance, ok := strings.Cut(p, ".")
+		return phenomena == w.Phenomena && (!ok || significance == w.Significance)
+	}) {
+		return false
+	}
+
+	if len(f.State) > 0 && !anyOf(f.State, func(state string) bool {
+		for code := range w.UGC {
+			if strings.HasPrefix(code, state) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+
+	if len(f.UGC) > 0 && !anyOf(f.UGC, func(code string) bool {
+		_, ok := w.UGC[code]
+		return ok
+	}) {
+		return false
+	}
+
+	// The geometry test is the most expensive so is left for last
+	if f.fence != nil && !f.fence.intersects(w.areas()) {
+		return false
+	}
+
+	return true
+}
+
+// Whether any of the warnings pass the filter
+func (f *warningFilter) matchesAny(warnings []*warning) bool {
+	for _, w := range warnings {
+		if f.matches(w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether any of the values satisfy the test
+func anyOf(values []string, test func(string) bool) bool {
+	for _, v := range values {
+		if test(v) {
+			return true
+		}
+	}
+	return false
+}
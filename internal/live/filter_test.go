@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWarning() *warning {
+	return &warning{
+		ID:           1,
+		WFO:          "KOUN",
+		Phenomena:    "TO",
+		Significance: "W",
+		EventNumber:  12,
+		Year:         2025,
+		IsPDS:        true,
+		UGC: map[string]UGC{
+			"OKC109": {Code: "OKC109", State: "OK"},
+			"OKC027": {Code: "OKC027", State: "OK"},
+		},
+	}
+}
+
+func TestWarningFilterMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *warningFilter
+		matches bool
+	}{
+		{"nil", nil, true},
+		{"empty", &warningFilter{}, true},
+		{"wfo", &warningFilter{WFO: []string{"KTSA", "KOUN"}}, true},
+		{"wfo without prefix", &warningFilter{WFO: []string{"OUN"}}, true},
+		{"other wfo", &warningFilter{WFO: []string{"KTSA"}}, false},
+		{"state", &warningFilter{State: []string{"OK"}}, true},
+		{"other state", &warningFilter{State: []string{"TX"}}, false},
+		{"ugc", &warningFilter{UGC: []string{"OKC027"}}, true},
+		{"other ugc", &warningFilter{UGC: []string{"OKC017"}}, false},
+		{"phenomena", &warningFilter{Phenomena: []string{"TO"}}, true},
+		{"phenomena and significance", &warningFilter{Phenomena: []string{"SV.W", "TO.W"}}, true},
+		{"other significance", &warningFilter{Phenomena: []string{"TO.A"}}, false},
+		{"pds", &warningFilter{PDS: true}, true},
+		{"emergency", &warningFilter{Emergency: true}, false},
+		{"every list must match", &warningFilter{State: []string{"OK"}, Phenomena: []string{"SV.W"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.matches, test.filter.matches(testWarning()))
+		})
+	}
+}
+
+func TestWarningFilterPrepare(t *testing.T) {
+	s := &subscription{}
+	err := json.Unmarshal([]byte(`{"type":"SUBSCRIBE","topics":["warnings"],"filter":{"state":[" ok"],"phenomena":["to.w"]}}`), s)
+	require.NoError(t, err)
+	require.NotNil(t, s.Filter)
+
+	require.NoError(t, s.Filter.prepare())
+	assert.Equal(t, []string{"OK"}, s.Filter.State)
+	assert.Equal(t, []string{"TO.W"}, s.Filter.Phenomena)
+	assert.True(t, s.Filter.matches(testWarning()))
+}
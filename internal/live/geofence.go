@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/geojson"
+	"github.com/twpayne/go-geom/xy"
+)
+
+// Kilometres in a degree of latitude
+const kmPerDegree = 111.2
+
+// An area a subscriber wants warnings for
+type geofence struct {
+	bounds *geom.Bounds
+	// Set for a point and radius
+	center geom.Coord
+	radius float64
+	// Set for a box or polygon
+	area *geom.MultiPolygon
+}
+
+// Build the geofence from the filter. Returns nil when the filter does not have one.
+func newGeofence(f *warningFilter) (*geofence, error) {
+	set := 0
+	for _, ok := range []bool{f.Point != nil, f.BBox != nil, len(f.Polygon) > 0} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		if f.Radius != 0 {
+			return nil, errors.New("radius needs a point")
+		}
+		return nil, nil
+	case set > 1:
+		return nil, errors.New("only one of point, bbox or polygon can be used")
+	}
+
+	switch {
+	case f.Point != nil:
+		if len(f.Point) != 2 || !validLonLat(f.Point[0], f.Point[1]) {
+			return nil, errors.New("point must be a longitude and latitude")
+		}
+		if f.Radius < 0 || f.Radius > 1000 {
+			return nil, errors.New("radius must be between 0 and 1000 km")
+		}
+		lon, lat := f.Point[0], f.Point[1]
+		dLat := f.Radius / kmPerDegree
+		dLon := f.Radius / (kmPerDegree * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+		return &geofence{
+			bounds: geom.NewBounds(geom.XY).Set(lon-dLon, lat-dLat, lon+dLon, lat+dLat),
+			center: geom.Coord{lon, lat},
+			radius: f.Radius,
+		}, nil
+	case f.BBox != nil:
+		if len(f.BBox) != 4 || !validLonLat(f.BBox[0], f.BBox[1]) || !validLonLat(f.BBox[2], f.BBox[3]) ||
+			f.BBox[0] >= f.BBox[2] || f.BBox[1] >= f.BBox[3] {
+			return nil, errors.New("bbox must be west, south, east and north")
+		}
+		bounds := geom.NewBounds(geom.XY).Set(f.BBox...)
+		area, err := geom.NewMultiPolygon(geom.XY).SetCoords([][][]geom.Coord{bounds.Polygon().Coords()})
+		if err != nil {
+			return nil, err
+		}
+		return &geofence{bounds: bounds, area: area}, nil
+	default:
+		var g geom.T
+		if err := geojson.Unmarshal(f.Polygon, &g); err != nil {
+			return nil, fmt.Errorf("invalid polygon: %v", err.Error())
+		}
+		area, err := toMultiPolygon(g)
+		if err != nil {
+			return nil, err
+		}
+		if area.NumPolygons() == 0 {
+			return nil, errors.New("polygon is empty")
+		}
+		return &geofence{bounds: area.Bounds(), area: area}, nil
+	}
+}
+
+func validLonLat(lon float64, lat float64) bool {
+	return lon >= -180 && lon <= 180 && lat >= -90 && lat <= 90
+}
+
+// Only keep the first two dimensions of a polygon or multipolygon
+func toMultiPolygon(g geom.T) (*geom.MultiPolygon, error) {
+	var coords [][][]geom.Coord
+	switch g := g.(type) {
+	case *geom.Polygon:
+		coords = [][][]geom.Coord{g.Coords()}
+	case *geom.MultiPolygon:
+		coords = g.Coords()
+	default:
+		return nil, errors.New("polygon must be a Polygon or MultiPolygon")
+	}
+
+	for _, polygon := range coords {
+		for _, ring := range polygon {
+			if len(ring) < 4 {
+				return nil, errors.New("polygon rings need at least four points")
+			}
+			for i, c := range ring {
+				ring[i] = c[:2]
+			}
+		}
+	}
+
+	return geom.NewMultiPolygon(geom.XY).SetCoords(coords)
+}
+
+// Whether any of the areas are within the geofence
+func (fence *geofence) intersects(areas []*geom.MultiPolygon) bool {
+	for _, area := range areas {
+		if area == nil || !fence.bounds.Overlaps(geom.XY, area.Bounds()) {
+			continue
+		}
+		if fence.area != nil {
+			if multiPolygonsIntersect(fence.area, area) {
+				return true
+			}
+		} else if distanceKm(fence.center, area) <= fence.radius {
+			return true
+		}
+	}
+	return false
+}
+
+// How far the point is from the area in kilometres, zero when it is inside
+func distanceKm(p geom.Coord, area *geom.MultiPolygon) float64 {
+	if inMultiPolygon(p, area) {
+		return 0
+	}
+
+	// Measure in a plane around the point, which is close enough over the distances allowed
+	scale := math.Cos(p[1] * math.Pi / 180)
+	project := func(c geom.Coord) geom.Coord {
+		return geom.Coord{(c[0] - p[0]) * scale * kmPerDegree, (c[1] - p[1]) * kmPerDegree}
+	}
+
+	origin := geom.Coord{0, 0}
+	nearest := math.Inf(1)
+	eachSegment(area, func(a, b geom.Coord) bool {
+		nearest = math.Min(nearest, xy.DistanceFromPointToLine(origin, project(a), project(b)))
+		return true
+	})
+	return nearest
+}
+
+// Whether the point is inside one of the polygons and outside its holes
+func inMultiPolygon(p geom.Coord, area *geom.MultiPolygon) bool {
+	for i := 0; i < area.NumPolygons(); i++ {
+		polygon := area.Polygon(i)
+		if polygon.NumLinearRings() == 0 || !xy.IsPointInRing(geom.XY, p, polygon.LinearRing(0).FlatCoords()) {
+			continue
+		}
+		inHole := false
+		for j := 1; j < polygon.NumLinearRings(); j++ {
+			if xy.IsPointInRing(geom.XY, p, polygon.LinearRing(j).FlatCoords()) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the areas share any point: their edges cross or one holds a point of the other
+func multiPolygonsIntersect(a *geom.MultiPolygon, b *geom.MultiPolygon) bool {
+	if p := firstPoint(a); p != nil && inMultiPolygon(p, b) {
+		return true
+	}
+	if p := firstPoint(b); p != nil && inMultiPolygon(p, a) {
+		return true
+	}
+
+	crosses := false
+	eachSegment(a, func(a1, a2 geom.Coord) bool {
+		eachSegment(b, func(b1, b2 geom.Coord) bool {
+			crosses = segmentsIntersect(a1, a2, b1, b2)
+			return !crosses
+		})
+		return !crosses
+	})
+	return crosses
+}
+
+func firstPoint(area *geom.MultiPolygon) geom.Coord {
+	flat := area.FlatCoords()
+	if len(flat) < 2 {
+		return nil
+	}
+	return geom.Coord{flat[0], flat[1]}
+}
+
+// Call the function with each edge of the area until it returns false
+func eachSegment(area *geom.MultiPolygon, f func(a, b geom.Coord) bool) {
+	for i := 0; i < area.NumPolygons(); i++ {
+		polygon := area.Polygon(i)
+		for j := 0; j < polygon.NumLinearRings(); j++ {
+			ring := polygon.LinearRing(j)
+			for k := 1; k < ring.NumCoords(); k++ {
+				if !f(ring.Coord(k-1), ring.Coord(k)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func segmentsIntersect(a1, a2, b1, b2 geom.Coord) bool {
+	cross := func(o, a, b geom.Coord) float64 {
+		return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+	}
+	d1 := cross(b1, b2, a1)
+	d2 := cross(b1, b2, a2)
+	d3 := cross(a1, a2, b1)
+	d4 := cross(a1, a2, b2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	// Touching or collinear
+	onSegment := func(p, a, b geom.Coord) bool {
+		return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+			math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+	}
+	return (d1 == 0 && onSegment(a1, b1, b2)) || (d2 == 0 && onSegment(a2, b1, b2)) ||
+		(d3 == 0 && onSegment(b1, a1, a2)) || (d4 == 0 && onSegment(b2, a1, a2))
+}
+
+// Size of the geofence index cells in degrees
+const geofenceCellSize = 1.0
+
+// Fences covering more cells than this are checked against every warning instead of being indexed
+const geofenceMaxCells = 2500
+
+type geofenceCell [2]int
+
+// A grid of the subscribers with geofences, so a warning is only tested against fences near it
+type geofenceIndex struct {
+	cells map[geofenceCell]map[*client]struct{}
+	// The cells each client is in
+	clients map[*client][]geofenceCell
+	// Clients with fences too large to index
+	wide map[*client]struct{}
+}
+
+func newGeofenceIndex() *geofenceIndex {
+	return &geofenceIndex{
+		cells:   map[geofenceCell]map[*client]struct{}{},
+		clients: map[*client][]geofenceCell{},
+		wide:    map[*client]struct{}{},
+	}
+}
+
+// The cells the bounds cover, or false when there are too many
+func coveredCells(bounds *geom.Bounds) ([]geofenceCell, bool) {
+	minX := int(math.Floor(bounds.Min(0) / geofenceCellSize))
+	minY := int(math.Floor(bounds.Min(1) / geofenceCellSize))
+	maxX := int(math.Floor(bounds.Max(0) / geofenceCellSize))
+	maxY := int(math.Floor(bounds.Max(1) / geofenceCellSize))
+	if (maxX-minX+1)*(maxY-minY+1) > geofenceMaxCells {
+		return nil, false
+	}
+
+	cells := []geofenceCell{}
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			cells = append(cells, geofenceCell{x, y})
+		}
+	}
+	return cells, true
+}
+
+func (index *geofenceIndex) insert(c *client, bounds *geom.Bounds) {
+	index.remove(c)
+
+	cells, ok := coveredCells(bounds)
+	if !ok {
+		index.wide[c] = struct{}{}
+		return
+	}
+
+	for _, cell := range cells {
+		if _, ok := index.cells[cell]; !ok {
+			index.cells[cell] = map[*client]struct{}{}
+		}
+		index.cells[cell][c] = struct{}{}
+	}
+	index.clients[c] = cells
+}
+
+func (index *geofenceIndex) remove(c *client) {
+	delete(index.wide, c)
+	for _, cell := range index.clients[c] {
+		delete(index.cells[cell], c)
+		if len(index.cells[cell]) == 0 {
+			delete(index.cells, cell)
+		}
+	}
+	delete(index.clients, c)
+}
+
+// Add the clients whose fences might overlap the bounds to the set
+func (index *geofenceIndex) search(bounds *geom.Bounds, found map[*client]struct{}) {
+	for c := range index.wide {
+		found[c] = struct{}{}
+	}
+	if bounds == nil || bounds.IsEmpty() {
+		return
+	}
+
+	cells, ok := coveredCells(bounds)
+	if !ok {
+		// Every indexed client is a candidate for something this large
+		for c := range index.clients {
+			found[c] = struct{}{}
+		}
+		return
+	}
+	for _, cell := range cells {
+		for c := range index.cells[cell] {
+			found[c] = struct{}{}
+		}
+	}
+}
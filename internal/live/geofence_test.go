@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-geom"
+)
+
+// A warning polygon around Norman, Oklahoma
+func testPolygon() *geom.MultiPolygon {
+	return geom.NewMultiPolygon(geom.XY).MustSetCoords([][][]geom.Coord{{{
+		{-97.6, 35.1}, {-97.2, 35.1}, {-97.2, 35.4}, {-97.6, 35.4}, {-97.6, 35.1},
+	}}})
+}
+
+func TestGeofence(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     string
+		intersects bool
+	}{
+		{"point inside", `{"point":[-97.44,35.22]}`, true},
+		{"point outside", `{"point":[-97.0,35.22]}`, false},
+		{"radius reaches", `{"point":[-97.0,35.22],"radius":25}`, true},
+		{"radius too small", `{"point":[-97.0,35.22],"radius":10}`, false},
+		{"bbox overlaps", `{"bbox":[-97.3,35.3,-96.0,36.0]}`, true},
+		{"bbox inside", `{"bbox":[-97.5,35.2,-97.3,35.3]}`, true},
+		{"bbox outside", `{"bbox":[-96.5,35.3,-96.0,36.0]}`, false},
+		{"polygon crosses", `{"polygon":{"type":"Polygon","coordinates":[[[-97.4,35.0],[-97.3,35.0],[-97.3,36.0],[-97.4,36.0],[-97.4,35.0]]]}}`, true},
+		{"polygon around", `{"polygon":{"type":"MultiPolygon","coordinates":[[[[-98,34],[-96,34],[-96,36],[-98,36],[-98,34]]]]}}`, true},
+		{"polygon outside", `{"polygon":{"type":"Polygon","coordinates":[[[-96,34],[-95,34],[-95,35],[-96,34]]]}}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := &warningFilter{}
+			require.NoError(t, json.Unmarshal([]byte(test.filter), filter))
+			require.NoError(t, filter.prepare())
+			require.NotNil(t, filter.fence)
+
+			assert.Equal(t, test.intersects, filter.fence.intersects([]*geom.MultiPolygon{testPolygon()}))
+		})
+	}
+}
+
+func TestGeofenceInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"radius":10}`,
+		`{"point":[-97.4]}`,
+		`{"point":[-97.4,35.2],"radius":-1}`,
+		`{"point":[-97.4,35.2],"bbox":[-98,35,-97,36]}`,
+		`{"bbox":[-97,35,-98,36]}`,
+		`{"polygon":{"type":"Point","coordinates":[-97.4,35.2]}}`,
+		`{"polygon":"oklahoma"}`,
+	} {
+		filter := &warningFilter{}
+		require.NoError(t, json.Unmarshal([]byte(data), filter))
+		assert.Error(t, filter.prepare(), data)
+	}
+}
+
+func TestWarningFilterGeofenceUsesUGC(t *testing.T) {
+	filter := &warningFilter{Point: []float64{-97.44, 35.22}}
+	require.NoError(t, filter.prepare())
+
+	w := testWarning()
+	assert.False(t, filter.matches(w), "no geometry to test")
+
+	w.UGC["OKC027"] = UGC{Code: "OKC027", Geom: testPolygon()}
+	assert.True(t, filter.matches(w))
+
+	// The polygon is preferred over the UGC
+	w.Geom = geom.NewMultiPolygon(geom.XY).MustSetCoords([][][]geom.Coord{{{
+		{-96, 34}, {-95, 34}, {-95, 35}, {-96, 34},
+	}}})
+	assert.False(t, filter.matches(w))
+}
+
+func TestGeofenceIndex(t *testing.T) {
+	index := newGeofenceIndex()
+	near, far, wide := &client{}, &client{}, &client{}
+
+	index.insert(near, geom.NewBounds(geom.XY).Set(-97.5, 35.2, -97.4, 35.3))
+	index.insert(far, geom.NewBounds(geom.XY).Set(-80.5, 25.2, -80.4, 25.3))
+	index.insert(wide, geom.NewBounds(geom.XY).Set(-180, -90, 180, 90))
+
+	found := map[*client]struct{}{}
+	index.search(testPolygon().Bounds(), found)
+	assert.Equal(t, map[*client]struct{}{near: {}, wide: {}}, found)
+
+	index.remove(near)
+	found = map[*client]struct{}{}
+	index.search(testPolygon().Bounds(), found)
+	assert.Equal(t, map[*client]struct{}{wide: {}}, found)
+	assert.Empty(t, index.cells[geofenceCell{-98, 35}])
+}
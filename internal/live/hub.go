@@ -8,7 +8,7 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/metdatasystem/us/shared/streaming"
 	"github.com/rs/zerolog/log"
 )
 
@@ -18,14 +18,14 @@ type Hub struct {
 	register        chan *client
 	unregister      chan *client
 	subscription    chan *subscription
-	inboundMessages chan *amqp.Delivery
+	inboundMessages chan *streaming.Delivery
 
 	connections map[*client]bool
 	managers    map[string]Manager
 
 	wsUpgrader websocket.Upgrader
 	db         *pgxpool.Pool
-	rabbit     *amqp.Channel
+	stream     streaming.Transport
 	ugcStore   *UGCStore
 }
 
@@ -36,12 +36,7 @@ func NewHub() (*Hub, error) {
 		return nil, err
 	}
 
-	rabbit, err := newRabbitChannel()
-	if err != nil {
-		return nil, err
-	}
-
-	err = initRabbit(rabbit)
+	stream, err := newStreamTransport()
 	if err != nil {
 		return nil, err
 	}
@@ -58,11 +53,17 @@ func NewHub() (*Hub, error) {
 			},
 		},
 		db:     dbPool,
-		rabbit: rabbit,
+		stream: stream,
 	}
 
 	hub.ugcStore = NewUGCStore(hub)
 
+	// The managers are attached before anything is served so the map is never written while
+	// requests read it
+	AttachWarningManager(hub)
+	AttachMCDManager(hub)
+	AttachLSRManager(hub)
+
 	return hub, err
 }
 
@@ -100,7 +101,7 @@ func (hub *Hub) subscribeClient(s *subscription) {
 	} else {
 		for _, topic := range s.Topics {
 			if manager, ok := hub.managers[topic]; ok {
-				manager.Subscribe(s.client)
+				manager.Subscribe(s)
 				s.client.subscriptions[topic] = struct{}{}
 				log.Debug().Str("topic", topic).Msg("subscribed to topic")
 			}
@@ -109,6 +110,42 @@ func (hub *Hub) subscribeClient(s *subscription) {
 	}
 }
 
+// Whether there is a manager for the topic
+func (hub *Hub) hasTopic(topic string) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	_, ok := hub.managers[topic]
+	return ok
+}
+
+// Register an HTTP client and make its subscriptions. Something must be reading what is sent
+// to the client as the managers send the initial data straight away.
+func (hub *Hub) subscribeHTTPClient(c *client, subscriptions []*subscription) {
+	hub.registerConnection(c)
+	for _, s := range subscriptions {
+		s.client = c
+		hub.subscribeClient(s)
+	}
+}
+
+// Unregister an HTTP client, discarding anything sent to it until the managers have let it go
+func (hub *Hub) releaseHTTPClient(c *client) {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c.send:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	hub.unregisterConnection(c)
+	close(done)
+}
+
 func (hub *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
@@ -132,8 +169,6 @@ func (hub *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (hub *Hub) run() {
-	AttachWarningManager(hub)
-
 	err := hub.ugcStore.load()
 	if err != nil {
 		log.Error().Err(err).Msg("failed to initialise hub UGC store")
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/rs/zerolog/log"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+	"github.com/twpayne/go-geom/encoding/geojson"
+)
+
+const LSRTopic string = "lsr"
+
+// How long reports are kept after they occurred
+const lsrRetention = 24 * time.Hour
+
+type lsrDTO struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	Product   string    `json:"product"`
+	WFO       string    `json:"wfo"`
+	Valid     time.Time `json:"valid"`
+	Year      int       `json:"year"`
+	Event     string    `json:"event"`
+	Magnitude *float64  `json:"magnitude"`
+	Units     string    `json:"units,omitempty"`
+	Qualifier string    `json:"qualifier,omitempty"`
+	Location  string    `json:"location"`
+	County    string    `json:"county"`
+	State     string    `json:"state"`
+	Source    string    `json:"source"`
+	Remarks   string    `json:"remarks"`
+	Geom      []byte    `json:"geom"`
+}
+
+type lsr struct {
+	ID        int         `json:"id"`
+	LSRID     string      `json:"lsrID"`
+	CreatedAt time.Time   `json:"createdAt,omitzero"`
+	UpdatedAt time.Time   `json:"updatedAt,omitzero"`
+	Product   string      `json:"product"`
+	WFO       string      `json:"wfo"`
+	Valid     time.Time   `json:"valid"`
+	Year      int         `json:"year"`
+	Event     string      `json:"event"`
+	Magnitude *float64    `json:"magnitude"`
+	Units     string      `json:"units,omitempty"`
+	Qualifier string      `json:"qualifier,omitempty"`
+	Location  string      `json:"location"`
+	County    string      `json:"county"`
+	State     string      `json:"state"`
+	Source    string      `json:"source"`
+	Remarks   string      `json:"remarks"`
+	Geom      *geom.Point `json:"geom,omitempty"`
+}
+
+// Generates an ID using the database ID and year of the report.
+//
+// Example: LSR-1234-2025
+func (l *lsr) GenerateID() string {
+	return fmt.Sprintf("LSR-%d-%d", l.ID, l.Year)
+}
+
+func (l *lsr) MarshalJSON() ([]byte, error) {
+	type Alias lsr // Use type alias to avoid recursion
+
+	aux := struct {
+		Alias
+		Geom string `json:"geom,omitempty"`
+	}{
+		Alias: (Alias)(*l),
+	}
+
+	if l.Geom != nil {
+		b, err := geojson.Marshal(l.Geom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal geometry: %v", err.Error())
+		}
+		aux.Geom = string(b)
+	}
+
+	return json.Marshal(aux)
+}
+
+type LSRManager struct {
+	mu sync.Mutex
+
+	hub    *Hub
+	events <-chan streaming.Delivery
+
+	data        map[string]*lsr
+	subscribers map[*client]struct{}
+	replay      *replayBuffer[struct{}]
+
+	ticker *time.Ticker
+}
+
+func AttachLSRManager(hub *Hub) {
+	hub.managers[LSRTopic] = NewLSRManager(hub)
+}
+
+func NewLSRManager(hub *Hub) *LSRManager {
+
+	ticker := time.NewTicker(60 * time.Second)
+
+	return &LSRManager{
+		hub:         hub,
+		data:        map[string]*lsr{},
+		subscribers: map[*client]struct{}{},
+		replay:      newReplayBuffer[struct{}](replayBufferSize),
+		ticker:      ticker,
+	}
+}
+
+func (manager *LSRManager) Load() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	// Subscribe before loading so no events are missed in between
+	events, err := manager.hub.stream.SubscribeLive(context.Background(), "live.lsr", streaming.ProductLSR)
+	if err != nil {
+		return err
+	}
+	manager.events = events
+
+	// Get all the recent reports
+	rows, err := manager.hub.db.Query(context.Background(), `
+	SELECT id, created_at, updated_at, product, wfo, valid, year, event, magnitude::double precision, COALESCE(units, ''),
+	COALESCE(qualifier, ''), location, COALESCE(county, ''), COALESCE(state, ''), COALESCE(source, ''),
+	COALESCE(remarks, ''), geom
+	FROM lsr.reports WHERE valid > $1
+	`, time.Now().Add(-lsrRetention))
+	if err != nil {
+		return fmt.Errorf("failed to get recent lsrs: %v", err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := manager.scanLSR(rows)
+		if err != nil {
+			return err
+		}
+
+		manager.data[l.LSRID] = l
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	log.Debug().Int("size", len(manager.data)).Msg("loaded lsr data")
+
+	return nil
+}
+
+func (manager *LSRManager) Run() {
+	go func() {
+		for {
+			select {
+			case t := <-manager.ticker.C:
+				manager.ticker.Reset(60 * time.Second)
+				manager.checkExpired(t)
+			case message := <-manager.events:
+
+				l := &lsrDTO{}
+
+				if err := json.Unmarshal(message.Body, l); err != nil {
+					log.Error().Err(err).Msg("failed to unmarshal lsr message")
+					continue
+				}
+
+				err := manager.handleUpdate(l, message.Type)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to handle lsr update")
+					continue
+				}
+				message.Ack()
+			}
+		}
+	}()
+}
+
+func (manager *LSRManager) Subscribe(s *subscription) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.subscribers[s.client] = struct{}{}
+
+	// Send only what the client missed when the buffer still has it
+	if s.Since != nil {
+		if entries, ok := manager.replay.since(s.Epoch, *s.Since); ok {
+			for _, entry := range entries {
+				s.client.send <- entry.envelope
+			}
+			log.Debug().Int("size", len(entries)).Uint64("since", *s.Since).Msg("replayed lsr data to client")
+			return
+		}
+	}
+
+	lsrs := []*lsr{}
+	for _, l := range manager.data {
+		lsrs = append(lsrs, l)
+	}
+
+	lsrsBytes, err := json.Marshal(lsrs)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal lsrs for subscription")
+		return
+	}
+
+	envelope := Envelope{
+		Type:      EnvelopeInitial,
+		Product:   LSRTopic,
+		ID:        "",
+		Timestamp: time.Now(),
+		Data:      lsrsBytes,
+		Sequence:  manager.replay.current(),
+		Epoch:     manager.replay.epoch,
+	}
+
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal envelope for subscription")
+		return
+	}
+
+	s.client.send <- envelopeBytes
+
+	log.Debug().Int("size", len(lsrs)).Msg("sent initial lsr data to client")
+}
+
+func (manager *LSRManager) Unsubscribe(c *client) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	delete(manager.subscribers, c)
+}
+
+func (manager *LSRManager) handleUpdate(dto *lsrDTO, eventType string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	l := &lsr{
+		ID:        dto.ID,
+		CreatedAt: dto.CreatedAt,
+		UpdatedAt: dto.UpdatedAt,
+		Product:   dto.Product,
+		WFO:       dto.WFO,
+		Valid:     dto.Valid,
+		Year:      dto.Year,
+		Event:     dto.Event,
+		Magnitude: dto.Magnitude,
+		Units:     dto.Units,
+		Qualifier: dto.Qualifier,
+		Location:  dto.Location,
+		County:    dto.County,
+		State:     dto.State,
+		Source:    dto.Source,
+		Remarks:   dto.Remarks,
+	}
+	l.LSRID = l.GenerateID()
+
+	if len(dto.Geom) > 0 {
+		g, err := ewkb.Unmarshal(dto.Geom)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal lsr geometry: %v", err.Error())
+		}
+
+		point, ok := g.(*geom.Point)
+		if !ok {
+			log.Warn().Str("lsr", l.LSRID).Msg("lsr geometry was not a point")
+		}
+		l.Geom = point
+	}
+
+	var envelopeType string
+	switch eventType {
+	case streaming.EventNew:
+		envelopeType = EnvelopeNew
+	case streaming.EventUpdate:
+		envelopeType = EnvelopeUpdate
+	case streaming.EventDelete:
+		envelopeType = EnvelopeDelete
+	default:
+		return fmt.Errorf("unknown lsr event type %s", eventType)
+	}
+
+	// Summary products carry old reports that are no longer of interest to live clients
+	if envelopeType != EnvelopeDelete && l.Valid.Before(time.Now().Add(-lsrRetention)) {
+		return nil
+	}
+
+	lsrBytes, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	envelope := Envelope{
+		Type:      envelopeType,
+		Product:   LSRTopic,
+		ID:        l.LSRID,
+		Timestamp: time.Now(),
+		Data:      lsrBytes,
+	}
+
+	envelopeBytes, err := manager.replay.record(&envelope, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	for client := range manager.subscribers {
+		client.send <- envelopeBytes
+	}
+
+	if envelopeType == EnvelopeDelete {
+		delete(manager.data, l.LSRID)
+	} else {
+		manager.data[l.LSRID] = l
+	}
+
+	return nil
+}
+
+func (manager *LSRManager) checkExpired(t time.Time) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	toDelete := []*lsr{}
+	for _, l := range manager.data {
+		if l.Valid.Before(t.Add(-lsrRetention)) {
+			toDelete = append(toDelete, l)
+		}
+	}
+
+	if len(toDelete) > 0 {
+		for _, l := range toDelete {
+			delete(manager.data, l.LSRID)
+
+			lsrBytes, err := json.Marshal(l)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to marshal lsr for expired lsr")
+				continue
+			}
+
+			envelope := Envelope{
+				Type:      EnvelopeDelete,
+				Product:   LSRTopic,
+				ID:        l.LSRID,
+				Timestamp: time.Now(),
+				Data:      lsrBytes,
+			}
+
+			envelopeBytes, err := manager.replay.record(&envelope, struct{}{})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to marshal envelope for expired lsr")
+				continue
+			}
+
+			for client := range manager.subscribers {
+				client.send <- envelopeBytes
+			}
+		}
+
+		log.Debug().Int("deleted", len(toDelete)).Msg("deleted expired lsrs")
+	}
+}
+
+func (manager *LSRManager) scanLSR(row pgx.Row) (*lsr, error) {
+
+	g := ewkb.Point{}
+
+	l := lsr{}
+
+	if err := row.Scan(
+		&l.ID,
+		&l.CreatedAt,
+		&l.UpdatedAt,
+		&l.Product,
+		&l.WFO,
+		&l.Valid,
+		&l.Year,
+		&l.Event,
+		&l.Magnitude,
+		&l.Units,
+		&l.Qualifier,
+		&l.Location,
+		&l.County,
+		&l.State,
+		&l.Source,
+		&l.Remarks,
+		&g,
+	); err != nil {
+		return nil, fmt.Errorf("failed to scan lsr: %v", err.Error())
+	}
+
+	l.LSRID = l.GenerateID()
+	l.Geom = g.Point
+
+	return &l, nil
+}
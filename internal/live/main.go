@@ -22,6 +22,11 @@ func main() {
 	}
 
 	http.Handle("/ws", hub)
+	http.HandleFunc("/sse", hub.ServeSSE)
+	http.HandleFunc("/poll", hub.ServePoll)
+	http.HandleFunc("GET /warnings", hub.ServeWarnings)
+	http.HandleFunc("GET /warnings/{id}", hub.ServeWarning)
+	http.HandleFunc("GET /ugc/{code}/warnings", hub.ServeUGCWarnings)
 
 	go hub.run()
 
@@ -3,6 +3,6 @@ package main
 type Manager interface {
 	Load() error
 	Run()
-	Subscribe(*client)
+	Subscribe(*subscription)
 	Unsubscribe(*client)
 }
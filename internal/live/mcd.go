@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/rs/zerolog/log"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+	"github.com/twpayne/go-geom/encoding/geojson"
+)
+
+const MCDTopic string = "mcds"
+
+type mcdDTO struct {
+	ID               int       `json:"id"`
+	CreatedAt        time.Time `json:"created_at,omitzero"`
+	UpdatedAt        time.Time `json:"updated_at,omitzero"`
+	Product          string    `json:"product"`
+	Issued           time.Time `json:"issued"`
+	Expires          time.Time `json:"expires"`
+	Year             int       `json:"year"`
+	Concerning       string    `json:"concerning"`
+	Geom             []byte    `json:"geom"`
+	WatchProbability *int      `json:"watch_probability"`
+	MostProbTornado  string    `json:"most_prob_tornado,omitempty"`
+	MostProbGust     string    `json:"most_prob_gust,omitempty"`
+	MostProbHail     string    `json:"most_prob_hail,omitempty"`
+	Text             string    `json:"text"`
+}
+
+type mcd struct {
+	ID               int           `json:"id"`
+	MCDID            string        `json:"mcdID"`
+	CreatedAt        time.Time     `json:"createdAt,omitzero"`
+	UpdatedAt        time.Time     `json:"updatedAt,omitzero"`
+	Product          string        `json:"product"`
+	Issued           time.Time     `json:"issued"`
+	Expires          time.Time     `json:"expires"`
+	Year             int           `json:"year"`
+	Concerning       string        `json:"concerning"`
+	Geom             *geom.Polygon `json:"geom,omitempty"`
+	WatchProbability *int          `json:"watchProbability"`
+	MostProbTornado  string        `json:"mostProbTornado,omitempty"`
+	MostProbGust     string        `json:"mostProbGust,omitempty"`
+	MostProbHail     string        `json:"mostProbHail,omitempty"`
+	Text             string        `json:"text"`
+}
+
+// Generates an ID using the MCD number and year.
+//
+// Example: MCD-0512-2025
+func (m *mcd) GenerateID() string {
+	return fmt.Sprintf("MCD-%04d-%d", m.ID, m.Year)
+}
+
+func (m *mcd) MarshalJSON() ([]byte, error) {
+	type Alias mcd // Use type alias to avoid recursion
+
+	aux := struct {
+		Alias
+		Geom string `json:"geom,omitempty"`
+	}{
+		Alias: (Alias)(*m),
+	}
+
+	if m.Geom != nil {
+		b, err := geojson.Marshal(m.Geom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal geometry: %v", err.Error())
+		}
+		aux.Geom = string(b)
+	}
+
+	return json.Marshal(aux)
+}
+
+type MCDManager struct {
+	mu sync.Mutex
+
+	hub    *Hub
+	events <-chan streaming.Delivery
+
+	data        map[string]*mcd
+	subscribers map[*client]struct{}
+	replay      *replayBuffer[struct{}]
+
+	ticker *time.Ticker
+}
+
+func AttachMCDManager(hub *Hub) {
+	hub.managers[MCDTopic] = NewMCDManager(hub)
+}
+
+func NewMCDManager(hub *Hub) *MCDManager {
+
+	ticker := time.NewTicker(60 * time.Second)
+
+	return &MCDManager{
+		hub:         hub,
+		data:        map[string]*mcd{},
+		subscribers: map[*client]struct{}{},
+		replay:      newReplayBuffer[struct{}](replayBufferSize),
+		ticker:      ticker,
+	}
+}
+
+func (manager *MCDManager) Load() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	// Subscribe before loading so no events are missed in between
+	events, err := manager.hub.stream.SubscribeLive(context.Background(), "live.mcd", streaming.ProductMCD)
+	if err != nil {
+		return err
+	}
+	manager.events = events
+
+	// Get all the unexpired MCDs
+	rows, err := manager.hub.db.Query(context.Background(), `
+	SELECT m.id, m.created_at, m.updated_at, m.product, m.issued, m.expires, m.year, m.concerning, m.geom,
+	m.watch_probability, COALESCE(m.most_prob_tornado, ''), COALESCE(m.most_prob_gust, ''), COALESCE(m.most_prob_hail, ''),
+	COALESCE(p.data, '')
+	FROM mcd.mcd m LEFT JOIN awips.products p ON p.product_id = m.product
+	WHERE m.expires > now()
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to get active mcds: %v", err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := manager.scanMCD(rows)
+		if err != nil {
+			return err
+		}
+
+		manager.data[m.MCDID] = m
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	log.Debug().Int("size", len(manager.data)).Msg("loaded mcd data")
+
+	return nil
+}
+
+func (manager *MCDManager) Run() {
+	go func() {
+		for {
+			select {
+			case t := <-manager.ticker.C:
+				manager.ticker.Reset(60 * time.Second)
+				manager.checkExpired(t)
+			case message := <-manager.events:
+
+				m := &mcdDTO{}
+
+				if err := json.Unmarshal(message.Body, m); err != nil {
+					log.Error().Err(err).Msg("failed to unmarshal mcd message")
+					continue
+				}
+
+				err := manager.handleUpdate(m, message.Type)
+				if err != nil {
+					log.Error().Err(err).Msg("failed to handle mcd update")
+					continue
+				}
+				message.Ack()
+			}
+		}
+	}()
+}
+
+func (manager *MCDManager) Subscribe(s *subscription) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.subscribers[s.client] = struct{}{}
+
+	// Send only what the client missed when the buffer still has it
+	if s.Since != nil {
+		if entries, ok := manager.replay.since(s.Epoch, *s.Since); ok {
+			for _, entry := range entries {
+				s.client.send <- entry.envelope
+			}
+			log.Debug().Int("size", len(entries)).Uint64("since", *s.Since).Msg("replayed mcd data to client")
+			return
+		}
+	}
+
+	mcds := []*mcd{}
+	for _, m := range manager.data {
+		mcds = append(mcds, m)
+	}
+
+	mcdsBytes, err := json.Marshal(mcds)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal mcds for subscription")
+		return
+	}
+
+	envelope := Envelope{
+		Type:      EnvelopeInitial,
+		Product:   MCDTopic,
+		ID:        "",
+		Timestamp: time.Now(),
+		Data:      mcdsBytes,
+		Sequence:  manager.replay.current(),
+		Epoch:     manager.replay.epoch,
+	}
+
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal envelope for subscription")
+		return
+	}
+
+	s.client.send <- envelopeBytes
+
+	log.Debug().Int("size", len(mcds)).Msg("sent initial mcd data to client")
+}
+
+func (manager *MCDManager) Unsubscribe(c *client) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	delete(manager.subscribers, c)
+}
+
+func (manager *MCDManager) handleUpdate(dto *mcdDTO, eventType string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	m := &mcd{
+		ID:               dto.ID,
+		CreatedAt:        dto.CreatedAt,
+		UpdatedAt:        dto.UpdatedAt,
+		Product:          dto.Product,
+		Issued:           dto.Issued,
+		Expires:          dto.Expires,
+		Year:             dto.Year,
+		Concerning:       dto.Concerning,
+		WatchProbability: dto.WatchProbability,
+		MostProbTornado:  dto.MostProbTornado,
+		MostProbGust:     dto.MostProbGust,
+		MostProbHail:     dto.MostProbHail,
+		Text:             dto.Text,
+	}
+	m.MCDID = m.GenerateID()
+
+	if len(dto.Geom) > 0 {
+		g, err := ewkb.Unmarshal(dto.Geom)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal mcd geometry: %v", err.Error())
+		}
+
+		polygon, ok := g.(*geom.Polygon)
+		if !ok {
+			log.Warn().Str("mcd", m.MCDID).Msg("mcd geometry was not a polygon")
+		}
+		m.Geom = polygon
+	}
+
+	// A corrected discussion replaces the existing one so clients only need to know about new and deleted discussions
+	envelopeType := EnvelopeNew
+	if eventType == streaming.EventDelete {
+		envelopeType = EnvelopeDelete
+	}
+
+	mcdBytes, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	envelope := Envelope{
+		Type:      envelopeType,
+		Product:   MCDTopic,
+		ID:        m.MCDID,
+		Timestamp: time.Now(),
+		Data:      mcdBytes,
+	}
+
+	envelopeBytes, err := manager.replay.record(&envelope, struct{}{})
+	if err != nil {
+		return err
+	}
+
+	for client := range manager.subscribers {
+		client.send <- envelopeBytes
+	}
+
+	if envelopeType == EnvelopeDelete {
+		delete(manager.data, m.MCDID)
+	} else {
+		manager.data[m.MCDID] = m
+	}
+
+	return nil
+}
+
+func (manager *MCDManager) checkExpired(t time.Time) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	toDelete := []*mcd{}
+	for _, m := range manager.data {
+		if !m.Expires.After(t) {
+			toDelete = append(toDelete, m)
+		}
+	}
+
+	if len(toDelete) > 0 {
+		for _, m := range toDelete {
+			delete(manager.data, m.MCDID)
+
+			mcdBytes, err := json.Marshal(m)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to marshal mcd for expired mcd")
+				continue
+			}
+
+			envelope := Envelope{
+				Type:      EnvelopeDelete,
+				Product:   MCDTopic,
+				ID:        m.MCDID,
+				Timestamp: time.Now(),
+				Data:      mcdBytes,
+			}
+
+			envelopeBytes, err := manager.replay.record(&envelope, struct{}{})
+			if err != nil {
+				log.Error().Err(err).Msg("failed to marshal envelope for expired mcd")
+				continue
+			}
+
+			for client := range manager.subscribers {
+				client.send <- envelopeBytes
+			}
+		}
+
+		log.Debug().Int("deleted", len(toDelete)).Msg("deleted expired mcds")
+	}
+}
+
+func (manager *MCDManager) scanMCD(row pgx.Row) (*mcd, error) {
+
+	g := ewkb.Polygon{}
+
+	m := mcd{}
+
+	if err := row.Scan(
+		&m.ID,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+		&m.Product,
+		&m.Issued,
+		&m.Expires,
+		&m.Year,
+		&m.Concerning,
+		&g,
+		&m.WatchProbability,
+		&m.MostProbTornado,
+		&m.MostProbGust,
+		&m.MostProbHail,
+		&m.Text,
+	); err != nil {
+		return nil, fmt.Errorf("failed to scan mcd: %v", err.Error())
+	}
+
+	m.MCDID = m.GenerateID()
+	m.Geom = g.Polygon
+
+	return &m, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Respond with the envelopes sent to the subscriptions as a JSON array. Without since each poll
+// starts with the INIT snapshot; with since, the poll waits until there is something new or the
+// timeout passes and an empty array is returned.
+func (hub *Hub) ServePoll(w http.ResponseWriter, r *http.Request) {
+	subscriptions, ok := hub.httpSubscriptions(w, r)
+	if !ok {
+		return
+	}
+
+	timeout := PollTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			http.Error(w, "invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = min(time.Duration(seconds)*time.Second, PollTimeout)
+	}
+
+	var (
+		mu        sync.Mutex
+		envelopes = []json.RawMessage{}
+	)
+	received := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	c := newHTTPClient(hub)
+	go func() {
+		for {
+			select {
+			case message := <-c.send:
+				mu.Lock()
+				envelopes = append(envelopes, message)
+				mu.Unlock()
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	hub.subscribeHTTPClient(c, subscriptions)
+
+	mu.Lock()
+	waiting := len(envelopes) == 0
+	mu.Unlock()
+	if waiting {
+		timer := time.NewTimer(timeout)
+		select {
+		case <-received:
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+		timer.Stop()
+	}
+
+	close(done)
+	hub.releaseHTTPClient(c)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(envelopes); err != nil {
+		log.Debug().Err(err).Msg("failed to write poll response")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Sends an INIT envelope to each new subscriber and broadcasts whatever it is given
+type testManager struct {
+	mu          sync.Mutex
+	subscribers map[*client]struct{}
+}
+
+func (manager *testManager) Load() error { return nil }
+func (manager *testManager) Run()        {}
+
+func (manager *testManager) Subscribe(s *subscription) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.subscribers[s.client] = struct{}{}
+	if s.Since == nil {
+		s.client.send <- []byte(`{"type":"INIT","product":"test"}`)
+	}
+}
+
+func (manager *testManager) Unsubscribe(c *client) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	delete(manager.subscribers, c)
+}
+
+func (manager *testManager) broadcast(message string) int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for c := range manager.subscribers {
+		c.send <- []byte(message)
+	}
+	return len(manager.subscribers)
+}
+
+func (manager *testManager) count() int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return len(manager.subscribers)
+}
+
+func testHub() (*Hub, *testManager) {
+	manager := &testManager{subscribers: map[*client]struct{}{}}
+	hub := &Hub{
+		connections: map[*client]bool{},
+		managers:    map[string]Manager{"test": manager},
+	}
+	return hub, manager
+}
+
+func TestServePollInitial(t *testing.T) {
+	hub, manager := testHub()
+
+	w := httptest.NewRecorder()
+	hub.ServePoll(w, httptest.NewRequest(http.MethodGet, "/poll?topics=test", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"type":"INIT","product":"test"}]`, w.Body.String())
+	assert.Zero(t, manager.count())
+	assert.Empty(t, hub.connections)
+}
+
+func TestServePollWaits(t *testing.T) {
+	hub, manager := testHub()
+
+	go func() {
+		for manager.broadcast(`{"type":"NEW","product":"test"}`) == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	w := httptest.NewRecorder()
+	hub.ServePoll(w, httptest.NewRequest(http.MethodGet, "/poll?topics=test&since=3&timeout=5", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"type":"NEW","product":"test"}]`, w.Body.String())
+	assert.Zero(t, manager.count())
+}
+
+func TestServePollTimeout(t *testing.T) {
+	hub, _ := testHub()
+
+	w := httptest.NewRecorder()
+	hub.ServePoll(w, httptest.NewRequest(http.MethodGet, "/poll?topics=test&since=3&timeout=0", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
+
+func TestServePollInvalid(t *testing.T) {
+	hub, _ := testHub()
+
+	for _, target := range []string{"/poll", "/poll?topics=unknown", "/poll?topics=test&timeout=soon"} {
+		w := httptest.NewRecorder()
+		hub.ServePoll(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+
+	w := httptest.NewRecorder()
+	hub.ServePoll(w, httptest.NewRequest(http.MethodPost, "/poll?topics=test", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestServeSSE(t *testing.T) {
+	hub, manager := testHub()
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeSSE))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?topics=test", nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(res.Body)
+	event := func() map[string]any {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		_, err = reader.ReadString('\n')
+		require.NoError(t, err)
+
+		data := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data))
+		return data
+	}
+
+	assert.Equal(t, "INIT", event()["type"])
+
+	manager.broadcast(`{"type":"NEW","product":"test"}`)
+	assert.Equal(t, "NEW", event()["type"])
+
+	// Closing the stream unsubscribes the client
+	cancel()
+	assert.Eventually(t, func() bool { return manager.count() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServeSSEStalledConsumer(t *testing.T) {
+	writeWait := WriteWait
+	WriteWait = 100 * time.Millisecond
+	defer func() { WriteWait = writeWait }()
+
+	hub, manager := testHub()
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeSSE))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?topics=test", nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Eventually(t, func() bool { return manager.count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Never read the body so the connection fills up and the writes stall. The broadcasts are
+	// spaced out so unsubscribing is not starved of the manager's lock.
+	message := `{"type":"NEW","product":"` + strings.Repeat("x", 1<<20) + `"}`
+	assert.Eventually(t, func() bool { return manager.broadcast(message) == 0 }, 5*time.Second, 10*time.Millisecond,
+		"expected the stalled consumer to be dropped")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// How many envelopes each topic keeps for clients that reconnect
+const replayBufferSize = 1000
+
+// The epoch of the envelopes this process sends
+var streamEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// An envelope that was sent, with what it was about so it can be filtered again when replayed
+type replayEntry[T any] struct {
+	sequence uint64
+	envelope []byte
+	subject  T
+}
+
+// Numbers the envelopes of a topic and keeps the most recent ones. Callers hold the manager's lock.
+type replayBuffer[T any] struct {
+	epoch    string
+	sequence uint64
+	entries  []replayEntry[T]
+	// Where the next entry goes once the buffer is full
+	next int
+	size int
+}
+
+func newReplayBuffer[T any](size int) *replayBuffer[T] {
+	return &replayBuffer[T]{
+		epoch:   streamEpoch,
+		entries: make([]replayEntry[T], 0, size),
+		size:    size,
+	}
+}
+
+// The sequence number of the last envelope sent
+func (buffer *replayBuffer[T]) current() uint64 {
+	return buffer.sequence
+}
+
+// Give the envelope the next sequence number and keep it. Returns the envelope to send.
+func (buffer *replayBuffer[T]) record(envelope *Envelope, subject T) ([]byte, error) {
+	envelope.Sequence = buffer.sequence + 1
+	envelope.Epoch = buffer.epoch
+
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	buffer.sequence = envelope.Sequence
+
+	entry := replayEntry[T]{sequence: envelope.Sequence, envelope: b, subject: subject}
+	if len(buffer.entries) < buffer.size {
+		buffer.entries = append(buffer.entries, entry)
+	} else {
+		buffer.entries[buffer.next] = entry
+		buffer.next = (buffer.next + 1) % buffer.size
+	}
+
+	return b, nil
+}
+
+// The envelopes sent after the sequence number of the epoch, oldest first. Returns false when
+// some have already been dropped or the sequence number is from another epoch, such as before
+// a restart, so the client needs a fresh snapshot.
+func (buffer *replayBuffer[T]) since(epoch string, sequence uint64) ([]replayEntry[T], bool) {
+	if epoch != buffer.epoch || sequence > buffer.sequence {
+		return nil, false
+	}
+
+	entries := make([]replayEntry[T], 0, len(buffer.entries))
+	entries = append(entries, buffer.entries[buffer.next:]...)
+	entries = append(entries, buffer.entries[:buffer.next]...)
+
+	oldest := buffer.sequence + 1
+	if len(entries) > 0 {
+		oldest = entries[0].sequence
+	}
+	if sequence+1 < oldest {
+		return nil, false
+	}
+
+	return entries[len(entries)-int(buffer.sequence-sequence):], true
+}
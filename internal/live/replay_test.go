@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordEnvelopes(t *testing.T, buffer *replayBuffer[string], ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		_, err := buffer.record(&Envelope{Type: EnvelopeNew, ID: id}, id)
+		require.NoError(t, err)
+	}
+}
+
+func replayedIDs(entries []replayEntry[string]) []string {
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.subject)
+	}
+	return ids
+}
+
+func TestReplayBufferRecord(t *testing.T) {
+	buffer := newReplayBuffer[string](3)
+	assert.Equal(t, uint64(0), buffer.current())
+
+	envelope := &Envelope{Type: EnvelopeNew, ID: "a"}
+	b, err := buffer.record(envelope, "a")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), envelope.Sequence)
+	assert.Equal(t, uint64(1), buffer.current())
+
+	sent := Envelope{}
+	require.NoError(t, json.Unmarshal(b, &sent))
+	assert.Equal(t, uint64(1), sent.Sequence)
+	assert.Equal(t, streamEpoch, sent.Epoch)
+}
+
+func TestReplayBufferSince(t *testing.T) {
+	buffer := newReplayBuffer[string](3)
+	recordEnvelopes(t, buffer, "a", "b")
+
+	entries, ok := buffer.since(buffer.epoch, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b"}, replayedIDs(entries))
+
+	entries, ok = buffer.since(buffer.epoch, 2)
+	assert.True(t, ok)
+	assert.Empty(t, entries)
+
+	// Sequence numbers from before a restart
+	_, ok = buffer.since(buffer.epoch, 5)
+	assert.False(t, ok)
+	_, ok = buffer.since("", 2)
+	assert.False(t, ok)
+	_, ok = buffer.since("previous", 1)
+	assert.False(t, ok)
+
+	// The buffer wraps around, dropping a and b
+	recordEnvelopes(t, buffer, "c", "d", "e")
+	entries, ok = buffer.since(buffer.epoch, 2)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"c", "d", "e"}, replayedIDs(entries))
+
+	entries, ok = buffer.since(buffer.epoch, 3)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"d", "e"}, replayedIDs(entries))
+
+	_, ok = buffer.since(buffer.epoch, 1)
+	assert.False(t, ok)
+}
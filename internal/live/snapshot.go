@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/twpayne/go-geom/encoding/geojson"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+// The warnings manager, or nil before its warnings have been loaded
+func (hub *Hub) warningManager() *WarningManager {
+	hub.mu.Lock()
+	manager, _ := hub.managers[WarningTopic].(*WarningManager)
+	hub.mu.Unlock()
+	if manager == nil {
+		return nil
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.loaded {
+		return nil
+	}
+	return manager
+}
+
+// The warnings that pass the filter, newest first
+func (manager *WarningManager) snapshot(filter *warningFilter) []*warning {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	warnings := []*warning{}
+	for _, w := range manager.data {
+		if filter.matches(w) {
+			warnings = append(warnings, w)
+		}
+	}
+
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Issued.Equal(warnings[j].Issued) {
+			return warnings[i].ID > warnings[j].ID
+		}
+		return warnings[i].Issued.After(warnings[j].Issued)
+	})
+
+	return warnings
+}
+
+func (manager *WarningManager) find(id string) *warning {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.data[id]
+}
+
+// Whether the request asks for GeoJSON with format=geojson or its Accept header
+func wantsGeoJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "geojson")
+	}
+	return strings.Contains(r.Header.Get("Accept"), geoJSONContentType)
+}
+
+// The warning as a GeoJSON feature, with the polygon as the geometry and everything else as properties
+func warningFeature(w *warning) (*geojson.Feature, error) {
+	b, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := map[string]any{}
+	if err := json.Unmarshal(b, &properties); err != nil {
+		return nil, err
+	}
+	delete(properties, "geom")
+
+	feature := &geojson.Feature{
+		ID:         w.CompositeID(),
+		Properties: properties,
+	}
+	// A nil polygon would not be a nil geometry
+	if w.Geom != nil {
+		feature.Geometry = w.Geom
+	}
+
+	return feature, nil
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, v any) {
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+// Write the warnings as a JSON array or a GeoJSON feature collection
+func writeWarnings(w http.ResponseWriter, r *http.Request, warnings []*warning) {
+	if !wantsGeoJSON(r) {
+		writeJSON(w, "application/json", warnings)
+		return
+	}
+
+	collection := &geojson.FeatureCollection{Features: []*geojson.Feature{}}
+	for _, warning := range warnings {
+		feature, err := warningFeature(warning)
+		if err != nil {
+			log.Error().Err(err).Str("warning", warning.WarningID).Msg("failed to convert warning to feature")
+			http.Error(w, "failed to convert warnings", http.StatusInternalServerError)
+			return
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	writeJSON(w, geoJSONContentType, collection)
+}
+
+// Check the warnings manager is ready and read the filter from the query
+func (hub *Hub) snapshotRequest(w http.ResponseWriter, r *http.Request) (*WarningManager, *warningFilter, bool) {
+	manager := hub.warningManager()
+	if manager == nil {
+		http.Error(w, "Warnings are not loaded.", http.StatusServiceUnavailable)
+		return nil, nil, false
+	}
+
+	filter, err := warningFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	return manager, filter, true
+}
+
+// The active warnings that pass the filter in the query
+func (hub *Hub) ServeWarnings(w http.ResponseWriter, r *http.Request) {
+	manager, filter, ok := hub.snapshotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	writeWarnings(w, r, manager.snapshot(filter))
+}
+
+// An active warning by its composite ID, such as KOUN-TO-W-0012-2025-1
+func (hub *Hub) ServeWarning(w http.ResponseWriter, r *http.Request) {
+	manager, _, ok := hub.snapshotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	warning := manager.find(r.PathValue("id"))
+	if warning == nil {
+		http.Error(w, "Warning not found.", http.StatusNotFound)
+		return
+	}
+
+	if !wantsGeoJSON(r) {
+		writeJSON(w, "application/json", warning)
+		return
+	}
+
+	feature, err := warningFeature(warning)
+	if err != nil {
+		log.Error().Err(err).Str("warning", warning.WarningID).Msg("failed to convert warning to feature")
+		http.Error(w, "failed to convert warning", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, geoJSONContentType, feature)
+}
+
+// The active warnings for a UGC that pass the filter in the query
+func (hub *Hub) ServeUGCWarnings(w http.ResponseWriter, r *http.Request) {
+	manager, filter, ok := hub.snapshotRequest(w, r)
+	if !ok {
+		return
+	}
+
+	code := strings.ToUpper(r.PathValue("code"))
+	if hub.ugcStore.findUGC(code) == nil {
+		http.Error(w, "UGC not found.", http.StatusNotFound)
+		return
+	}
+
+	if filter == nil {
+		filter = &warningFilter{}
+	}
+	filter.UGC = []string{code}
+
+	writeWarnings(w, r, manager.snapshot(filter))
+}
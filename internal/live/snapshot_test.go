@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotServer(t *testing.T) *http.ServeMux {
+	t.Helper()
+
+	hub := &Hub{managers: map[string]Manager{}}
+	hub.ugcStore = NewUGCStore(hub)
+	hub.ugcStore.data["OKC027"] = &UGC{Code: "OKC027", State: "OK"}
+	hub.ugcStore.data["TXC485"] = &UGC{Code: "TXC485", State: "TX"}
+
+	manager := NewWarningManager(hub)
+	manager.ticker.Stop()
+	manager.loaded = true
+	hub.managers[WarningTopic] = manager
+
+	tornado := testWarning()
+	tornado.Issued = time.Date(2025, 5, 19, 23, 10, 0, 0, time.UTC)
+	tornado.Geom = testPolygon()
+	tornado.WarningID = tornado.CompositeID()
+	manager.data[tornado.CompositeID()] = tornado
+
+	severe := &warning{
+		ID:           2,
+		WFO:          "KFWD",
+		Phenomena:    "SV",
+		Significance: "W",
+		EventNumber:  40,
+		Year:         2025,
+		Issued:       time.Date(2025, 5, 19, 23, 20, 0, 0, time.UTC),
+		UGC:          map[string]UGC{"TXC485": {Code: "TXC485", State: "TX"}},
+	}
+	severe.WarningID = severe.CompositeID()
+	manager.data[severe.CompositeID()] = severe
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /warnings", hub.ServeWarnings)
+	mux.HandleFunc("GET /warnings/{id}", hub.ServeWarning)
+	mux.HandleFunc("GET /ugc/{code}/warnings", hub.ServeUGCWarnings)
+	return mux
+}
+
+func getSnapshot(t *testing.T, mux *http.ServeMux, target string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func snapshotIDs(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	warnings := []struct {
+		WarningID string `json:"warningID"`
+	}{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &warnings))
+
+	ids := []string{}
+	for _, w := range warnings {
+		ids = append(ids, w.WarningID)
+	}
+	return ids
+}
+
+func TestServeWarnings(t *testing.T) {
+	mux := snapshotServer(t)
+
+	w := getSnapshot(t, mux, "/warnings")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"KFWD-SV-W-0040-2025-2", "KOUN-TO-W-0012-2025-1"}, snapshotIDs(t, w), "newest first")
+
+	assert.Equal(t, []string{"KOUN-TO-W-0012-2025-1"}, snapshotIDs(t, getSnapshot(t, mux, "/warnings?state=ok")))
+	assert.Equal(t, []string{"KFWD-SV-W-0040-2025-2"}, snapshotIDs(t, getSnapshot(t, mux, "/warnings?phenomena=SV.W")))
+	assert.Equal(t, []string{"KOUN-TO-W-0012-2025-1"}, snapshotIDs(t, getSnapshot(t, mux, "/warnings?point=-97.44,35.22")))
+	assert.Empty(t, snapshotIDs(t, getSnapshot(t, mux, "/warnings?emergency=true")))
+
+	assert.Equal(t, http.StatusBadRequest, getSnapshot(t, mux, "/warnings?radius=5").Code)
+}
+
+func TestServeWarningsGeoJSON(t *testing.T) {
+	mux := snapshotServer(t)
+
+	for _, w := range []*httptest.ResponseRecorder{
+		getSnapshot(t, mux, "/warnings?format=geojson"),
+		getSnapshot(t, mux, "/warnings", "Accept", "application/geo+json"),
+	} {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/geo+json", w.Header().Get("Content-Type"))
+
+		collection := struct {
+			Type     string `json:"type"`
+			Features []struct {
+				ID         string          `json:"id"`
+				Geometry   json.RawMessage `json:"geometry"`
+				Properties map[string]any  `json:"properties"`
+			} `json:"features"`
+		}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &collection))
+
+		assert.Equal(t, "FeatureCollection", collection.Type)
+		require.Len(t, collection.Features, 2)
+		assert.Equal(t, "KFWD-SV-W-0040-2025-2", collection.Features[0].ID)
+		assert.JSONEq(t, "null", string(collection.Features[0].Geometry))
+		assert.Contains(t, string(collection.Features[1].Geometry), `"MultiPolygon"`)
+		assert.Equal(t, "KOUN", collection.Features[1].Properties["wfo"])
+		assert.NotContains(t, collection.Features[1].Properties, "geom")
+	}
+}
+
+func TestServeWarning(t *testing.T) {
+	mux := snapshotServer(t)
+
+	w := getSnapshot(t, mux, "/warnings/KFWD-SV-W-0040-2025-2")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"warningID":"KFWD-SV-W-0040-2025-2"`)
+
+	w = getSnapshot(t, mux, "/warnings/KOUN-TO-W-0012-2025-1?format=geojson")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"Feature"`)
+
+	assert.Equal(t, http.StatusNotFound, getSnapshot(t, mux, "/warnings/KOUN-TO-W-0001-2025-9").Code)
+}
+
+func TestServeUGCWarnings(t *testing.T) {
+	mux := snapshotServer(t)
+
+	assert.Equal(t, []string{"KFWD-SV-W-0040-2025-2"}, snapshotIDs(t, getSnapshot(t, mux, "/ugc/txc485/warnings")))
+	assert.Empty(t, snapshotIDs(t, getSnapshot(t, mux, "/ugc/TXC485/warnings?phenomena=TO")))
+	assert.Equal(t, http.StatusNotFound, getSnapshot(t, mux, "/ugc/TXC001/warnings").Code)
+}
+
+func TestServeWarningsNotLoaded(t *testing.T) {
+	hub := &Hub{managers: map[string]Manager{}}
+
+	w := httptest.NewRecorder()
+	hub.ServeWarnings(w, httptest.NewRequest(http.MethodGet, "/warnings", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// Attached but still loading
+	manager := NewWarningManager(hub)
+	manager.ticker.Stop()
+	hub.managers[WarningTopic] = manager
+
+	w = httptest.NewRecorder()
+	hub.ServeWarnings(w, httptest.NewRequest(http.MethodGet, "/warnings", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
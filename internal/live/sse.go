@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Read and check the subscriptions of an HTTP request, writing an error when they are invalid
+func (hub *Hub) httpSubscriptions(w http.ResponseWriter, r *http.Request) ([]*subscription, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	subscriptions, err := subscriptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	for _, s := range subscriptions {
+		if !hub.hasTopic(s.Topics[0]) {
+			http.Error(w, fmt.Sprintf("unknown topic %s", s.Topics[0]), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	return subscriptions, true
+}
+
+// Stream envelopes as Server-Sent Events, with each envelope as the data of a message
+func (hub *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	subscriptions, ok := hub.httpSubscriptions(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "Streaming not supported.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx holding back events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The managers wait for each client to take what they send, so a consumer that stops reading
+	// is dropped once a write takes longer than WriteWait, as WebSocket clients are
+	controller := http.NewResponseController(w)
+	write := func(format string, args ...any) error {
+		err := controller.SetWriteDeadline(time.Now().Add(WriteWait))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+	if err := controller.Flush(); err != nil {
+		log.Debug().Err(err).Msg("failed to start event stream")
+		return
+	}
+
+	c := newHTTPClient(hub)
+	subscribed := make(chan struct{})
+	go func() {
+		hub.subscribeHTTPClient(c, subscriptions)
+		close(subscribed)
+	}()
+
+	defer func() {
+		// Keep reading until the subscriptions are made so none are left without a reader
+		for waiting := true; waiting; {
+			select {
+			case <-c.send:
+			case <-subscribed:
+				waiting = false
+			}
+		}
+		hub.releaseHTTPClient(c)
+	}()
+
+	ticker := time.NewTicker(PingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-c.send:
+			if err := write("data: %s\n\n", message); err != nil {
+				log.Debug().Err(err).Msg("failed to write event")
+				return
+			}
+		case <-ticker.C:
+			// Comments keep proxies from closing an idle stream
+			if err := write(": ping\n\n"); err != nil {
+				log.Debug().Err(err).Msg("failed to ping event stream")
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
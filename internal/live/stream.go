@@ -0,0 +1,11 @@
+package main
+
+import (
+	"github.com/metdatasystem/us/shared/streaming"
+)
+
+const appID = "us.live"
+
+func newStreamTransport() (streaming.Transport, error) {
+	return streaming.NewTransport(appID)
+}
@@ -5,6 +5,8 @@ import (
 	"sync"
 
 	"github.com/rs/zerolog/log"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/ewkb"
 )
 
 type UGC struct {
@@ -14,6 +16,8 @@ type UGC struct {
 	Type   string `json:"type"`
 	Number int    `json:"number"`
 	Name   string `json:"name"`
+	// Only used for geofenced subscriptions
+	Geom *geom.MultiPolygon `json:"-"`
 }
 
 type UGCStore struct {
@@ -35,7 +39,8 @@ func (store *UGCStore) load() error {
 	defer store.mu.Unlock()
 
 	rows, err := store.hub.db.Query(context.Background(), `
-	SELECT id, ugc, name, state, type, number FROM postgis.ugcs WHERE valid_to IS NULL
+	SELECT id, ugc, name, state, type, number, ST_Multi(ST_SimplifyPreserveTopology(geom, 0.01))
+	FROM postgis.ugcs WHERE valid_to IS NULL
 	`)
 	if err != nil {
 		return err
@@ -44,6 +49,7 @@ func (store *UGCStore) load() error {
 
 	for rows.Next() {
 		ugc := UGC{}
+		g := ewkb.MultiPolygon{}
 		if err := rows.Scan(
 			&ugc.ID,
 			&ugc.Code,
@@ -51,9 +57,11 @@ func (store *UGCStore) load() error {
 			&ugc.State,
 			&ugc.Type,
 			&ugc.Number,
+			&g,
 		); err != nil {
 			return err
 		}
+		ugc.Geom = g.MultiPolygon
 		store.data[ugc.Code] = &ugc
 	}
 
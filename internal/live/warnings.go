@@ -9,7 +9,6 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/metdatasystem/us/shared/streaming"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog/log"
 	"github.com/twpayne/go-geom"
 	"github.com/twpayne/go-geom/encoding/ewkb"
@@ -59,6 +58,7 @@ type warningDTO struct {
 	SpoutTag       string     `json:"spout_tag,omitempty"`
 	SnowSquall     string     `json:"snow_squall,omitempty"`
 	SnowSquallTag  string     `json:"snow_squall_tag,omitempty"`
+	HVTEC          *hvtec     `json:"hvtec,omitempty"`
 }
 
 // Generates an ID using the warning's WFO, phenomena, significance, event number, and year.
@@ -117,6 +117,18 @@ type warning struct {
 	SpoutTag       string             `json:"spoutTag,omitempty"`
 	SnowSquall     string             `json:"snowSquall,omitempty"`
 	SnowSquallTag  string             `json:"snowSquall_tag,omitempty"`
+	HVTEC          *hvtec             `json:"hvtec,omitempty"`
+}
+
+// The hydrologic VTEC of river flood warnings
+type hvtec struct {
+	NWSLI    string     `json:"nwsli"`
+	Severity string     `json:"severity"`
+	Cause    string     `json:"cause"`
+	Begin    *time.Time `json:"begin"`
+	Crest    *time.Time `json:"crest"`
+	End      *time.Time `json:"end"`
+	Record   string     `json:"record"`
 }
 
 // Generates an ID using the warning's WFO, phenomena, significance, event number, and year.
@@ -133,6 +145,30 @@ func (w *warning) CompositeID() string {
 	return fmt.Sprintf("%s-%v", w.GenerateID(), w.ID)
 }
 
+// The warning's polygon or, when it does not have one, the areas of its UGC
+func (w *warning) areas() []*geom.MultiPolygon {
+	if w.Geom != nil {
+		return []*geom.MultiPolygon{w.Geom}
+	}
+
+	areas := []*geom.MultiPolygon{}
+	for _, ugc := range w.UGC {
+		if ugc.Geom != nil {
+			areas = append(areas, ugc.Geom)
+		}
+	}
+	return areas
+}
+
+// The bounds of the warning's areas
+func (w *warning) bounds() *geom.Bounds {
+	bounds := geom.NewBounds(geom.XY)
+	for _, area := range w.areas() {
+		bounds.Extend(area)
+	}
+	return bounds
+}
+
 func (w *warning) MarshalJSON() ([]byte, error) {
 	type Alias warning // Use type alias to avoid recursion
 
@@ -166,17 +202,23 @@ func (w *warning) MarshalJSON() ([]byte, error) {
 type WarningManager struct {
 	mu sync.Mutex
 
-	hub         *Hub
-	rabbitQueue amqp.Queue
+	hub    *Hub
+	events <-chan streaming.Delivery
 
-	data        map[string]*warning
-	subscribers map[*client]struct{}
+	data map[string]*warning
+	// Each subscriber's filter, nil when they receive every warning
+	subscribers map[*client]*warningFilter
+	fences      *geofenceIndex
+	// Envelopes sent, with the warnings they were about
+	replay *replayBuffer[[]*warning]
+	// Whether the active warnings have been loaded
+	loaded bool
 
 	ticker *time.Ticker
 }
 
 func AttachWarningManager(hub *Hub) {
-	hub.managers["warnings"] = NewWarningManager(hub)
+	hub.managers[WarningTopic] = NewWarningManager(hub)
 }
 
 func NewWarningManager(hub *Hub) *WarningManager {
@@ -186,7 +228,9 @@ func NewWarningManager(hub *Hub) *WarningManager {
 	store := &WarningManager{
 		hub:         hub,
 		data:        map[string]*warning{},
-		subscribers: map[*client]struct{}{},
+		subscribers: map[*client]*warningFilter{},
+		fences:      newGeofenceIndex(),
+		replay:      newReplayBuffer[[]*warning](replayBufferSize),
 		ticker:      ticker,
 	}
 
@@ -197,32 +241,12 @@ func (manager *WarningManager) Load() error {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	rabbit := manager.hub.rabbit
-
-	// Declare and bind the RabbitMQ queues we will be consuming from
-	q, err := rabbit.QueueDeclare(
-		"live.warning",
-		false,
-		true,
-		false,
-		false,
-		nil,
-	)
+	// Subscribe before loading so no events are missed in between
+	events, err := manager.hub.stream.SubscribeLive(context.Background(), "live.warning", streaming.ProductWarning)
 	if err != nil {
 		return err
 	}
-
-	manager.rabbitQueue = q
-
-	if err := rabbit.QueueBind(
-		q.Name,
-		"warning",
-		streaming.ExchangeLiveName,
-		false,
-		nil,
-	); err != nil {
-		return err
-	}
+	manager.events = events
 
 	// Get all the current warnings
 	rows, err := manager.hub.db.Query(context.Background(), `
@@ -247,32 +271,19 @@ func (manager *WarningManager) Load() error {
 	}
 
 	log.Debug().Int("size", len(manager.data)).Msg("loaded warning data")
+	manager.loaded = true
 
 	return nil
 }
 
 func (manager *WarningManager) Run() {
-	d, err := manager.hub.rabbit.Consume(
-		manager.rabbitQueue.Name,
-		"live.warning",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to begin consuming warnings")
-		return
-	}
-
 	go func() {
 		for {
 			select {
 			case t := <-manager.ticker.C:
 				manager.ticker.Reset(60 * time.Second)
 				manager.checkExpired(t)
-			case message := <-d:
+			case message := <-manager.events:
 
 				w := &warningDTO{}
 
@@ -281,26 +292,48 @@ func (manager *WarningManager) Run() {
 					continue
 				}
 
-				err = manager.handleUpdate(w, message.Type)
+				err := manager.handleUpdate(w, message.Type)
 				if err != nil {
 					log.Error().Err(err).Msg("failed to handle warning update")
 					continue
 				}
-				message.Ack(true)
+				message.Ack()
 			}
 		}
 	}()
 }
 
-func (manager *WarningManager) Subscribe(c *client) {
+func (manager *WarningManager) Subscribe(s *subscription) {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	manager.subscribers[c] = struct{}{}
+	manager.subscribers[s.client] = s.Filter
+	if s.Filter != nil && s.Filter.fence != nil {
+		manager.fences.insert(s.client, s.Filter.fence.bounds)
+	} else {
+		manager.fences.remove(s.client)
+	}
+
+	// Send only what the client missed when the buffer still has it
+	if s.Since != nil {
+		if entries, ok := manager.replay.since(s.Epoch, *s.Since); ok {
+			sent := 0
+			for _, entry := range entries {
+				if s.Filter.matchesAny(entry.subject) {
+					s.client.send <- entry.envelope
+					sent++
+				}
+			}
+			log.Debug().Int("size", sent).Uint64("since", *s.Since).Msg("replayed warning data to client")
+			return
+		}
+	}
 
 	warnings := []*warning{}
 	for _, w := range manager.data {
-		warnings = append(warnings, w)
+		if s.Filter.matches(w) {
+			warnings = append(warnings, w)
+		}
 	}
 
 	// Marshal the warnings slice to JSON
@@ -317,6 +350,8 @@ func (manager *WarningManager) Subscribe(c *client) {
 		ID:        "",
 		Timestamp: time.Now(),
 		Data:      warningsBytes,
+		Sequence:  manager.replay.current(),
+		Epoch:     manager.replay.epoch,
 	}
 
 	// Marshal the envelope to JSON if you need to send it
@@ -326,7 +361,7 @@ func (manager *WarningManager) Subscribe(c *client) {
 		return
 	}
 
-	c.send <- envelopeBytes
+	s.client.send <- envelopeBytes
 
 	log.Debug().Int("size", len(warnings)).Msg("sent initial warning data to client")
 }
@@ -336,6 +371,27 @@ func (manager *WarningManager) Unsubscribe(c *client) {
 	defer manager.mu.Unlock()
 
 	delete(manager.subscribers, c)
+	manager.fences.remove(c)
+}
+
+// Send the envelope to every subscriber whose filter matches any of the warnings
+func (manager *WarningManager) send(envelope []byte, warnings ...*warning) {
+	// Subscribers with a geofence are only considered when it is near one of the warnings
+	nearby := map[*client]struct{}{}
+	for _, w := range warnings {
+		manager.fences.search(w.bounds(), nearby)
+	}
+
+	for client, filter := range manager.subscribers {
+		if filter != nil && filter.fence != nil {
+			if _, ok := nearby[client]; !ok {
+				continue
+			}
+		}
+		if filter.matchesAny(warnings) {
+			client.send <- envelope
+		}
+	}
 }
 
 func (manager *WarningManager) handleUpdate(warningDTO *warningDTO, eventType string) error {
@@ -381,6 +437,7 @@ func (manager *WarningManager) handleUpdate(warningDTO *warningDTO, eventType st
 		SpoutTag:       warningDTO.SpoutTag,
 		SnowSquall:     warningDTO.SnowSquall,
 		SnowSquallTag:  warningDTO.SnowSquallTag,
+		HVTEC:          warningDTO.HVTEC,
 	}
 
 	if len(warningDTO.Geom) > 0 {
@@ -433,6 +490,7 @@ func (manager *WarningManager) handleUpdate(warningDTO *warningDTO, eventType st
 			ugcs[ugc.Code] = *ugc
 		}
 	}
+	w.UGC = ugcs
 
 	warningBytes, err := json.Marshal(w)
 	if err != nil {
@@ -447,14 +505,18 @@ func (manager *WarningManager) handleUpdate(warningDTO *warningDTO, eventType st
 		Data:      warningBytes,
 	}
 
-	envelopeBytes, err := json.Marshal(envelope)
+	// Subscribers that had the warning also need to hear when it no longer matches their filter
+	subject := []*warning{w}
+	if previous, ok := manager.data[w.CompositeID()]; ok {
+		subject = append(subject, previous)
+	}
+
+	envelopeBytes, err := manager.replay.record(&envelope, subject)
 	if err != nil {
 		return err
 	}
 
-	for client := range manager.subscribers {
-		client.send <- envelopeBytes
-	}
+	manager.send(envelopeBytes, subject...)
 
 	// See if we have the warning already
 	if _, ok := manager.data[w.CompositeID()]; ok {
@@ -490,6 +552,7 @@ func (manager *WarningManager) checkExpired(t time.Time) {
 			warningBytes, err := json.Marshal(w)
 			if err != nil {
 				log.Error().Err(err).Msg("failed to marshal warning for expired warning")
+				continue
 			}
 
 			envelope := Envelope{
@@ -500,14 +563,13 @@ func (manager *WarningManager) checkExpired(t time.Time) {
 				Data:      warningBytes,
 			}
 
-			envelopeBytes, err := json.Marshal(envelope)
+			envelopeBytes, err := manager.replay.record(&envelope, []*warning{w})
 			if err != nil {
 				log.Error().Err(err).Msg("failed to marshal envelope for expired warning")
+				continue
 			}
 
-			for client := range manager.subscribers {
-				client.send <- envelopeBytes
-			}
+			manager.send(envelopeBytes, w)
 		}
 
 		log.Debug().Int("deleted", len(toDelete)).Msg("deleted expired warnings")
@@ -519,6 +581,10 @@ func (manager *WarningManager) scanWarning(row pgx.Row) (*warning, error) {
 	g := ewkb.MultiPolygon{}
 	locs := ewkb.MultiPoint{}
 	u := []string{}
+	var (
+		nwsli, severity, cause, record *string
+		begin, crest, end              *time.Time
+	)
 
 	w := warning{}
 
@@ -563,8 +629,27 @@ func (manager *WarningManager) scanWarning(row pgx.Row) (*warning, error) {
 		&w.SpoutTag,
 		&w.SnowSquall,
 		&w.SnowSquallTag,
+		&nwsli,
+		&severity,
+		&cause,
+		&begin,
+		&crest,
+		&end,
+		&record,
 	)
 
+	if nwsli != nil {
+		w.HVTEC = &hvtec{
+			NWSLI:    *nwsli,
+			Severity: deref(severity),
+			Cause:    deref(cause),
+			Begin:    begin,
+			Crest:    crest,
+			End:      end,
+			Record:   deref(record),
+		}
+	}
+
 	ugcs := map[string]UGC{}
 
 	for _, ugc := range u {
@@ -581,3 +666,11 @@ func (manager *WarningManager) scanWarning(row pgx.Row) (*warning, error) {
 
 	return &w, nil
 }
+
+// Returns the value of a nullable column or an empty string
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
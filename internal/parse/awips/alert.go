@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/shared/streaming"
+	zlog "github.com/rs/zerolog/log"
+)
+
+// How far the alert sent time may be from the issued time of the matching VTEC update
+const alertMatchWindow = 10 * time.Minute
+
+// Check an alert against the VTEC updates from the product stream. When none of the alert's
+// VTEC has been seen, the product segment is rebuilt from the alert and handled like any other
+// product so the event is filled in, with the product marked as backfilled.
+func HandleAlert(alert *streaming.Alert, receivedAt time.Time, db *pgxpool.Pool, stream streaming.Transport) error {
+	log := zlog.With().Str("alert", alert.ID).Str("awips", alert.AWIPS).Logger()
+
+	vtecs, errs := awips.ParseVTEC(strings.Join(alert.VTEC, "\n"))
+	if len(errs) != 0 {
+		log.Warn().Errs("errors", errs).Msg("failed to parse alert vtec")
+	}
+
+	found := []string{}
+	missing := []string{}
+	for _, vtec := range vtecs {
+		// The product handler ignores these too
+		if vtec.Class == "T" || vtec.Action == "ROU" {
+			continue
+		}
+
+		exists, err := vtecUpdateExists(db, vtec, alert.Sent)
+		if err != nil {
+			return fmt.Errorf("failed to find vtec update: %v", err.Error())
+		}
+		if exists {
+			found = append(found, vtec.Original)
+		} else {
+			missing = append(missing, vtec.Original)
+		}
+	}
+
+	switch {
+	case len(missing) == 0:
+		monitor.Alerts.WithLabelValues("matched").Inc()
+		log.Debug().Strs("vtec", found).Msg("alert matches the product stream")
+		return nil
+	case len(found) > 0:
+		// The product arrived but does not agree with the alert, so leave it as it is
+		monitor.Alerts.WithLabelValues("mismatch").Inc()
+		log.Warn().Strs("found", found).Strs("missing", missing).Msg("alert only partly matches the product stream")
+		return nil
+	}
+
+	text, err := alertText(alert)
+	if err != nil {
+		monitor.Alerts.WithLabelValues("skipped").Inc()
+		log.Warn().Err(err).Strs("missing", missing).Msg("cannot fill in vtec missing from the product stream")
+		return nil
+	}
+
+	monitor.Alerts.WithLabelValues("backfilled").Inc()
+	log.Warn().Strs("missing", missing).Msg("filling in vtec missing from the product stream")
+	handleText(text, receivedAt, db, stream, true)
+
+	return nil
+}
+
+// Whether there is an update for the VTEC action issued around the time
+func vtecUpdateExists(db *pgxpool.Pool, vtec awips.VTEC, issued time.Time) (bool, error) {
+	year := issued.Year()
+	if vtec.Start != nil {
+		year = vtec.Start.Year()
+	}
+
+	exists := false
+	err := db.QueryRow(context.Background(), `
+	SELECT EXISTS(SELECT 1 FROM vtec.updates WHERE wfo = $1 AND phenomena = $2 AND significance = $3
+	AND event_number = $4 AND year = $5 AND action = $6 AND issued BETWEEN $7 AND $8)
+	`, vtec.WFO, vtec.Phenomena, vtec.Significance, vtec.EventNumber, year, vtec.Action,
+		issued.Add(-alertMatchWindow), issued.Add(alertMatchWindow)).Scan(&exists)
+
+	return exists, err
+}
+
+// Rebuild the text of the product segment the alert came from
+func alertText(alert *streaming.Alert) (string, error) {
+	if alert.WMO == "" || alert.AWIPS == "" {
+		return "", errors.New("alert does not have the product's WMO heading and AWIPS identifier")
+	}
+	if alert.Expires.IsZero() {
+		return "", errors.New("alert does not expire")
+	}
+
+	ugc := alertUGC(alert.UGC, alert.Expires)
+	if ugc == "" {
+		return "", errors.New("alert does not have any UGC")
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s\n%s\n\n", alert.WMO, alert.AWIPS)
+	fmt.Fprintf(b, "%s\nNational Weather Service\n%s\n\n", alert.Event, alert.Sent.UTC().Format("1504 UTC Mon Jan 2 2006"))
+	fmt.Fprintf(b, "%s\n", ugc)
+	for _, vtec := range alert.VTEC {
+		fmt.Fprintf(b, "%s\n", vtec)
+	}
+	for _, paragraph := range []string{alert.Headline, alert.Description, alert.Instruction} {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			// Keep the segment together
+			fmt.Fprintf(b, "\n%s\n", strings.ReplaceAll(paragraph, "$$", ""))
+		}
+	}
+	if latlon := alertLatLon(alert.Polygon); latlon != "" {
+		fmt.Fprintf(b, "\n%s\n", latlon)
+	}
+	b.WriteString("\n$$\n")
+
+	return b.String(), nil
+}
+
+// Write UGC codes as a UGC line, such as IAC153-169-MNC001-192345-
+func alertUGC(codes []string, expires time.Time) string {
+	if len(codes) == 0 {
+		return ""
+	}
+
+	b := &strings.Builder{}
+	prefix := ""
+	for _, code := range codes {
+		if len(code) != 6 {
+			continue
+		}
+		// Codes in the same state and of the same type only need the number
+		if code[:3] != prefix {
+			prefix = code[:3]
+			b.WriteString(code)
+		} else {
+			b.WriteString(code[3:])
+		}
+		b.WriteString("-")
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	b.WriteString(expires.UTC().Format("021504") + "-")
+
+	return b.String()
+}
+
+// Write a polygon of longitude, latitude pairs as a LAT...LON line
+func alertLatLon(polygon [][2]float64) string {
+	// Text products leave out the closing point
+	if len(polygon) > 1 && polygon[0] == polygon[len(polygon)-1] {
+		polygon = polygon[:len(polygon)-1]
+	}
+	if len(polygon) < 3 {
+		return ""
+	}
+
+	points := []string{}
+	for _, point := range polygon {
+		// Longitudes are degrees west
+		lon := -point[0]
+		if lon < 0 {
+			lon += 360
+		}
+		points = append(points, fmt.Sprintf("%04d %04d", int(math.Round(point[1]*100)), int(math.Round(lon*100))))
+	}
+
+	return "LAT...LON " + strings.Join(points, " ")
+}
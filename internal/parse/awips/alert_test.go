@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAlert = &streaming.Alert{
+	ID:          "urn:oid:2.49.0.1.840.0.1",
+	Sent:        time.Date(2025, 5, 19, 23, 10, 0, 0, time.UTC),
+	Expires:     time.Date(2025, 5, 19, 23, 45, 0, 0, time.UTC),
+	Event:       "Tornado Warning",
+	WMO:         "WFUS53 KDMX 192310",
+	AWIPS:       "TORDMX",
+	VTEC:        []string{"/O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/"},
+	UGC:         []string{"IAC153", "IAC169", "MNC001"},
+	Polygon:     [][2]float64{{-93.63, 41.63}, {-93.52, 41.57}, {-93.70, 41.50}, {-93.63, 41.63}},
+	Description: "At 610 PM CDT, a severe thunderstorm capable of producing a tornado was located near Ames.",
+}
+
+func TestAlertText(t *testing.T) {
+	text, err := alertText(testAlert)
+	require.NoError(t, err)
+
+	product, err := awips.New(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, "TORDMX", product.AWIPS.Original)
+	assert.Equal(t, "KDMX", product.WMO.Office)
+	assert.True(t, product.Issued.Equal(testAlert.Sent))
+
+	require.Len(t, product.Segments, 1)
+	segment := product.Segments[0]
+
+	require.Len(t, segment.VTEC, 1)
+	assert.Equal(t, "NEW", segment.VTEC[0].Action)
+	assert.Equal(t, 42, segment.VTEC[0].EventNumber)
+
+	require.NotNil(t, segment.UGC)
+	require.Len(t, segment.UGC.States, 2)
+	assert.Equal(t, []string{"153", "169"}, segment.UGC.States[0].Areas)
+	assert.True(t, segment.Expires.Equal(testAlert.Expires))
+
+	assert.Contains(t, segment.Text, "LAT...LON 4163 9363 4157 9352 4150 9370")
+	assert.Contains(t, segment.Text, testAlert.Description)
+}
+
+func TestAlertTextWithoutProduct(t *testing.T) {
+	alert := *testAlert
+	alert.WMO = ""
+
+	_, err := alertText(&alert)
+	assert.Error(t, err)
+}
+
+func TestAlertUGC(t *testing.T) {
+	expires := time.Date(2025, 5, 19, 23, 45, 0, 0, time.UTC)
+
+	assert.Equal(t, "IAC153-169-MNC001-IAZ004-192345-", alertUGC([]string{"IAC153", "IAC169", "MNC001", "IAZ004"}, expires))
+	assert.Equal(t, "", alertUGC(nil, expires))
+}
+
+func TestAlertLatLon(t *testing.T) {
+	assert.Equal(t, "LAT...LON 4163 9363 4157 9352 4150 10170", alertLatLon([][2]float64{{-93.63, 41.63}, {-93.52, 41.57}, {-101.70, 41.50}}))
+	// West bias across the date line
+	assert.Equal(t, "LAT...LON 1320 18100 1330 17900 1310 17950", alertLatLon([][2]float64{{179, 13.2}, {-179, 13.3}, {-179.5, 13.1}}))
+	assert.Equal(t, "", alertLatLon([][2]float64{{-93.63, 41.63}}))
+}
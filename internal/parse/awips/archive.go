@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// When enabled every product is stored in the text archive, not only those matched by a route.
+// Set with the AWIPS_ARCHIVE_ALL environment variable.
+var archiveAll bool
+
+func loadArchiveConfig() {
+	value := os.Getenv("AWIPS_ARCHIVE_ALL")
+	if value == "" {
+		return
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warn().Str("value", value).Msg("invalid AWIPS_ARCHIVE_ALL value. Archiving routed products only")
+		return
+	}
+	archiveAll = enabled
+
+	if archiveAll {
+		log.Info().Msg("archiving all products")
+	}
+}
+
+// Store text that could not be parsed or archived so it can be inspected later
+func (handler *Handler) quarantine(text string, receivedAt time.Time, wmo string, awipsID string, reason error) {
+	if !archiveAll {
+		return
+	}
+
+	_, err := handler.db.Exec(context.Background(), `
+	INSERT INTO awips.quarantine (received_at, wmo, awips, error, data) VALUES
+	($1, $2, $3, $4, $5)
+	`, receivedAt, wmo, awipsID, reason.Error(), text)
+	if err != nil {
+		handler.log.Error().Err(err).Msg("failed to quarantine product")
+		return
+	}
+
+	handler.log.Debug().Err(reason).Msg("quarantined product")
+}
@@ -20,6 +20,8 @@ type awipsProduct struct {
 	WMO        string     `json:"wmo"`
 	AWIPS      string     `json:"awips"`
 	BBB        string     `json:"bbb"`
+	Backfilled bool       `json:"backfilled"` // Rebuilt from an alert that was missing from the product stream
+	Duplicate  bool       `json:"-"`          // Whether the stored text under the product ID is kept instead
 }
 
 // Create a new product ID based on the product's issuance time, office, WMO datatype, and AWIPS identifier.
@@ -61,19 +63,40 @@ func (handler *productHandler) Handle(product awips.Product, receivedAt time.Tim
 		WMO:        product.WMO.Datatype,
 		AWIPS:      product.AWIPS.Original,
 		BBB:        bbb,
+		Backfilled: handler.backfilled,
 	}
 
 	rows, err := handler.db.Query(context.Background(), `
-	INSERT INTO awips.products (product_id, received_at, issued, source, data, wmo, awips, bbb) VALUES
-	($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at;
-	`, id, awipsProduct.ReceivedAt, product.Issued, awipsProduct.Source, awipsProduct.Data, awipsProduct.WMO, awipsProduct.AWIPS, awipsProduct.BBB)
+	INSERT INTO awips.products (product_id, received_at, issued, source, data, wmo, awips, bbb, backfilled) VALUES
+	($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (product_id, issued) DO UPDATE SET received_at = EXCLUDED.received_at, data = EXCLUDED.data,
+	backfilled = EXCLUDED.backfilled
+	WHERE md5(products.data) <> md5(EXCLUDED.data) AND (products.backfilled OR NOT EXCLUDED.backfilled)
+	RETURNING id, created_at;
+	`, id, awipsProduct.ReceivedAt, product.Issued, awipsProduct.Source, awipsProduct.Data, awipsProduct.WMO, awipsProduct.AWIPS, awipsProduct.BBB, awipsProduct.Backfilled)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("no rows returned after creating awips product")
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		rows.Close()
+
+		// NWWS retransmits products so we use the stored product. Different text under the same
+		// ID replaces what was stored above, so only the same text gets here, or a product rebuilt
+		// from an alert when the received one is already stored, which is kept instead.
+		err = handler.db.QueryRow(context.Background(), `
+		SELECT id, created_at, md5(data) = md5($3) OR (NOT backfilled AND $4) FROM awips.products
+		WHERE product_id = $1 AND issued = $2
+		`, id, product.Issued, product.Text, awipsProduct.Backfilled).Scan(&awipsProduct.ID, &awipsProduct.CreatedAt, &awipsProduct.Duplicate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find duplicate awips product: %v", err.Error())
+		}
+
+		return awipsProduct, nil
 	}
 	err = rows.Scan(&awipsProduct.ID, &awipsProduct.CreatedAt)
 	if err != nil {
@@ -7,14 +7,19 @@ import (
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/metdatasystem/us/pkg/awips"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/metdatasystem/us/shared/streaming"
 	"github.com/rs/zerolog"
 	zlog "github.com/rs/zerolog/log"
 )
 
 var (
-	vtecRoute = regexp.MustCompile("(MWW|FFW|CFW|TCV|RFW|FFA|SVR|TOR|SVS|SMW|MWS|NPW|WCN|WSW|EWW|FLS|FLW)")
-	mcdRoute  = regexp.MustCompile("(SWOMCD)")
+	vtecRoute      = regexp.MustCompile("(MWW|FFW|CFW|TCV|RFW|FFA|SVR|TOR|SVS|SMW|MWS|NPW|WCN|WSW|EWW|FLS|FLW)")
+	mcdRoute       = regexp.MustCompile("(SWOMCD)")
+	watchRoute     = regexp.MustCompile("^(SEL|WWP|SAW|WOU)$")
+	lsrRoute       = regexp.MustCompile("^LSR$")
+	outlookRoute   = regexp.MustCompile("^PTS(DY[1-3]|D48)$")
+	tropicalRoute  = regexp.MustCompile("^(TCM|TCP|TCV)$")
+	statementRoute = regexp.MustCompile("^SPS$")
 )
 
 type Route struct {
@@ -32,27 +37,83 @@ var routes = []Route{
 		},
 		Handler: func(handler *Handler) HandlerFunc { return NewVTECHandler(handler) },
 	},
+	// Mesoscale Discussions
+	{
+		Name: "MCD Handler",
+		Match: func(product *awips.Product) bool {
+			return mcdRoute.MatchString(product.AWIPS.Original)
+		},
+		Handler: func(handler *Handler) HandlerFunc { return NewMCDHandler(handler) },
+	},
+	// SPC Watches
+	{
+		Name: "Watch Handler",
+		Match: func(product *awips.Product) bool {
+			return watchRoute.MatchString(product.AWIPS.Product)
+		},
+		Handler: func(handler *Handler) HandlerFunc { return NewWatchHandler(handler) },
+	},
+	// Local Storm Reports
+	{
+		Name: "LSR Handler",
+		Match: func(product *awips.Product) bool {
+			return lsrRoute.MatchString(product.AWIPS.Product)
+		},
+		Handler: func(handler *Handler) HandlerFunc { return NewLSRHandler(handler) },
+	},
+	// Convective Outlooks
+	{
+		Name: "Outlook Handler",
+		Match: func(product *awips.Product) bool {
+			return outlookRoute.MatchString(product.AWIPS.Original)
+		},
+		Handler: func(handler *Handler) HandlerFunc { return NewOutlookHandler(handler) },
+	},
+	// Tropical Cyclones
+	{
+		Name: "Tropical Handler",
+		Match: func(product *awips.Product) bool {
+			return tropicalRoute.MatchString(product.AWIPS.Product)
+		},
+		Handler: func(handler *Handler) HandlerFunc { return NewTropicalHandler(handler) },
+	},
+	// Non-VTEC Statements
+	{
+		Name: "Statement Handler",
+		Match: func(product *awips.Product) bool {
+			return statementRoute.MatchString(product.AWIPS.Product)
+		},
+		Handler: func(handler *Handler) HandlerFunc { return NewStatementHandler(handler) },
+	},
 }
 
 type Handler struct {
 	db        *pgxpool.Pool
-	rabbit    *amqp.Channel
+	stream    streaming.Transport
 	dbProduct *awipsProduct
 	product   *awips.Product
 	log       zerolog.Logger
+	// Whether the product was rebuilt from an alert instead of received
+	backfilled bool
 }
 
 type HandlerFunc interface {
 	Handle() error
 }
 
-func HandleText(text string, receivedAt time.Time, db *pgxpool.Pool, rabbit *amqp.Channel) {
+func HandleText(text string, receivedAt time.Time, db *pgxpool.Pool, stream streaming.Transport) {
+	handleText(text, receivedAt, db, stream, false)
+}
+
+func handleText(text string, receivedAt time.Time, db *pgxpool.Pool, stream streaming.Transport, backfilled bool) {
 
 	log := zlog.With().Logger()
 
 	product, err := awips.New(text)
 	if err != nil && err != awips.ErrCouldNotFindAWIPS {
 		log.Error().Err(err).Msg("failed to parse product")
+		handler := &Handler{db: db, log: log}
+		handler.quarantine(text, receivedAt, "", "", err)
 		return
 	}
 	if product.WMO.Original != "" {
@@ -63,16 +124,17 @@ func HandleText(text string, receivedAt time.Time, db *pgxpool.Pool, rabbit *amq
 	}
 
 	handler := &Handler{
-		db:      db,
-		rabbit:  rabbit,
-		log:     log,
-		product: product,
+		db:         db,
+		stream:     stream,
+		log:        log,
+		product:    product,
+		backfilled: backfilled,
 	}
 
 	handler.process(receivedAt)
 }
 
-func Handle(text string, receivedAt time.Time, wmo string, office string, awipsID string, db *pgxpool.Pool, rabbit *amqp.Channel) error {
+func Handle(text string, receivedAt time.Time, wmo string, office string, awipsID string, db *pgxpool.Pool, stream streaming.Transport) error {
 	log := zlog.With().Logger()
 
 	log = log.With().Str("awips", awipsID).Logger()
@@ -82,6 +144,8 @@ func Handle(text string, receivedAt time.Time, wmo string, office string, awipsI
 	if err != nil {
 		if err != awips.ErrCouldNotFindAWIPS {
 			log.Error().Err(err).Msg("failed to parse product")
+			handler := &Handler{db: db, log: log}
+			handler.quarantine(text, receivedAt, wmo, awipsID, err)
 			return fmt.Errorf("failed to parse product: %w", err)
 		}
 		if awipsID != "" {
@@ -98,7 +162,7 @@ func Handle(text string, receivedAt time.Time, wmo string, office string, awipsI
 
 	handler := &Handler{
 		db:      db,
-		rabbit:  rabbit,
+		stream:  stream,
 		log:     log,
 		product: product,
 	}
@@ -108,20 +172,48 @@ func Handle(text string, receivedAt time.Time, wmo string, office string, awipsI
 	return nil
 }
 
+// Store the product in the text archive
+func (handler *Handler) archive(receivedAt time.Time) error {
+	pHandler := productHandler{*handler}
+	dbproduct, err := pHandler.Handle(*handler.product, receivedAt)
+	if err != nil {
+		return err
+	}
+	handler.dbProduct = dbproduct
+
+	return nil
+}
+
 // Process the product matching it to any routes
 func (handler *Handler) process(receivedAt time.Time) {
 	product := handler.product
 
+	// Some products, like the SAW, do not carry an issued line so fall back to the WMO header
+	if product.Issued.IsZero() && !product.WMO.Issued.IsZero() {
+		product.Issued = awips.MergeDayTime(receivedAt, product.WMO.Issued)
+	}
+
+	if archiveAll {
+		if err := handler.archive(receivedAt); err != nil {
+			handler.log.Error().Err(err).Msg("failed to handle product")
+			handler.quarantine(product.Text, receivedAt, product.WMO.Datatype, product.AWIPS.Original, err)
+			return
+		}
+	}
+
 	for _, route := range routes {
 		if route.Match(product) {
 			if handler.dbProduct == nil {
-				pHandler := productHandler{*handler}
-				dbproduct, err := pHandler.Handle(*product, receivedAt)
-				if err != nil {
+				if err := handler.archive(receivedAt); err != nil {
 					handler.log.Error().Err(err).Msg("failed to handle product")
 					continue
 				}
-				handler.dbProduct = dbproduct
+			}
+			// Retransmissions of the same text, and rebuilt copies of received products, have
+			// already been handled
+			if handler.dbProduct.Duplicate {
+				handler.log.Debug().Str("product", handler.dbProduct.ProductID).Msg("skipping duplicate product")
+				return
 			}
 			h := route.Handler(handler)
 			err := h.Handle()
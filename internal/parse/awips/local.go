@@ -5,13 +5,14 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/metdatasystem/us/shared/streaming"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func Local(path string, logLevel zerolog.Level) {
 	zerolog.SetGlobalLevel(logLevel)
+	loadArchiveConfig()
 
 	db, err := newDatabasePool(os.Getenv("DATABASE_URL"))
 	if err != nil {
@@ -19,23 +20,18 @@ func Local(path string, logLevel zerolog.Level) {
 		return
 	}
 
-	rabbitChannel, err := newRabbitChannel()
+	stream, err := streaming.NewTransport(appID)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to initialise rabbit channel")
+		log.Error().Err(err).Msg("failed to initialise stream transport")
 		return
 	}
+	defer stream.Close()
 
-	err = initRabbit(rabbitChannel)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to initialise rabbit declarations")
-		return
-	}
-
-	process(path, db, rabbitChannel)
+	process(path, db, stream)
 
 }
 
-func process(path string, db *pgxpool.Pool, rabbit *amqp.Channel) {
+func process(path string, db *pgxpool.Pool, stream streaming.Transport) {
 	file, err := os.Open(path)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to open file")
@@ -57,14 +53,14 @@ func process(path string, db *pgxpool.Pool, rabbit *amqp.Channel) {
 		}
 
 		for _, f := range files {
-			process(path+f.Name(), db, rabbit)
+			process(path+f.Name(), db, stream)
 		}
 	} else {
-		processFile(file, stat.Size(), db, rabbit)
+		processFile(file, stat.Size(), db, stream)
 	}
 }
 
-func processFile(file *os.File, size int64, db *pgxpool.Pool, rabbit *amqp.Channel) {
+func processFile(file *os.File, size int64, db *pgxpool.Pool, stream streaming.Transport) {
 	data := make([]byte, size)
 	_, err := file.Read(data)
 	if err != nil {
@@ -73,6 +69,6 @@ func processFile(file *os.File, size int64, db *pgxpool.Pool, rabbit *amqp.Chann
 
 	text := string(data)
 
-	HandleText(text, time.Now(), db, rabbit)
+	HandleText(text, time.Now(), db, stream)
 	time.Sleep(10 * time.Second)
 }
@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/pkg/awips/products"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+)
+
+type lsr struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	Product   string    `json:"product"`
+	WFO       string    `json:"wfo"`
+	Valid     time.Time `json:"valid"`
+	Year      int       `json:"year"`
+	Event     string    `json:"event"`
+	Magnitude *float64  `json:"magnitude"`
+	Units     string    `json:"units,omitempty"`
+	Qualifier string    `json:"qualifier,omitempty"`
+	Location  string    `json:"location"`
+	County    string    `json:"county"`
+	State     string    `json:"state"`
+	Source    string    `json:"source"`
+	Remarks   string    `json:"remarks"`
+	Geom      []byte    `json:"geom"`
+}
+
+// Generates an ID using the database ID and year of the report.
+//
+// Example: LSR-1234-2025
+func (lsr *lsr) GenerateID() string {
+	return fmt.Sprintf("LSR-%d-%d", lsr.ID, lsr.Year)
+}
+
+type lsrHandler struct {
+	Handler
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func NewLSRHandler(handler *Handler) *lsrHandler {
+	return &lsrHandler{*handler, context.Background(), nil}
+}
+
+// Handle a Local Storm Report product. Every report in the product is stored and published individually.
+func (handler *lsrHandler) Handle() error {
+	product := handler.product
+	log := handler.log
+
+	parsed, err := products.ParseLSR(product.Text)
+	if err != nil {
+		return err
+	}
+
+	// Initialise transaction
+	handler.tx, err = handler.db.BeginTx(handler.ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err.Error())
+	}
+	defer handler.tx.Rollback(handler.ctx)
+
+	corrected := parsed.Corrected || product.IsCorrection()
+
+	type event struct {
+		eventType string
+		lsr       *lsr
+	}
+	events := []event{}
+	stored := []*lsr{}
+
+	for _, report := range parsed.Reports {
+		var point []byte
+		if report.Point != nil {
+			point, err = ewkb.Marshal(report.Point, ewkb.NDR)
+			if err != nil {
+				return fmt.Errorf("failed to marshal lsr point: %v", err.Error())
+			}
+		}
+
+		valid := report.Time.UTC()
+
+		l := &lsr{
+			Product:   handler.dbProduct.ProductID,
+			WFO:       product.Office,
+			Valid:     valid,
+			Year:      valid.Year(),
+			Event:     report.Event,
+			Magnitude: report.Magnitude,
+			Units:     report.Units,
+			Qualifier: report.Qualifier,
+			Location:  report.Location,
+			County:    report.County,
+			State:     report.State,
+			Source:    report.Source,
+			Remarks:   report.Remarks,
+			Geom:      point,
+		}
+
+		created, err := handler.upsert(l)
+		if err != nil {
+			return err
+		}
+
+		eventType := streaming.EventUpdate
+		if created {
+			eventType = streaming.EventNew
+		}
+		events = append(events, event{eventType, l})
+		stored = append(stored, l)
+	}
+
+	// A correction may move a report so the previous reports of the same event at the same time are replaced
+	if corrected {
+		removed, err := handler.removeCorrected(stored)
+		if err != nil {
+			return err
+		}
+		for _, r := range removed {
+			events = append(events, event{streaming.EventDelete, r})
+		}
+	}
+
+	if err := handler.tx.Commit(handler.ctx); err != nil {
+		return err
+	}
+
+	log.Debug().Int("reports", len(parsed.Reports)).Bool("summary", parsed.Summary).Bool("corrected", corrected).Msg("stored local storm reports")
+
+	for _, e := range events {
+		data, err := json.Marshal(e.lsr)
+		if err != nil {
+			return fmt.Errorf("failed to marshal lsr: %v", err.Error())
+		}
+
+		err = handler.publish(streaming.ProductLSR, e.lsr.GenerateID(), e.eventType, data)
+		if err != nil {
+			return fmt.Errorf("failed to publish lsr: %v", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Insert the report or update it if it has already been received. Returns whether the report was created.
+func (handler *lsrHandler) upsert(lsr *lsr) (bool, error) {
+	var created bool
+	err := handler.tx.QueryRow(handler.ctx, `
+	INSERT INTO lsr.reports(product, wfo, valid, year, event, magnitude, units, qualifier, location, county,
+	state, source, remarks, geom) VALUES
+	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, ST_GeomFromWKB($14, 4326))
+	ON CONFLICT (wfo, valid, event, location, year) DO UPDATE SET updated_at = CURRENT_TIMESTAMP,
+	product = EXCLUDED.product, magnitude = EXCLUDED.magnitude, units = EXCLUDED.units, qualifier = EXCLUDED.qualifier,
+	county = EXCLUDED.county, state = EXCLUDED.state, source = EXCLUDED.source, remarks = EXCLUDED.remarks, geom = EXCLUDED.geom
+	RETURNING id, created_at, updated_at, (xmax = 0)
+	`, lsr.Product, lsr.WFO, lsr.Valid, lsr.Year, lsr.Event, lsr.Magnitude, lsr.Units, lsr.Qualifier, lsr.Location,
+		lsr.County, lsr.State, lsr.Source, lsr.Remarks, lsr.Geom).Scan(&lsr.ID, &lsr.CreatedAt, &lsr.UpdatedAt, &created)
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert lsr: %v", err.Error())
+	}
+
+	return created, nil
+}
+
+// Remove the reports from earlier products of the same events and times as the corrected reports.
+// Reports the correction repeats were upserted with its product, so only those it left out are removed.
+func (handler *lsrHandler) removeCorrected(reports []*lsr) ([]*lsr, error) {
+	type key struct {
+		valid time.Time
+		event string
+	}
+	seen := map[key]bool{}
+	removed := []*lsr{}
+
+	for _, report := range reports {
+		k := key{report.Valid, report.Event}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		rows, err := handler.tx.Query(handler.ctx, `
+		DELETE FROM lsr.reports WHERE wfo = $1 AND valid = $2 AND event = $3 AND year = $4 AND product <> $5
+		RETURNING id, created_at, updated_at, product, wfo, valid, year, event, magnitude, COALESCE(units, ''),
+		COALESCE(qualifier, ''), location, COALESCE(county, ''), COALESCE(state, ''), COALESCE(source, ''),
+		COALESCE(remarks, ''), ST_AsEWKB(geom)
+		`, report.WFO, report.Valid, report.Event, report.Year, report.Product)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove corrected lsrs: %v", err.Error())
+		}
+
+		for rows.Next() {
+			r := &lsr{}
+			if err := rows.Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt, &r.Product, &r.WFO, &r.Valid, &r.Year, &r.Event,
+				&r.Magnitude, &r.Units, &r.Qualifier, &r.Location, &r.County, &r.State, &r.Source, &r.Remarks,
+				&r.Geom); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan corrected lsr: %v", err.Error())
+			}
+			r.WFO = strings.TrimSpace(r.WFO)
+			removed = append(removed, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove corrected lsrs: %v", err.Error())
+		}
+	}
+
+	return removed, nil
+}
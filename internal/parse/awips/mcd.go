@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/pkg/awips/products"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+)
+
+type mcd struct {
+	ID               int       `json:"id"` // The MCD number
+	CreatedAt        time.Time `json:"created_at,omitzero"`
+	UpdatedAt        time.Time `json:"updated_at,omitzero"`
+	Product          string    `json:"product"`
+	Issued           time.Time `json:"issued"`
+	Expires          time.Time `json:"expires"`
+	Year             int       `json:"year"`
+	Concerning       string    `json:"concerning"`
+	Geom             []byte    `json:"geom"`
+	WatchProbability *int      `json:"watch_probability"`
+	MostProbTornado  string    `json:"most_prob_tornado,omitempty"`
+	MostProbGust     string    `json:"most_prob_gust,omitempty"`
+	MostProbHail     string    `json:"most_prob_hail,omitempty"`
+	Text             string    `json:"text"` // Published to live consumers but not stored with the MCD
+}
+
+// Generates an ID using the MCD number and year.
+//
+// Example: MCD-0512-2025
+func (mcd *mcd) GenerateID() string {
+	return fmt.Sprintf("MCD-%04d-%d", mcd.ID, mcd.Year)
+}
+
+type mcdHandler struct {
+	Handler
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func NewMCDHandler(handler *Handler) *mcdHandler {
+	return &mcdHandler{*handler, context.Background(), nil}
+}
+
+// Handle a Mesoscale Discussion product
+func (handler *mcdHandler) Handle() error {
+	product := handler.product
+	log := handler.log
+
+	parsed, err := products.ParseMCD(product.Text)
+	if err != nil {
+		return err
+	}
+
+	polygon, err := ewkb.Marshal(&parsed.Polygon, ewkb.NDR)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mcd polygon: %v", err.Error())
+	}
+
+	// The valid times of the MCD only carry the day and time so we use the product issuance for the rest
+	issued := awips.MergeDayTime(product.Issued, parsed.Issued)
+	expires := awips.MergeDayTime(product.Issued, parsed.Expires)
+
+	var probability *int
+	if parsed.WatchProbability > 0 {
+		probability = &parsed.WatchProbability
+	}
+
+	mcd := &mcd{
+		ID:               parsed.Number,
+		Product:          handler.dbProduct.ProductID,
+		Issued:           issued,
+		Expires:          expires,
+		Year:             issued.Year(),
+		Concerning:       parsed.Concerning,
+		Geom:             polygon,
+		WatchProbability: probability,
+		MostProbTornado:  parsed.MostProbTornado,
+		MostProbGust:     parsed.MostProbGust,
+		MostProbHail:     parsed.MostProbHail,
+		Text:             product.Text,
+	}
+
+	log = log.With().Str("mcd", mcd.GenerateID()).Logger()
+
+	// Initialise transaction
+	handler.tx, err = handler.db.BeginTx(handler.ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err.Error())
+	}
+	defer handler.tx.Rollback(handler.ctx)
+
+	exists, err := handler.exists(mcd.ID, mcd.Year)
+	if err != nil {
+		return fmt.Errorf("failed to find mcd: %v", err.Error())
+	}
+
+	eventType := streaming.EventNew
+	if exists {
+		// SPC re-issues the same number when correcting a discussion, so the new product replaces the old one
+		if !product.IsCorrection() {
+			log.Warn().Msg("mcd number already exists but product is not a correction. Replacing existing mcd")
+		}
+		err = handler.update(mcd)
+		eventType = streaming.EventUpdate
+	} else {
+		err = handler.create(mcd)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := handler.tx.Commit(handler.ctx); err != nil {
+		return err
+	}
+
+	// A re-issued discussion that is no longer valid should be removed by consumers
+	if !mcd.Expires.After(product.Issued) {
+		eventType = streaming.EventDelete
+	}
+
+	data, err := json.Marshal(mcd)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mcd: %v", err.Error())
+	}
+
+	err = handler.publish(streaming.ProductMCD, mcd.GenerateID(), eventType, data)
+	if err != nil {
+		return fmt.Errorf("failed to publish mcd: %v", err.Error())
+	}
+
+	return nil
+}
+
+// Checks whether the MCD number has already been stored for the year
+func (handler *mcdHandler) exists(number int, year int) (bool, error) {
+	var exists bool
+	err := handler.tx.QueryRow(handler.ctx, `
+	SELECT EXISTS(SELECT 1 FROM mcd.mcd WHERE id = $1 AND year = $2)
+	`, number, year).Scan(&exists)
+
+	return exists, err
+}
+
+func (handler *mcdHandler) create(mcd *mcd) error {
+	err := handler.tx.QueryRow(handler.ctx, `
+	INSERT INTO mcd.mcd(id, product, issued, expires, year, concerning, geom, watch_probability,
+	most_prob_tornado, most_prob_gust, most_prob_hail) VALUES
+	($1, $2, $3, $4, $5, $6, ST_GeomFromWKB($7, 4326), $8, $9, $10, $11) RETURNING created_at, updated_at
+	`, mcd.ID, mcd.Product, mcd.Issued, mcd.Expires, mcd.Year, mcd.Concerning, mcd.Geom, mcd.WatchProbability,
+		mcd.MostProbTornado, mcd.MostProbGust, mcd.MostProbHail).Scan(&mcd.CreatedAt, &mcd.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert mcd: %v", err.Error())
+	}
+
+	return nil
+}
+
+func (handler *mcdHandler) update(mcd *mcd) error {
+	err := handler.tx.QueryRow(handler.ctx, `
+	UPDATE mcd.mcd SET updated_at = CURRENT_TIMESTAMP, product = $3, issued = $4, expires = $5, concerning = $6,
+	geom = ST_GeomFromWKB($7, 4326), watch_probability = $8, most_prob_tornado = $9, most_prob_gust = $10, most_prob_hail = $11
+	WHERE id = $1 AND year = $2 RETURNING created_at, updated_at
+	`, mcd.ID, mcd.Year, mcd.Product, mcd.Issued, mcd.Expires, mcd.Concerning, mcd.Geom, mcd.WatchProbability,
+		mcd.MostProbTornado, mcd.MostProbGust, mcd.MostProbHail).Scan(&mcd.CreatedAt, &mcd.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update mcd: %v", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/pkg/awips/products"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+)
+
+type outlookHandler struct {
+	Handler
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func NewOutlookHandler(handler *Handler) *outlookHandler {
+	return &outlookHandler{*handler, context.Background(), nil}
+}
+
+// Handle a convective outlook point product. Each day of the outlook is stored with the areas of each hazard.
+func (handler *outlookHandler) Handle() error {
+	product := handler.product
+	log := handler.log
+
+	outlook, err := products.ParseOutlook(product.Text)
+	if err != nil {
+		return err
+	}
+
+	// Initialise transaction
+	handler.tx, err = handler.db.BeginTx(handler.ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err.Error())
+	}
+	defer handler.tx.Rollback(handler.ctx)
+
+	for _, day := range outlook.Days {
+		// The valid times of the outlook only carry the day and time so we use the product issuance for the rest
+		starts := awips.MergeDayTime(product.Issued, day.Starts)
+		expires := starts.Add(day.Expires.Sub(day.Starts))
+		year := product.Issued.UTC().Year()
+
+		// Retransmissions replace the previously stored outlook
+		_, err = handler.tx.Exec(handler.ctx, `
+		DELETE FROM outlooks.outlooks WHERE product = $1 AND day = $2 AND year = $3
+		`, handler.dbProduct.ProductID, day.Day, year)
+		if err != nil {
+			return fmt.Errorf("failed to delete existing outlook: %v", err.Error())
+		}
+
+		var id int
+		err = handler.tx.QueryRow(handler.ctx, `
+		INSERT INTO outlooks.outlooks(product, issued, day, starts, expires, year) VALUES
+		($1, $2, $3, $4, $5, $6) RETURNING id
+		`, handler.dbProduct.ProductID, product.Issued, day.Day, starts, expires, year).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to insert outlook: %v", err.Error())
+		}
+
+		for _, area := range day.Areas {
+			polygon, err := ewkb.Marshal(area.Polygon, ewkb.NDR)
+			if err != nil {
+				return fmt.Errorf("failed to marshal outlook polygon: %v", err.Error())
+			}
+
+			_, err = handler.tx.Exec(handler.ctx, `
+			INSERT INTO outlooks.areas(outlook, year, hazard, threshold, geom) VALUES
+			($1, $2, $3, $4, ST_GeomFromWKB($5, 4326))
+			`, id, year, area.Hazard, area.Threshold, polygon)
+			if err != nil {
+				return fmt.Errorf("failed to insert outlook area: %v", err.Error())
+			}
+		}
+
+		log.Debug().Int("day", day.Day).Int("areas", len(day.Areas)).Msg("stored outlook")
+	}
+
+	return handler.tx.Commit(handler.ctx)
+}
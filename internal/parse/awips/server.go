@@ -11,7 +11,6 @@ import (
 	"github.com/metdatasystem/us/shared/streaming"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -33,7 +32,7 @@ var monitor = &Monitor{
 		Namespace: "us",
 		Subsystem: "parse",
 		Name:      "rabbitmq_ready",
-		Help:      "Indicates if the AWIPS parse server is connected to the RabbitMQ broker",
+		Help:      "Indicates if the AWIPS parse server is connected to the message broker",
 	}),
 	ReceivedMessages: prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: "us",
@@ -47,6 +46,12 @@ var monitor = &Monitor{
 		Name:      "processed_messages",
 		Help:      "How many messages the server has processed",
 	}, []string{"result"}),
+	Alerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "us",
+		Subsystem: "parse",
+		Name:      "alerts",
+		Help:      "How many alerts have been checked against the product stream",
+	}, []string{"result"}),
 	MessageProcessTime: prometheus.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "us",
 		Subsystem: "parse",
@@ -62,6 +67,7 @@ func init() {
 	prometheus.MustRegister(monitor.ReceivedMessages)
 	prometheus.MustRegister(monitor.ProcessedMessages)
 	prometheus.MustRegister(monitor.MessageProcessTime)
+	prometheus.MustRegister(monitor.Alerts)
 }
 
 func Server(logLevel zerolog.Level) {
@@ -69,6 +75,7 @@ func Server(logLevel zerolog.Level) {
 	defer stop()
 
 	zerolog.SetGlobalLevel(logLevel)
+	loadArchiveConfig()
 
 	db, err := newDatabasePool(os.Getenv("DATABASE_URL"))
 	if err != nil {
@@ -77,27 +84,17 @@ func Server(logLevel zerolog.Level) {
 	}
 	monitor.DBReady.Set(1)
 
-	rabbitChannel, err := newRabbitChannel()
+	stream, err := streaming.NewTransport(appID)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to initialise rabbit channel")
+		log.Error().Err(err).Msg("failed to initialise stream transport")
 		return
 	}
 
-	err = initRabbit(rabbitChannel)
+	messages, err := stream.ConsumeAWIPS(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to initialise rabbit declarations")
+		log.Error().Err(err).Msg("failed to consume awips products")
 		return
 	}
-
-	messages, err := rabbitChannel.Consume(
-		streaming.QueueAWIPS,
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
 	monitor.RabbitReady.Set(1)
 
 	go func() {
@@ -106,35 +103,56 @@ func Server(logLevel zerolog.Level) {
 		for message := range messages {
 			monitor.ReceivedMessages.Inc()
 
-			go func(message amqp.Delivery) {
+			go func(message streaming.Delivery) {
 				start := prometheus.NewTimer(monitor.MessageProcessTime)
 				defer start.ObserveDuration()
 
 				switch message.ContentType {
 				case "text/plain":
-					HandleText(string(message.Body), message.Timestamp, db, rabbitChannel)
+					HandleText(string(message.Body), message.Timestamp, db, stream)
 				case "application/json":
 					data := &streaming.AWIPSRaw{}
 					if err := data.Unmarshal(message.Body); err != nil {
 						log.Error().Err(err).Msg("failed to unmarshal awips raw")
 						monitor.ProcessedMessages.WithLabelValues("failure").Inc()
-						message.Nack(false, false)
+						message.Nack()
 						return
 					}
-					err := Handle(data.Text, message.Timestamp, data.TTAAII, data.CCCC, data.AWIPS, db, rabbitChannel)
+					err := Handle(data.Text, message.Timestamp, data.TTAAII, data.CCCC, data.AWIPS, db, stream)
 					if err != nil {
 						monitor.ProcessedMessages.WithLabelValues("failure").Inc()
-						message.Nack(false, false)
+						message.Nack()
+						return
+					}
+				case streaming.AlertContentType:
+					alert := &streaming.Alert{}
+					if err := alert.Unmarshal(message.Body); err != nil {
+						log.Error().Err(err).Msg("failed to unmarshal alert")
+						monitor.ProcessedMessages.WithLabelValues("failure").Inc()
+						message.Nack()
+						return
+					}
+					if err := HandleAlert(alert, message.Timestamp, db, stream); err != nil {
+						log.Error().Err(err).Str("alert", alert.ID).Msg("failed to handle alert")
+						monitor.ProcessedMessages.WithLabelValues("failure").Inc()
+						message.Nack()
 						return
 					}
 				}
 				monitor.ProcessedMessages.WithLabelValues("success").Inc()
-				message.Ack(false)
+				message.Ack()
 			}(message)
 		}
 
 	}()
 
+	go func() {
+		if err, ok := <-stream.Closed(); ok {
+			log.Error().Err(err).Msg("lost stream transport connection")
+			monitor.RabbitReady.Set(0)
+		}
+	}()
+
 	secondTimer := time.NewTimer(15 * time.Second)
 	minuteTimer := time.NewTimer(time.Minute)
 	go func() {
@@ -155,12 +173,6 @@ func Server(logLevel zerolog.Level) {
 					monitor.DBReady.Set(1)
 				}
 
-				if rabbitChannel.IsClosed() {
-					monitor.RabbitReady.Set(0)
-				} else {
-					monitor.RabbitReady.Set(1)
-				}
-
 				cancel()
 				minuteTimer.Reset(time.Minute)
 			}
@@ -178,9 +190,9 @@ func Server(logLevel zerolog.Level) {
 	monitor.RabbitReady.Set(0)
 
 	db.Close()
-	err = rabbitChannel.Close()
+	err = stream.Close()
 	if err != nil {
-		log.Error().Err(err).Msg("failed to close rabbit channel")
+		log.Error().Err(err).Msg("failed to close stream transport")
 	}
 }
 
@@ -194,6 +206,7 @@ type Monitor struct {
 	ReceivedMessages   prometheus.Counter
 	ProcessedMessages  *prometheus.CounterVec
 	MessageProcessTime prometheus.Histogram
+	Alerts             *prometheus.CounterVec
 }
 
 func serveMetrics() {
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+)
+
+// Statements do not carry VTEC so they are stored as warnings using this significance and class
+const (
+	statementSignificance = "S"
+	statementClass        = "O"
+)
+
+type statementProduct struct {
+	Phenomena string
+	Title     string
+}
+
+// Non-VTEC products with polygons that are stored alongside warnings, keyed by their AWIPS product
+var statementProducts = map[string]statementProduct{
+	"SPS": {Phenomena: "SP", Title: "Special Weather Statement"},
+}
+
+type statementHandler struct {
+	Handler
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func NewStatementHandler(handler *Handler) *statementHandler {
+	return &statementHandler{*handler, context.Background(), nil}
+}
+
+// Handle a non-VTEC statement. Each segment with a polygon is stored as a warning with a synthetic event number
+// and expires with the UGC line of the segment.
+func (handler *statementHandler) Handle() error {
+	product := handler.product
+	log := handler.log
+
+	statement, ok := statementProducts[product.AWIPS.Product]
+	if !ok {
+		return fmt.Errorf("unknown statement product %s", product.AWIPS.Product)
+	}
+
+	var err error
+
+	// Initialise transaction
+	handler.tx, err = handler.db.BeginTx(handler.ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err.Error())
+	}
+	defer handler.tx.Rollback(handler.ctx)
+
+	published := []*warning{}
+	deleted := []*warning{}
+	for _, segment := range product.Segments {
+		// Statements without a polygon are general statements and are not treated as alerts
+		if segment.UGC == nil || segment.LatLon == nil {
+			continue
+		}
+
+		w, err := newStatementWarning(product, &segment, statement, handler.dbProduct.ProductID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create statement")
+			continue
+		}
+
+		previous, err := handler.correct(w)
+		if err != nil {
+			return err
+		}
+		deleted = append(deleted, previous...)
+
+		if w.EventNumber == 0 {
+			// Other statements from the office may be stored at the same time, so hold a lock on the
+			// office's numbering until the transaction ends
+			lock := fmt.Sprintf("warnings.statement.%s.%s.%s.%d", w.WFO, w.Phenomena, w.Significance, w.Year)
+			_, err = handler.tx.Exec(handler.ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, lock)
+			if err != nil {
+				return fmt.Errorf("failed to lock statement event numbers: %v", err.Error())
+			}
+
+			err = handler.tx.QueryRow(handler.ctx, `
+			SELECT COALESCE(MAX(event_number), 0) + 1 FROM warnings.warnings
+			WHERE wfo = $1 AND phenomena = $2 AND significance = $3 AND year = $4
+			`, w.WFO, w.Phenomena, w.Significance, w.Year).Scan(&w.EventNumber)
+			if err != nil {
+				return fmt.Errorf("failed to get statement event number: %v", err.Error())
+			}
+		}
+
+		err = handler.tx.QueryRow(handler.ctx, `
+		INSERT INTO warnings.warnings(
+			issued, expires, ends, expires_initial, text, product,
+			wfo, action, current, class, phenomena, significance, event_number, year,
+			title, is_emergency, is_pds, geom, direction, location, speed, speed_text, tml_time,
+			ugc, hail_tag, wind_tag, spout_tag
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+			ST_GeomFromWKB($18, 4326), $19, ST_GeomFromWKB($20, 4326), $21, $22, $23, $24, $25, $26, $27
+		) RETURNING id
+		`, w.Issued, w.Expires, w.Ends, w.ExpiresInitial, w.Text, w.Product,
+			w.WFO, w.Action, w.Current, w.Class, w.Phenomena, w.Significance, w.EventNumber, w.Year,
+			w.Title, w.IsEmergency, w.IsPDS, w.Geom, w.Direction, w.Locations, w.Speed, w.SpeedText, w.TMLTime,
+			w.UGC, w.HailTag, w.WindTag, w.SpoutTag).Scan(&w.ID)
+		if err != nil {
+			return fmt.Errorf("failed to insert statement: %v", err.Error())
+		}
+
+		published = append(published, w)
+	}
+
+	if err := handler.tx.Commit(handler.ctx); err != nil {
+		return err
+	}
+
+	for _, w := range deleted {
+		if err := handler.publishStatement(w, streaming.EventDelete); err != nil {
+			log.Error().Err(err).Msg("failed to publish statement delete")
+		}
+	}
+	for _, w := range published {
+		if err := handler.publishStatement(w, streaming.EventNew); err != nil {
+			log.Error().Err(err).Msg("failed to publish statement")
+		}
+	}
+
+	log.Debug().Int("statements", len(published)).Msg("stored statements")
+
+	return nil
+}
+
+// Corrected statements replace the statement with the same issuance and UGCs, keeping its event number.
+// Returns the replaced statements so they can be removed from the live feed.
+func (handler *statementHandler) correct(w *warning) ([]*warning, error) {
+	rows, err := handler.tx.Query(handler.ctx, `
+	UPDATE warnings.warnings SET current = false, updated_at = CURRENT_TIMESTAMP
+	WHERE wfo = $1 AND phenomena = $2 AND significance = $3 AND year = $4 AND issued = $5 AND ugc = $6 AND current = true
+	RETURNING id, event_number
+	`, w.WFO, w.Phenomena, w.Significance, w.Year, w.Issued, w.UGC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update corrected statement: %v", err.Error())
+	}
+	defer rows.Close()
+
+	previous := []*warning{}
+	for rows.Next() {
+		old := *w
+		if err := rows.Scan(&old.ID, &old.EventNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan corrected statement: %v", err.Error())
+		}
+		old.Current = false
+		w.EventNumber = old.EventNumber
+		w.Action = "COR"
+		previous = append(previous, &old)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to update corrected statement: %v", err.Error())
+	}
+
+	return previous, nil
+}
+
+func (handler *statementHandler) publishStatement(w *warning, eventType string) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	return handler.publish(streaming.ProductWarning, w.GenerateID(), eventType, data)
+}
+
+// Create the warning for a statement segment. The event number is left for the database to assign.
+func newStatementWarning(product *awips.Product, segment *awips.ProductSegment, statement statementProduct, productID string) (*warning, error) {
+	polygon, err := segment.LatLon.ToMultiPolygon()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latlon polygon: %v", err.Error())
+	}
+	geom, err := ewkb.Marshal(polygon, ewkb.NDR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal polygon: %v", err.Error())
+	}
+
+	ugcList := []string{}
+	for _, state := range segment.UGC.States {
+		for _, area := range state.Areas {
+			ugcList = append(ugcList, state.ID+state.Type+area)
+		}
+	}
+
+	var (
+		direction *int
+		locations []byte
+		speed     *int
+		speedText *string
+		tmlTime   *time.Time
+	)
+	if segment.TML != nil {
+		direction = &segment.TML.Direction
+		locations, err = ewkb.Marshal(segment.TML.Locations, ewkb.NDR)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal locations: %v", err.Error())
+		}
+		speed = &segment.TML.Speed
+		speedText = &segment.TML.SpeedString
+		tmlTime = &segment.TML.Time
+	}
+
+	return &warning{
+		Issued:         product.Issued,
+		Expires:        segment.UGC.Expires,
+		Ends:           segment.UGC.Expires,
+		ExpiresInitial: segment.UGC.Expires,
+		Text:           segment.Text,
+		Product:        productID,
+		WFO:            product.Office,
+		Action:         "NEW",
+		Current:        true,
+		Class:          statementClass,
+		Phenomena:      statement.Phenomena,
+		Significance:   statementSignificance,
+		Year:           product.Issued.UTC().Year(),
+		Title:          statement.Title,
+		IsEmergency:    segment.IsEmergency(),
+		IsPDS:          segment.IsPDS(),
+		Geom:           geom,
+		Direction:      direction,
+		Locations:      locations,
+		Speed:          speed,
+		SpeedText:      speedText,
+		TMLTime:        tmlTime,
+		UGC:            ugcList,
+		HailTag:        segment.Tags["hail"],
+		WindTag:        segment.Tags["wind"],
+		SpoutTag:       segment.Tags["spout"],
+	}, nil
+}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+)
+
+const appID = "us.parse.awips"
+
+// Publish a live event. The product name is used to route it.
+func (handler *Handler) publish(product string, id string, eventType string, data []byte) error {
+	if handler.stream == nil {
+		handler.log.Warn().Str("product", product).Msg("handler missing stream transport. Not publishing event")
+		return nil
+	}
+
+	return handler.stream.PublishLive(context.Background(), product, streaming.Message{
+		ContentType: "application/json",
+		ID:          id,
+		Timestamp:   time.Now(),
+		Type:        eventType,
+		AppID:       appID,
+		Body:        data,
+	})
+}
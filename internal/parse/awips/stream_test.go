@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/shared/streaming"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	transport := streaming.NewMemoryTransport()
+	defer transport.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := transport.SubscribeLive(ctx, "live.warning", streaming.ProductWarning)
+	require.NoError(t, err)
+
+	handler := &Handler{stream: transport, log: zerolog.Nop()}
+	err = handler.publish(streaming.ProductWarning, "KDMX.TO.W.0042", streaming.EventNew, []byte(`{"id":1}`))
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, "application/json", event.ContentType)
+		assert.Equal(t, "KDMX.TO.W.0042", event.ID)
+		assert.Equal(t, streaming.EventNew, event.Type)
+		assert.Equal(t, appID, event.AppID)
+		assert.JSONEq(t, `{"id":1}`, string(event.Body))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
+
+func TestPublishWithoutStream(t *testing.T) {
+	handler := &Handler{log: zerolog.Nop()}
+	assert.NoError(t, handler.publish(streaming.ProductWarning, "KDMX.TO.W.0042", streaming.EventNew, nil))
+}
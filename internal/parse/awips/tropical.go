@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/pkg/awips/products"
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+)
+
+type tropicalHandler struct {
+	Handler
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func NewTropicalHandler(handler *Handler) *tropicalHandler {
+	return &tropicalHandler{*handler, context.Background(), nil}
+}
+
+// Handle a tropical cyclone product. Advisories and TCV segments are stored against the storm to build its timeline.
+func (handler *tropicalHandler) Handle() error {
+	var err error
+
+	// Initialise transaction
+	handler.tx, err = handler.db.BeginTx(handler.ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err.Error())
+	}
+	defer handler.tx.Rollback(handler.ctx)
+
+	switch handler.product.AWIPS.Product {
+	case "TCM":
+		err = handler.tcm()
+	case "TCP":
+		err = handler.tcp()
+	case "TCV":
+		err = handler.tcv()
+	default:
+		err = fmt.Errorf("unknown tropical product %s", handler.product.AWIPS.Product)
+	}
+	if err != nil {
+		return err
+	}
+
+	return handler.tx.Commit(handler.ctx)
+}
+
+func (handler *tropicalHandler) tcm() error {
+	product := handler.product
+	log := handler.log
+
+	tcm, err := products.ParseTCM(product.Text)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.upsertStorm(&tcm.Storm); err != nil {
+		return err
+	}
+
+	center, err := marshalPoint(tcm.Center)
+	if err != nil {
+		return err
+	}
+	windRadii, err := json.Marshal(tcm.WindRadii)
+	if err != nil {
+		return fmt.Errorf("failed to marshal wind radii: %v", err.Error())
+	}
+	var seaRadii *string
+	if tcm.SeaRadii != nil {
+		b, err := json.Marshal(tcm.SeaRadii)
+		if err != nil {
+			return fmt.Errorf("failed to marshal sea radii: %v", err.Error())
+		}
+		s := string(b)
+		seaRadii = &s
+	}
+
+	// The observation time only carries the day and time so we use the product issuance for the rest
+	observed := awips.MergeDayTime(product.Issued, tcm.Observed)
+
+	var id int
+	err = handler.tx.QueryRow(handler.ctx, `
+	INSERT INTO tropical.advisories(storm, advisory, issued, intermediate, name, classification, center, observed,
+	accuracy, movement_direction, movement_speed, pressure, eye_diameter, max_wind, gusts, wind_radii, sea_radii, tcm_product) VALUES
+	($1, $2, $3, $4, $5, $6, ST_GeomFromWKB($7, 4326), $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	ON CONFLICT (storm, advisory) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, issued = LEAST(advisories.issued, EXCLUDED.issued),
+	name = EXCLUDED.name, classification = EXCLUDED.classification, center = EXCLUDED.center, observed = EXCLUDED.observed,
+	accuracy = EXCLUDED.accuracy, movement_direction = EXCLUDED.movement_direction, movement_speed = EXCLUDED.movement_speed,
+	pressure = EXCLUDED.pressure, eye_diameter = EXCLUDED.eye_diameter, max_wind = EXCLUDED.max_wind, gusts = EXCLUDED.gusts,
+	wind_radii = EXCLUDED.wind_radii, sea_radii = EXCLUDED.sea_radii, tcm_product = EXCLUDED.tcm_product
+	RETURNING id
+	`, tcm.Storm.ID, tcm.Storm.Advisory, product.Issued, tcm.Storm.Intermediate, tcm.Storm.Name, tcm.Storm.Classification, center,
+		observed, tcm.Accuracy, tcm.MovementDirection, tcm.MovementSpeed, tcm.Pressure, tcm.EyeDiameter, tcm.MaxWind, tcm.Gusts,
+		string(windRadii), seaRadii, handler.dbProduct.ProductID).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to upsert advisory from tcm: %v", err.Error())
+	}
+
+	// Corrections replace the forecast track of the advisory
+	_, err = handler.tx.Exec(handler.ctx, `
+	DELETE FROM tropical.forecasts WHERE advisory = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete existing forecasts: %v", err.Error())
+	}
+
+	for _, forecast := range tcm.Forecasts {
+		center, err := marshalPoint(forecast.Center)
+		if err != nil {
+			return err
+		}
+		windRadii, err := json.Marshal(forecast.WindRadii)
+		if err != nil {
+			return fmt.Errorf("failed to marshal forecast wind radii: %v", err.Error())
+		}
+
+		_, err = handler.tx.Exec(handler.ctx, `
+		INSERT INTO tropical.forecasts(advisory, valid, outlook, status, center, max_wind, gusts, wind_radii) VALUES
+		($1, $2, $3, $4, ST_GeomFromWKB($5, 4326), $6, $7, $8)
+		`, id, awips.MergeDayTime(product.Issued, forecast.Valid), forecast.Outlook, forecast.Status, center,
+			forecast.MaxWind, forecast.Gusts, string(windRadii))
+		if err != nil {
+			return fmt.Errorf("failed to insert forecast: %v", err.Error())
+		}
+	}
+
+	log.Debug().Str("storm", tcm.Storm.ID).Str("advisory", tcm.Storm.Advisory).Int("forecasts", len(tcm.Forecasts)).Msg("stored tcm")
+
+	return nil
+}
+
+func (handler *tropicalHandler) tcp() error {
+	product := handler.product
+
+	tcp, err := products.ParseTCP(product.Text)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.upsertStorm(&tcp.Storm); err != nil {
+		return err
+	}
+
+	center, err := marshalPoint(tcp.Center)
+	if err != nil {
+		return err
+	}
+
+	// Intermediate advisories only have a public advisory, so it provides the position of the storm
+	_, err = handler.tx.Exec(handler.ctx, `
+	INSERT INTO tropical.advisories(storm, advisory, issued, intermediate, name, classification, center,
+	movement_direction, movement_speed_mph, pressure, max_wind_mph, tcp_product) VALUES
+	($1, $2, $3, $4, $5, $6, ST_GeomFromWKB($7, 4326), $8, $9, $10, $11, $12)
+	ON CONFLICT (storm, advisory) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, issued = LEAST(advisories.issued, EXCLUDED.issued),
+	name = EXCLUDED.name, classification = EXCLUDED.classification, center = COALESCE(advisories.center, EXCLUDED.center),
+	movement_direction = COALESCE(advisories.movement_direction, EXCLUDED.movement_direction),
+	movement_speed_mph = EXCLUDED.movement_speed_mph, pressure = COALESCE(advisories.pressure, EXCLUDED.pressure),
+	max_wind_mph = EXCLUDED.max_wind_mph, tcp_product = EXCLUDED.tcp_product
+	`, tcp.Storm.ID, tcp.Storm.Advisory, product.Issued, tcp.Storm.Intermediate, tcp.Storm.Name, tcp.Storm.Classification,
+		center, tcp.MovementDirection, tcp.MovementSpeed, tcp.Pressure, tcp.MaxWind, handler.dbProduct.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert advisory from tcp: %v", err.Error())
+	}
+
+	return nil
+}
+
+func (handler *tropicalHandler) tcv() error {
+	product := handler.product
+	log := handler.log
+
+	storm, err := products.ParseTropicalStorm(product.Text)
+	if err != nil {
+		return err
+	}
+
+	if err := handler.upsertStorm(storm); err != nil {
+		return err
+	}
+
+	// Retransmissions replace the previously stored segments
+	_, err = handler.tx.Exec(handler.ctx, `
+	DELETE FROM tropical.tcv WHERE product = $1
+	`, handler.dbProduct.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to delete existing tcv segments: %v", err.Error())
+	}
+
+	count := 0
+	for _, segment := range product.Segments {
+		if segment.UGC == nil {
+			continue
+		}
+
+		local := products.ParseTCVSegment(segment.Text)
+		// The NHC breakpoint segments only carry VTEC
+		if local == nil && !segment.HasVTEC() {
+			continue
+		}
+		if local == nil {
+			local = &products.TCVSegment{Hazards: map[string]*products.TCVHazard{}}
+		}
+
+		vtecs := []string{}
+		for _, v := range segment.VTEC {
+			vtecs = append(vtecs, v.Original)
+		}
+
+		ugcs := []string{}
+		for _, state := range segment.UGC.States {
+			for _, area := range state.Areas {
+				ugcs = append(ugcs, state.ID+state.Type+area)
+			}
+		}
+
+		wind := hazardOrEmpty(local, products.TCVWind)
+		surge := hazardOrEmpty(local, products.TCVStormSurge)
+		rain := hazardOrEmpty(local, products.TCVFloodingRain)
+		tornado := hazardOrEmpty(local, products.TCVTornadoes)
+
+		_, err = handler.tx.Exec(handler.ctx, `
+		INSERT INTO tropical.tcv(storm, advisory, issued, wfo, product, vtec, ugc, locations,
+		wind_forecast, wind_peak, wind_threat, surge_forecast, surge_peak, surge_threat,
+		rain_forecast, rain_peak, rain_threat, tornado_forecast, tornado_threat) VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		`, storm.ID, storm.Advisory, product.Issued, product.Office, handler.dbProduct.ProductID, vtecs, ugcs, local.Locations,
+			wind.Forecast, wind.Peak, wind.Threat, surge.Forecast, surge.Peak, surge.Threat,
+			rain.Forecast, rain.Peak, rain.Threat, tornado.Forecast, tornado.Threat)
+		if err != nil {
+			return fmt.Errorf("failed to insert tcv segment: %v", err.Error())
+		}
+		count++
+	}
+
+	log.Debug().Str("storm", storm.ID).Str("advisory", storm.Advisory).Int("segments", count).Msg("stored tcv")
+
+	return nil
+}
+
+// Create the storm or update its name and classification from the latest advisory
+func (handler *tropicalHandler) upsertStorm(storm *products.TropicalStorm) error {
+	_, err := handler.tx.Exec(handler.ctx, `
+	INSERT INTO tropical.storms(id, basin, number, year, name, classification) VALUES
+	($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (id) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, name = EXCLUDED.name, classification = EXCLUDED.classification
+	`, storm.ID, storm.Basin, storm.Number, storm.Year, storm.Name, storm.Classification)
+	if err != nil {
+		return fmt.Errorf("failed to upsert storm: %v", err.Error())
+	}
+
+	return nil
+}
+
+func hazardOrEmpty(segment *products.TCVSegment, hazard string) *products.TCVHazard {
+	if h, ok := segment.Hazards[hazard]; ok {
+		return h
+	}
+	return &products.TCVHazard{}
+}
+
+func marshalPoint(point *geom.Point) ([]byte, error) {
+	if point == nil {
+		return nil, nil
+	}
+	b, err := ewkb.Marshal(point, ewkb.NDR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal point: %v", err.Error())
+	}
+	return b, nil
+}
@@ -32,43 +32,44 @@ type vtecEvent struct {
 }
 
 type vtecUpdate struct {
-	ID            int        `json:"id"`
-	CreatedAt     time.Time  `json:"created_at,omitempty"`
-	Issued        time.Time  `json:"issued"`
-	Starts        *time.Time `json:"starts,omitempty"`
-	Expires       time.Time  `json:"expires"`
-	Ends          time.Time  `json:"ends,omitempty"`
-	Text          string     `json:"text"`
-	Product       string     `json:"product"`
-	WFO           string     `json:"wfo"`
-	Action        string     `json:"action"`
-	Class         string     `json:"class"`
-	Phenomena     string     `json:"phenomena"`
-	Significance  string     `json:"significance"`
-	EventNumber   int        `json:"event_number"`
-	Year          int        `json:"year"`
-	Title         string     `json:"title"`
-	IsEmergency   bool       `json:"is_emergency"`
-	IsPDS         bool       `json:"is_pds"`
-	Geom          []byte     `json:"geom,omitempty"`
-	Direction     *int       `json:"direction"`
-	Location      []byte     `json:"location,omitempty"`
-	Speed         *int       `json:"speed"`
-	SpeedText     *string    `json:"speed_text"`
-	TMLTime       *time.Time `json:"tml_time"`
-	UGC           []string   `json:"ugc"`
-	Tornado       string     `json:"tornado,omitempty"`
-	Damage        string     `json:"damage,omitempty"`
-	HailThreat    string     `json:"hail_threat,omitempty"`
-	HailTag       string     `json:"hail_tag,omitempty"`
-	WindThreat    string     `json:"wind_threat,omitempty"`
-	WindTag       string     `json:"wind_tag,omitempty"`
-	FlashFlood    string     `json:"flash_flood,omitempty"`
-	RainfallTag   string     `json:"rainfall_tag,omitempty"`
-	FloodTagDam   string     `json:"flood_tag_dam,omitempty"`
-	SpoutTag      string     `json:"spout_tag,omitempty"`
-	SnowSquall    string     `json:"snow_squall,omitempty"`
-	SnowSquallTag string     `json:"snow_squall_tag,omitempty"`
+	ID            int          `json:"id"`
+	CreatedAt     time.Time    `json:"created_at,omitempty"`
+	Issued        time.Time    `json:"issued"`
+	Starts        *time.Time   `json:"starts,omitempty"`
+	Expires       time.Time    `json:"expires"`
+	Ends          time.Time    `json:"ends,omitempty"`
+	Text          string       `json:"text"`
+	Product       string       `json:"product"`
+	WFO           string       `json:"wfo"`
+	Action        string       `json:"action"`
+	Class         string       `json:"class"`
+	Phenomena     string       `json:"phenomena"`
+	Significance  string       `json:"significance"`
+	EventNumber   int          `json:"event_number"`
+	Year          int          `json:"year"`
+	Title         string       `json:"title"`
+	IsEmergency   bool         `json:"is_emergency"`
+	IsPDS         bool         `json:"is_pds"`
+	Geom          []byte       `json:"geom,omitempty"`
+	Direction     *int         `json:"direction"`
+	Location      []byte       `json:"location,omitempty"`
+	Speed         *int         `json:"speed"`
+	SpeedText     *string      `json:"speed_text"`
+	TMLTime       *time.Time   `json:"tml_time"`
+	UGC           []string     `json:"ugc"`
+	Tornado       string       `json:"tornado,omitempty"`
+	Damage        string       `json:"damage,omitempty"`
+	HailThreat    string       `json:"hail_threat,omitempty"`
+	HailTag       string       `json:"hail_tag,omitempty"`
+	WindThreat    string       `json:"wind_threat,omitempty"`
+	WindTag       string       `json:"wind_tag,omitempty"`
+	FlashFlood    string       `json:"flash_flood,omitempty"`
+	RainfallTag   string       `json:"rainfall_tag,omitempty"`
+	FloodTagDam   string       `json:"flood_tag_dam,omitempty"`
+	SpoutTag      string       `json:"spout_tag,omitempty"`
+	SnowSquall    string       `json:"snow_squall,omitempty"`
+	SnowSquallTag string       `json:"snow_squall_tag,omitempty"`
+	HVTEC         *awips.HVTEC `json:"hvtec,omitempty"`
 }
 
 type vtecUGC struct {
@@ -454,22 +455,28 @@ func (handler *vtecHandler) createUpdate(segment *awips.ProductSegment, event *v
 		SpoutTag:      segment.Tags["spout"],
 		SnowSquall:    segment.Tags["snowSquall"],
 		SnowSquallTag: segment.Tags["snowSquallImpact"],
+		HVTEC:         segment.HVTEC,
 	}
 
+	hvtec := newHVTECColumns(update.HVTEC)
+
 	_, err = handler.tx.Exec(handler.ctx, `
 	INSERT INTO vtec.updates(issued, starts, expires, ends, text, product, 
 	wfo, action, class, phenomena, significance, event_number, year, title, 
 	is_emergency, is_pds, geom, direction, location, speed, speed_text, tml_time, 
 	ugc, tornado, damage, hail_threat, hail_tag, wind_threat, wind_tag, flash_flood, 
-	rainfall_tag, flood_tag_dam, spout_tag, snow_squall, snow_squall_tag)
+	rainfall_tag, flood_tag_dam, spout_tag, snow_squall, snow_squall_tag,
+	nwsli, flood_severity, flood_cause, flood_begin, flood_crest, flood_end, flood_record)
 	VALUES
 	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, ST_GeomFromWKB($17, 4326), $18, 
-	ST_GeomFromWKB($19, 4326), $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35)
+	ST_GeomFromWKB($19, 4326), $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35,
+	$36, $37, $38, $39, $40, $41, $42)
 	`, update.Issued, update.Starts, update.Expires, update.Ends, update.Text, update.Product,
 		update.WFO, update.Action, update.Class, update.Phenomena, update.Significance, update.EventNumber, update.Year, update.Title,
 		update.IsEmergency, update.IsPDS, update.Geom, update.Direction, update.Location, update.Speed, update.SpeedText, update.TMLTime,
 		update.UGC, update.Tornado, update.Damage, update.HailThreat, update.HailTag, update.WindThreat, update.WindTag, update.FlashFlood,
-		update.RainfallTag, update.FloodTagDam, update.SpoutTag, update.SnowSquall, update.SnowSquallTag)
+		update.RainfallTag, update.FloodTagDam, update.SpoutTag, update.SnowSquall, update.SnowSquallTag,
+		hvtec.NWSLI, hvtec.Severity, hvtec.Cause, hvtec.Begin, hvtec.Crest, hvtec.End, hvtec.Record)
 	if err != nil {
 		return fmt.Errorf("vtec update query failed: %v", err.Error())
 	}
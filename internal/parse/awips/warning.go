@@ -1,59 +1,84 @@
 package internal
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/metdatasystem/us/pkg/awips"
 	"github.com/metdatasystem/us/shared/streaming"
-	"github.com/rabbitmq/amqp091-go"
 	"github.com/rs/zerolog/log"
 	"github.com/twpayne/go-geom/encoding/ewkb"
 )
 
 type warning struct {
-	ID             int        `json:"id"`
-	Phenomena      string     `json:"phenomena"`
-	Significance   string     `json:"significance"`
-	WFO            string     `json:"wfo"`
-	EventNumber    int        `json:"event_number"`
-	Year           int        `json:"year"`
-	Action         string     `json:"action"`
-	Current        bool       `json:"current"`
-	CreatedAt      time.Time  `json:"created_at,omitzero"`
-	UpdatedAt      time.Time  `json:"updated_at,omitzero"`
-	Issued         time.Time  `json:"issued"`
-	Starts         *time.Time `json:"starts,omitzero"`
-	Expires        time.Time  `json:"expires"`
-	ExpiresInitial time.Time  `json:"expires_initial,omitzero"`
-	Ends           time.Time  `json:"ends,omitzero"`
-	Class          string     `json:"class"`
-	Title          string     `json:"title"`
-	IsEmergency    bool       `json:"is_emergency"`
-	IsPDS          bool       `json:"is_pds"`
-	Text           string     `json:"text"`
-	Product        string     `json:"product"`
-	Geom           []byte     `json:"geom"`
-	Direction      *int       `json:"direction"`
-	Locations      []byte     `json:"locations"`
-	Speed          *int       `json:"speed"`
-	SpeedText      *string    `json:"speed_text"`
-	TMLTime        *time.Time `json:"tml_time"`
-	UGC            []string   `json:"ugc"`
-	Tornado        string     `json:"tornado,omitempty"`
-	Damage         string     `json:"damage,omitempty"`
-	HailThreat     string     `json:"hail_threat,omitempty"`
-	HailTag        string     `json:"hail_tag,omitempty"`
-	WindThreat     string     `json:"wind_threat,omitempty"`
-	WindTag        string     `json:"wind_tag,omitempty"`
-	FlashFlood     string     `json:"flash_flood,omitempty"`
-	RainfallTag    string     `json:"rainfall_tag,omitempty"`
-	FloodTagDam    string     `json:"flood_tag_dam,omitempty"`
-	SpoutTag       string     `json:"spout_tag,omitempty"`
-	SnowSquall     string     `json:"snow_squall,omitempty"`
-	SnowSquallTag  string     `json:"snow_squall_tag,omitempty"`
+	ID             int          `json:"id"`
+	Phenomena      string       `json:"phenomena"`
+	Significance   string       `json:"significance"`
+	WFO            string       `json:"wfo"`
+	EventNumber    int          `json:"event_number"`
+	Year           int          `json:"year"`
+	Action         string       `json:"action"`
+	Current        bool         `json:"current"`
+	CreatedAt      time.Time    `json:"created_at,omitzero"`
+	UpdatedAt      time.Time    `json:"updated_at,omitzero"`
+	Issued         time.Time    `json:"issued"`
+	Starts         *time.Time   `json:"starts,omitzero"`
+	Expires        time.Time    `json:"expires"`
+	ExpiresInitial time.Time    `json:"expires_initial,omitzero"`
+	Ends           time.Time    `json:"ends,omitzero"`
+	Class          string       `json:"class"`
+	Title          string       `json:"title"`
+	IsEmergency    bool         `json:"is_emergency"`
+	IsPDS          bool         `json:"is_pds"`
+	Text           string       `json:"text"`
+	Product        string       `json:"product"`
+	Geom           []byte       `json:"geom"`
+	Direction      *int         `json:"direction"`
+	Locations      []byte       `json:"locations"`
+	Speed          *int         `json:"speed"`
+	SpeedText      *string      `json:"speed_text"`
+	TMLTime        *time.Time   `json:"tml_time"`
+	UGC            []string     `json:"ugc"`
+	Tornado        string       `json:"tornado,omitempty"`
+	Damage         string       `json:"damage,omitempty"`
+	HailThreat     string       `json:"hail_threat,omitempty"`
+	HailTag        string       `json:"hail_tag,omitempty"`
+	WindThreat     string       `json:"wind_threat,omitempty"`
+	WindTag        string       `json:"wind_tag,omitempty"`
+	FlashFlood     string       `json:"flash_flood,omitempty"`
+	RainfallTag    string       `json:"rainfall_tag,omitempty"`
+	FloodTagDam    string       `json:"flood_tag_dam,omitempty"`
+	SpoutTag       string       `json:"spout_tag,omitempty"`
+	SnowSquall     string       `json:"snow_squall,omitempty"`
+	SnowSquallTag  string       `json:"snow_squall_tag,omitempty"`
+	HVTEC          *awips.HVTEC `json:"hvtec,omitempty"` // River flood warnings carry their forecast point, severity and crest
+}
+
+// The H-VTEC of a warning or update as nullable database columns
+type hvtecColumns struct {
+	NWSLI    *string
+	Severity *string
+	Cause    *string
+	Begin    *time.Time
+	Crest    *time.Time
+	End      *time.Time
+	Record   *string
+}
+
+func newHVTECColumns(hvtec *awips.HVTEC) hvtecColumns {
+	if hvtec == nil {
+		return hvtecColumns{}
+	}
+	return hvtecColumns{
+		NWSLI:    &hvtec.NWSLI,
+		Severity: &hvtec.Severity,
+		Cause:    &hvtec.Cause,
+		Begin:    hvtec.Begin,
+		Crest:    hvtec.Crest,
+		End:      hvtec.End,
+		Record:   &hvtec.Record,
+	}
 }
 
 // Generates an ID using the warning's WFO, phenomena, significance, event number, and year.
@@ -177,6 +202,7 @@ func (handler *vtecHandler) warning(segment *awips.ProductSegment, event *vtecEv
 		SpoutTag:       segment.Tags["spout"],
 		SnowSquall:     segment.Tags["snowSquall"],
 		SnowSquallTag:  segment.Tags["snowSquallImpact"],
+		HVTEC:          segment.HVTEC,
 	}
 
 	if _, ok := handler.publishedWarnings[warning.GenerateID()]; !ok {
@@ -204,20 +230,7 @@ func (handler *vtecHandler) warning(segment *awips.ProductSegment, event *vtecEv
 				continue
 			}
 
-			err = handler.rabbit.PublishWithContext(context.Background(),
-				streaming.ExchangeLiveName,
-				"warning",
-				false,
-				false,
-				amqp091.Publishing{
-					ContentType: "application/json",
-					MessageId:   tempW.GenerateCompositeID(),
-					Timestamp:   time.Now(),
-					Type:        streaming.EventDelete,
-					AppId:       "us.parse.awips",
-					Body:        data,
-				},
-			)
+			err = handler.publish(streaming.ProductWarning, tempW.GenerateCompositeID(), streaming.EventDelete, data)
 			if err != nil {
 				log.Error().Err(err).Msg("failed to publish warning delete")
 				continue
@@ -235,16 +248,20 @@ func (handler *vtecHandler) warning(segment *awips.ProductSegment, event *vtecEv
 		current = false
 	}
 
+	hvtec := newHVTECColumns(warning.HVTEC)
+
 	rows, err := handler.tx.Query(handler.ctx, `
        INSERT INTO warnings.warnings(
 	       issued, starts, expires, ends, expires_initial, text, product, 
 		   wfo, action, current, class, phenomena, significance, event_number, year, 
 		   title, is_emergency, is_pds, geom, direction, location, speed, speed_text, tml_time, 
 		   ugc, tornado, damage, hail_threat, hail_tag, wind_threat, wind_tag, flash_flood, 
-		   rainfall_tag, flood_tag_dam, spout_tag, snow_squall, snow_squall_tag
+		   rainfall_tag, flood_tag_dam, spout_tag, snow_squall, snow_squall_tag,
+		   nwsli, flood_severity, flood_cause, flood_begin, flood_crest, flood_end, flood_record
        ) VALUES (
 	       $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, 
-		   ST_GeomFromWKB($19, 4326), $20, ST_GeomFromWKB($21, 4326), $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37
+		   ST_GeomFromWKB($19, 4326), $20, ST_GeomFromWKB($21, 4326), $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37,
+		   $38, $39, $40, $41, $42, $43, $44
        ) RETURNING id
        `,
 		warning.Issued,
@@ -284,6 +301,13 @@ func (handler *vtecHandler) warning(segment *awips.ProductSegment, event *vtecEv
 		warning.SpoutTag,
 		warning.SnowSquall,
 		warning.SnowSquallTag,
+		hvtec.NWSLI,
+		hvtec.Severity,
+		hvtec.Cause,
+		hvtec.Begin,
+		hvtec.Crest,
+		hvtec.End,
+		hvtec.Record,
 	)
 	if err != nil {
 		return err
@@ -302,8 +326,8 @@ func (handler *vtecHandler) warning(segment *awips.ProductSegment, event *vtecEv
 		return fmt.Errorf("failed to insert warning: %v", err.Error())
 	}
 
-	if handler.rabbit == nil {
-		log.Warn().Msg("handler missing RabbitMQ channel. Not publishing warning")
+	if handler.stream == nil {
+		log.Warn().Msg("handler missing stream transport. Not publishing warning")
 		return nil
 	}
 
@@ -334,18 +358,5 @@ func (handler *vtecHandler) publishWarning(warning *warning, eventType string) e
 		return err
 	}
 
-	return handler.rabbit.PublishWithContext(context.Background(),
-		streaming.ExchangeLiveName,
-		"warning",
-		false,
-		false,
-		amqp091.Publishing{
-			ContentType: "application/json",
-			MessageId:   warning.GenerateID(),
-			Timestamp:   time.Now(),
-			Type:        eventType,
-			AppId:       "us.parse.awips",
-			Body:        data,
-		},
-	)
+	return handler.publish(streaming.ProductWarning, warning.GenerateID(), eventType, data)
 }
@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/metdatasystem/us/pkg/awips/products"
+	"github.com/twpayne/go-geom/encoding/ewkb"
+)
+
+type watchHandler struct {
+	Handler
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func NewWatchHandler(handler *Handler) *watchHandler {
+	return &watchHandler{*handler, context.Background(), nil}
+}
+
+// Handle an SPC watch product. Each of the SEL, WWP, SAW and WOU products fill in their part of the watch record.
+func (handler *watchHandler) Handle() error {
+	var err error
+
+	// Initialise transaction
+	handler.tx, err = handler.db.BeginTx(handler.ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err.Error())
+	}
+	defer handler.tx.Rollback(handler.ctx)
+
+	switch handler.product.AWIPS.Product {
+	case "SEL":
+		err = handler.sel()
+	case "WWP":
+		err = handler.wwp()
+	case "SAW":
+		err = handler.saw()
+	case "WOU":
+		err = handler.wou()
+	default:
+		err = fmt.Errorf("unknown watch product %s", handler.product.AWIPS.Product)
+	}
+	if err != nil {
+		return err
+	}
+
+	return handler.tx.Commit(handler.ctx)
+}
+
+func (handler *watchHandler) sel() error {
+	product := handler.product
+
+	sel, err := products.ParseSEL(product.Text)
+	if err != nil {
+		return err
+	}
+
+	year, err := handler.watchYear(sel.Number)
+	if err != nil {
+		return err
+	}
+
+	_, err = handler.tx.Exec(handler.ctx, `
+	INSERT INTO watches.watches(number, year, phenomena, issued, is_pds, summary, sel_product) VALUES
+	($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+	ON CONFLICT (number, year) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, phenomena = COALESCE(EXCLUDED.phenomena, watches.phenomena),
+	issued = LEAST(watches.issued, EXCLUDED.issued), is_pds = EXCLUDED.is_pds, summary = EXCLUDED.summary,
+	sel_product = EXCLUDED.sel_product
+	`, sel.Number, year, sel.Phenomena, product.Issued, sel.IsPDS, sel.Summary, handler.dbProduct.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert watch from sel: %v", err.Error())
+	}
+
+	return nil
+}
+
+// The year the watch is numbered in, from when the product was issued in UTC. Numbering starts
+// again each year, so a product issued on 1 January about a watch still in effect from the
+// year before belongs to that year.
+func (handler *watchHandler) watchYear(number int) (int, error) {
+	issued := handler.product.Issued.UTC()
+	year := issued.Year()
+	if issued.YearDay() != 1 {
+		return year, nil
+	}
+
+	// Watches without an expiry yet have only had their SEL or WWP, which are sent as they are issued
+	previous := false
+	err := handler.tx.QueryRow(handler.ctx, `
+	SELECT EXISTS(SELECT 1 FROM watches.watches WHERE number = $1 AND year = $2
+	AND COALESCE(expires, issued + interval '1 day') > $3)
+	`, number, year-1, issued).Scan(&previous)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find watch from the previous year: %v", err.Error())
+	}
+	if previous {
+		return year - 1, nil
+	}
+
+	return year, nil
+}
+
+func (handler *watchHandler) wwp() error {
+	product := handler.product
+
+	wwp, err := products.ParseWWP(product.Text)
+	if err != nil {
+		return err
+	}
+
+	year, err := handler.watchYear(wwp.Number)
+	if err != nil {
+		return err
+	}
+
+	_, err = handler.tx.Exec(handler.ctx, `
+	INSERT INTO watches.watches(number, year, phenomena, issued, is_pds, prob_tornadoes, prob_strong_tornadoes,
+	prob_severe_wind, prob_significant_wind, prob_severe_hail, prob_significant_hail, prob_combined_hail_wind,
+	max_hail, max_wind_gust, max_tops, motion_direction, motion_speed, wwp_product) VALUES
+	($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	ON CONFLICT (number, year) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, issued = LEAST(watches.issued, EXCLUDED.issued),
+	phenomena = COALESCE(watches.phenomena, EXCLUDED.phenomena), is_pds = watches.is_pds OR EXCLUDED.is_pds,
+	prob_tornadoes = EXCLUDED.prob_tornadoes,
+	prob_strong_tornadoes = EXCLUDED.prob_strong_tornadoes, prob_severe_wind = EXCLUDED.prob_severe_wind,
+	prob_significant_wind = EXCLUDED.prob_significant_wind, prob_severe_hail = EXCLUDED.prob_severe_hail,
+	prob_significant_hail = EXCLUDED.prob_significant_hail, prob_combined_hail_wind = EXCLUDED.prob_combined_hail_wind,
+	max_hail = EXCLUDED.max_hail, max_wind_gust = EXCLUDED.max_wind_gust, max_tops = EXCLUDED.max_tops,
+	motion_direction = EXCLUDED.motion_direction, motion_speed = EXCLUDED.motion_speed, wwp_product = EXCLUDED.wwp_product
+	`, wwp.Number, year, wwp.Phenomena, product.Issued, wwp.IsPDS, wwp.ProbTornadoes, wwp.ProbStrongTornadoes,
+		wwp.ProbSevereWind, wwp.ProbSignificantWind, wwp.ProbSevereHail, wwp.ProbSignificantHail, wwp.ProbCombinedHailWind,
+		wwp.MaxHail, wwp.MaxWindGust, wwp.MaxTops, wwp.MotionDirection, wwp.MotionSpeed, handler.dbProduct.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert watch from wwp: %v", err.Error())
+	}
+
+	return nil
+}
+
+func (handler *watchHandler) saw() error {
+	product := handler.product
+
+	saw, err := products.ParseSAW(product.Text)
+	if err != nil {
+		return err
+	}
+
+	year, err := handler.watchYear(saw.Number)
+	if err != nil {
+		return err
+	}
+
+	var polygon []byte
+	if saw.Polygon != nil {
+		polygon, err = ewkb.Marshal(saw.Polygon, ewkb.NDR)
+		if err != nil {
+			return fmt.Errorf("failed to marshal saw polygon: %v", err.Error())
+		}
+	}
+
+	// The valid times of the SAW only carry the day and time so we use the product issuance for the rest
+	issued := awips.MergeDayTime(product.Issued, saw.Issued)
+	expires := awips.MergeDayTime(product.Issued, saw.Expires)
+
+	var action *string
+	if saw.Cancelled {
+		a := "CAN"
+		action = &a
+		expires = product.Issued.UTC()
+	}
+
+	_, err = handler.tx.Exec(handler.ctx, `
+	INSERT INTO watches.watches(number, year, phenomena, action, issued, expires, states, geom, saw_product) VALUES
+	($1, $2, NULLIF($3, ''), $4, $5, $6, $7, ST_GeomFromWKB($8, 4326), $9)
+	ON CONFLICT (number, year) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, action = COALESCE(EXCLUDED.action, watches.action),
+	phenomena = COALESCE(watches.phenomena, EXCLUDED.phenomena),
+	issued = LEAST(watches.issued, EXCLUDED.issued), expires = EXCLUDED.expires, states = EXCLUDED.states,
+	geom = COALESCE(EXCLUDED.geom, watches.geom), saw_product = EXCLUDED.saw_product
+	`, saw.Number, year, saw.Phenomena, action, issued, expires, saw.States, polygon, handler.dbProduct.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert watch from saw: %v", err.Error())
+	}
+
+	return nil
+}
+
+func (handler *watchHandler) wou() error {
+	product := handler.product
+	log := handler.log
+
+	wou, err := products.ParseWOU(product.Text)
+	if err != nil {
+		return err
+	}
+
+	year, err := handler.watchYear(wou.Number)
+	if err != nil {
+		return err
+	}
+
+	// Get the counties currently in the watch
+	ugcs := []string{}
+	err = handler.tx.QueryRow(handler.ctx, `
+	SELECT ugc FROM watches.watches WHERE number = $1 AND year = $2 FOR UPDATE
+	`, wou.Number, year).Scan(&ugcs)
+	if err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("failed to find watch: %v", err.Error())
+	}
+
+	var (
+		action  string
+		expires time.Time
+		active  bool
+	)
+	for _, segment := range wou.Segments {
+		vtec := segment.VTEC
+
+		end := product.Issued.UTC()
+		if vtec.End != nil {
+			end = *vtec.End
+		}
+
+		switch vtec.Action {
+		case "CAN", "EXP", "UPG":
+			ugcs = slices.DeleteFunc(ugcs, func(u string) bool {
+				return slices.Contains(segment.UGC, u)
+			})
+			if !active {
+				action = vtec.Action
+				expires = end
+			}
+		default:
+			for _, u := range segment.UGC {
+				if !slices.Contains(ugcs, u) {
+					ugcs = append(ugcs, u)
+				}
+			}
+			// The watch remains in effect as long as one segment is still active
+			if !active || end.After(expires) {
+				expires = end
+			}
+			if !active {
+				action = vtec.Action
+			}
+			active = true
+		}
+	}
+	slices.Sort(ugcs)
+
+	log.Debug().Int("watch", wou.Number).Str("action", action).Int("ugcs", len(ugcs)).Msg("updating watch counties")
+
+	issued := product.Issued.UTC()
+	if start := wou.Segments[0].VTEC.Start; start != nil {
+		issued = *start
+	}
+
+	_, err = handler.tx.Exec(handler.ctx, `
+	INSERT INTO watches.watches(number, year, phenomena, action, issued, expires, ugc, wou_product) VALUES
+	($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8)
+	ON CONFLICT (number, year) DO UPDATE SET updated_at = CURRENT_TIMESTAMP, action = EXCLUDED.action,
+	phenomena = COALESCE(watches.phenomena, EXCLUDED.phenomena),
+	issued = LEAST(watches.issued, EXCLUDED.issued), expires = EXCLUDED.expires, ugc = EXCLUDED.ugc,
+	wou_product = EXCLUDED.wou_product
+	`, wou.Number, year, wou.Phenomena, action, issued, expires, ugcs, handler.dbProduct.ProductID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert watch from wou: %v", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSEL(number int, wmoIssued string, issued string) string {
+	return fmt.Sprintf(`000
+WWUS20 KWNS %s
+SEL9
+SPC WW %s
+KSZ000-010600-
+
+URGENT - IMMEDIATE BROADCAST REQUESTED
+Tornado Watch Number %d
+NWS Storm Prediction Center Norman OK
+%s
+
+SUMMARY...Storms will pose a risk of tornadoes overnight.
+
+$$
+`, wmoIssued, wmoIssued, number, issued)
+}
+
+func testWOU(number int, wmoIssued string, issued string, start string) string {
+	return fmt.Sprintf(`000
+WOUS64 KWNS %s
+WOU9
+
+BULLETIN - IMMEDIATE BROADCAST REQUESTED
+TORNADO WATCH OUTLINE UPDATE FOR WT %d
+NWS STORM PREDICTION CENTER NORMAN OK
+%s
+
+KSC001-003-010600-
+/O.NEW.KWNS.TO.A.%04d.%s-260101T0600Z/
+
+$$
+`, wmoIssued, number, issued, number, start)
+}
+
+func TestWatchNewYear(t *testing.T) {
+	suite, err := initTestSuite(t, "watch/")
+	require.NoError(t, err, "failed to initialize test suite")
+	t.Cleanup(suite.teardown)
+
+	tests := []struct {
+		name   string
+		number int
+		sel    string
+		wou    string
+		year   int
+	}{
+		{
+			// Local time is still the 31st
+			name:   "after 00Z",
+			number: 998,
+			sel:    testSEL(998, "010005", "605 PM CST Wed Dec 31 2025"),
+			wou:    testWOU(998, "010010", "610 PM CST WED DEC 31 2025", "260101T0005Z"),
+			year:   2026,
+		},
+		{
+			name:   "either side of 00Z",
+			number: 999,
+			sel:    testSEL(999, "312350", "550 PM CST Wed Dec 31 2025"),
+			wou:    testWOU(999, "010010", "610 PM CST WED DEC 31 2025", "251231T2350Z"),
+			year:   2025,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cleanup := func() {
+				_, err := suite.db.Exec(context.Background(), `
+				DELETE FROM watches.watches WHERE number = $1 AND year IN (2025, 2026)
+				`, test.number)
+				assert.NoError(t, err, "failed to delete watches")
+			}
+			cleanup()
+			t.Cleanup(cleanup)
+
+			HandleText(test.sel, time.Now(), suite.db, nil)
+			HandleText(test.wou, time.Now(), suite.db, nil)
+
+			rows, err := suite.db.Query(context.Background(), `
+			SELECT year, sel_product IS NOT NULL, wou_product IS NOT NULL FROM watches.watches
+			WHERE number = $1 AND year IN (2025, 2026)
+			`, test.number)
+			require.NoError(t, err, "failed to query watches")
+			defer rows.Close()
+
+			require.True(t, rows.Next(), "no watch row returned")
+			var (
+				year     int
+				sel, wou bool
+			)
+			require.NoError(t, rows.Scan(&year, &sel, &wou))
+			assert.Equal(t, test.year, year)
+			assert.True(t, sel, "the watch has no SEL")
+			assert.True(t, wou, "the watch has no WOU")
+			assert.False(t, rows.Next(), "the watch was split across years")
+		})
+	}
+}
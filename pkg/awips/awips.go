@@ -17,8 +17,9 @@ var ErrCouldNotFindAWIPS = errors.New("could not find AWIPS header")
 
 // This regular expression is derived from NWS directive 10-1701 section 4.1.3.
 // The directive states that the AWIPS header is a 4 to 6 character string on its own line.
-// The string can contain letters and numbers.
-const AWIPSRegexp = `(?m:^[A-Z0-9]{3}[A-Z0-9 ]{3}[\n\r])`
+// The string can contain letters and numbers and may be padded with spaces to six characters.
+// The product category always starts with a letter, which stops the NWWS sequence number line (e.g. "000 ") from matching.
+const AWIPSRegexp = `(?m:^[A-Z][A-Z0-9]{2}[A-Z0-9 ]{1,3}[\n\r])`
 
 // Returns the AWIPS header from the given text.
 // If no header is found, the string is empty.
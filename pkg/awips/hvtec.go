@@ -0,0 +1,133 @@
+package awips
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+Hydrologic VTEC (H-VTEC) follows the P-VTEC line of flood products as described in NWS Directive 10-1703.
+
+	/nwsli.s.ic.yymmddThhnnZ.yymmddThhnnZ.yymmddThhnnZ.fr/
+*/
+
+var HVTECSeverity = map[string]string{
+	"N": "None",
+	"0": "Areal or Flash Flood",
+	"1": "Minor",
+	"2": "Moderate",
+	"3": "Major",
+	"U": "Unknown",
+}
+
+var HVTECCause = map[string]string{
+	"ER": "Excessive Rainfall",
+	"SM": "Snowmelt",
+	"RS": "Rain and Snowmelt",
+	"DM": "Dam or Levee Failure",
+	"IJ": "Ice Jam",
+	"GO": "Glacier-Dammed Lake Outburst",
+	"IC": "Rain and/or Snowmelt and/or Ice Jam",
+	"FS": "Upstream Flooding plus Storm Surge",
+	"FT": "Upstream Flooding plus Tidal Effects",
+	"ET": "Elevated Upstream Flow plus Tidal Effects",
+	"WT": "Wind and/or Tidal Effects",
+	"DR": "Upstream Dam or Reservoir Release",
+	"MC": "Other Multiple Causes",
+	"OT": "Other Effects",
+	"UU": "Unknown",
+}
+
+var HVTECRecord = map[string]string{
+	"NO": "Record flooding is not expected",
+	"NR": "Near record or record flood expected",
+	"UU": "Flood without a period of record to compare",
+	"OO": "Areal flood or flash flood products",
+}
+
+type HVTEC struct {
+	Original    string     `json:"original"`
+	NWSLI       string     `json:"nwsli"`
+	Severity    string     `json:"severity"`
+	Cause       string     `json:"cause"`
+	BeginString string     `json:"-"`
+	Begin       *time.Time `json:"begin"`
+	CrestString string     `json:"-"`
+	Crest       *time.Time `json:"crest"`
+	EndString   string     `json:"-"`
+	End         *time.Time `json:"end"`
+	Record      string     `json:"record"`
+}
+
+const HVTECRegexp = `/([A-Z0-9]{5})\.([N0-3U])\.([A-Z]{2})\.([0-9]{6}T[0-9]{4}Z)\.([0-9]{6}T[0-9]{4}Z)\.([0-9]{6}T[0-9]{4}Z)\.([A-Z]{2})/`
+
+// Parses the H-VTEC line of the text if there is one. Returns nil if the text does not contain H-VTEC.
+func ParseHVTEC(text string) (*HVTEC, error) {
+	hvtecRegex := regexp.MustCompile(HVTECRegexp)
+	match := hvtecRegex.FindStringSubmatch(text)
+	if match == nil {
+		return nil, nil
+	}
+
+	original := match[0]
+
+	cause := match[3]
+	if _, ok := HVTECCause[cause]; !ok {
+		return nil, fmt.Errorf("invalid immediate cause %s for %s", cause, original)
+	}
+
+	record := match[7]
+	if _, ok := HVTECRecord[record]; !ok {
+		return nil, fmt.Errorf("invalid flood record %s for %s", record, original)
+	}
+
+	times := make([]*time.Time, 3)
+	for i, s := range match[4:7] {
+		t, err := parseHVTECTime(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse time %s for %s: %w", s, original, err)
+		}
+		times[i] = t
+	}
+
+	return &HVTEC{
+		Original:    strings.Trim(original, "/"),
+		NWSLI:       match[1],
+		Severity:    match[2],
+		Cause:       cause,
+		BeginString: match[4],
+		Begin:       times[0],
+		CrestString: match[5],
+		Crest:       times[1],
+		EndString:   match[6],
+		End:         times[2],
+		Record:      record,
+	}, nil
+}
+
+// Missing times are sent as zeros
+func parseHVTECTime(s string) (*time.Time, error) {
+	if s == "000000T0000Z" {
+		return nil, nil
+	}
+	t, err := time.Parse("060102T1504Z", s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Whether the H-VTEC refers to a river forecast point rather than an areal flood
+func (hvtec *HVTEC) HasForecastPoint() bool {
+	return hvtec.NWSLI != "00000"
+}
+
+func (hvtec *HVTEC) SeverityString() string {
+	return HVTECSeverity[hvtec.Severity]
+}
+
+func (hvtec *HVTEC) CauseString() string {
+	return HVTECCause[hvtec.Cause]
+}
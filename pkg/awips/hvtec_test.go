@@ -0,0 +1,118 @@
+package awips
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHVTECParse(t *testing.T) {
+	hvtec, err := ParseHVTEC("/NPTA4.2.ER.250521T0600Z.250522T1800Z.250524T1800Z.NO/")
+	if err != nil {
+		t.Fatalf("failed to parse H-VTEC: %v", err)
+	}
+	if hvtec == nil {
+		t.Fatal("expected H-VTEC, got nil")
+	}
+
+	if hvtec.NWSLI != "NPTA4" {
+		t.Errorf("expected NWSLI 'NPTA4', got '%s'", hvtec.NWSLI)
+	}
+	if hvtec.Severity != "2" {
+		t.Errorf("expected severity '2', got '%s'", hvtec.Severity)
+	}
+	if hvtec.SeverityString() != "Moderate" {
+		t.Errorf("expected severity 'Moderate', got '%s'", hvtec.SeverityString())
+	}
+	if hvtec.Cause != "ER" {
+		t.Errorf("expected cause 'ER', got '%s'", hvtec.Cause)
+	}
+	if hvtec.Record != "NO" {
+		t.Errorf("expected record 'NO', got '%s'", hvtec.Record)
+	}
+	if !hvtec.HasForecastPoint() {
+		t.Error("expected H-VTEC to have a forecast point")
+	}
+
+	crest := time.Date(2025, 5, 22, 18, 0, 0, 0, time.UTC)
+	if hvtec.Crest == nil || !hvtec.Crest.Equal(crest) {
+		t.Errorf("expected crest %v, got %v", crest, hvtec.Crest)
+	}
+	begin := time.Date(2025, 5, 21, 6, 0, 0, 0, time.UTC)
+	if hvtec.Begin == nil || !hvtec.Begin.Equal(begin) {
+		t.Errorf("expected begin %v, got %v", begin, hvtec.Begin)
+	}
+}
+
+func TestHVTECParseMissingTimes(t *testing.T) {
+	hvtec, err := ParseHVTEC("/00000.0.ER.000000T0000Z.000000T0000Z.000000T0000Z.OO/")
+	if err != nil {
+		t.Fatalf("failed to parse H-VTEC: %v", err)
+	}
+
+	if hvtec.HasForecastPoint() {
+		t.Error("expected H-VTEC to not have a forecast point")
+	}
+	if hvtec.Begin != nil || hvtec.Crest != nil || hvtec.End != nil {
+		t.Error("expected missing times to be nil")
+	}
+}
+
+func TestHVTECParseInvalid(t *testing.T) {
+	if _, err := ParseHVTEC("/NPTA4.2.XX.250521T0600Z.250522T1800Z.250524T1800Z.NO/"); err == nil {
+		t.Error("expected error for invalid immediate cause")
+	}
+
+	hvtec, err := ParseHVTEC("/O.NEW.KLZK.FL.W.0012.250521T0600Z-250525T0000Z/")
+	if err != nil || hvtec != nil {
+		t.Errorf("expected no H-VTEC for P-VTEC line, got %v, %v", hvtec, err)
+	}
+}
+
+func TestHVTECSegment(t *testing.T) {
+	data, err := os.ReadFile("../../data/test/awips/flw/FLW-W-KLZK-12-2025.txt")
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	product, err := New(string(data))
+	if err != nil {
+		t.Fatalf("failed to parse product: %v", err)
+	}
+	if len(product.Segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(product.Segments))
+	}
+
+	segment := product.Segments[0]
+	if segment.HVTEC == nil {
+		t.Fatal("expected segment to have H-VTEC")
+	}
+	if segment.HVTEC.NWSLI != "NPTA4" {
+		t.Errorf("expected NWSLI 'NPTA4', got '%s'", segment.HVTEC.NWSLI)
+	}
+}
+
+func TestHVTECSegmentInvalid(t *testing.T) {
+	data, err := os.ReadFile("../../data/test/awips/flw/FLW-W-KLZK-12-2025.txt")
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	text := strings.Replace(string(data), "/NPTA4.2.ER.", "/NPTA4.2.XX.", 1)
+
+	product, err := New(text)
+	if err != nil {
+		t.Fatalf("expected an invalid H-VTEC not to fail the product: %v", err)
+	}
+	if len(product.Segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(product.Segments))
+	}
+
+	segment := product.Segments[0]
+	if segment.HVTEC != nil {
+		t.Errorf("expected no H-VTEC, got %v", segment.HVTEC)
+	}
+	if len(segment.VTEC) != 1 || segment.VTEC[0].Phenomena != "FL" || segment.VTEC[0].Significance != "W" {
+		t.Errorf("expected the P-VTEC to be kept, got %v", segment.VTEC)
+	}
+}
@@ -32,6 +32,7 @@ type Product struct {
 type ProductSegment struct {
 	Text    string            `json:"text"`
 	VTEC    []VTEC            `json:"vtec"`
+	HVTEC   *HVTEC            `json:"hvtec"`
 	UGC     *UGC              `json:"ugc"`
 	Expires time.Time         `json:"expires"` // The product expiry time as defined in NWS Directive 10-1701
 	Ends    time.Time         `json:"ends"`    // The event end time as defined in NWS Directive 10-1701
@@ -165,6 +166,13 @@ func GetSegments(text string, issued time.Time, wmo WMO) ([]ProductSegment, []er
 			errors = append(errors, e...)
 		}
 
+		// Flood products carry H-VTEC after the P-VTEC. The P-VTEC is still usable without it.
+		hvtec, err := ParseHVTEC(segment)
+		if err != nil {
+			errors = append(errors, err)
+			hvtec = nil
+		}
+
 		latlon, err := ParseLatLon(text)
 		if err != nil {
 			errors = append(errors, err)
@@ -185,6 +193,7 @@ func GetSegments(text string, issued time.Time, wmo WMO) ([]ProductSegment, []er
 		segments = append(segments, ProductSegment{
 			Text:    segment,
 			VTEC:    vtec,
+			HVTEC:   hvtec,
 			UGC:     ugc,
 			Expires: expires,
 			LatLon:  latlon,
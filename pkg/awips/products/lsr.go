@@ -0,0 +1,192 @@
+package products
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/twpayne/go-geom"
+)
+
+/*
+Local Storm Reports are laid out in fixed columns as described in NWS Directive 10-517.
+
+	..TIME...   ...EVENT...      ...CITY LOCATION...     ...LAT.LON...
+	..DATE...   ....MAG....      ..COUNTY LOCATION..ST.. ...SOURCE....
+	            ..REMARKS..
+
+Each report is made up of a time line, a date line and optional remarks indented to the remarks column.
+*/
+
+// The magnitude qualifiers of a LSR
+const (
+	LSRMeasured  = "M"
+	LSREstimated = "E"
+	LSRUnknown   = "U"
+)
+
+var ErrNoLSRReports = errors.New("no local storm reports found")
+
+var (
+	lsrTimeRegexp      = regexp.MustCompile(`^[0-9]{4} (AM|PM) {3,}\S`) // Padded to the event column unlike the issued line
+	lsrMagnitudeRegexp = regexp.MustCompile(`^([EMU])?([0-9]*\.?[0-9]+)\s*(.*)$`)
+	lsrLatLonRegexp    = regexp.MustCompile(`([0-9.]+)([NS])\s+([0-9.]+)([EW])`)
+)
+
+// Local Storm Report product (LSR)
+type LSR struct {
+	Original  string      `json:"original"`
+	Summary   bool        `json:"summary"`   // Whether the product is a summary of many previous reports
+	Corrected bool        `json:"corrected"` // Whether the product corrects a previous report
+	Reports   []LSRReport `json:"reports"`
+}
+
+// A single report in a LSR
+type LSRReport struct {
+	Original  string      `json:"original"`
+	Time      time.Time   `json:"time"`
+	Event     string      `json:"event"`
+	Location  string      `json:"location"`
+	Point     *geom.Point `json:"point"`
+	Magnitude *float64    `json:"magnitude"`
+	Units     string      `json:"units"`
+	Qualifier string      `json:"qualifier"` // Measured, estimated or unknown
+	County    string      `json:"county"`
+	State     string      `json:"state"`
+	Source    string      `json:"source"`
+	Remarks   string      `json:"remarks"`
+}
+
+// Parses a Local Storm Report (LSR) product from the given text. Reports use the timezone of the product issuance.
+func ParseLSR(text string) (*LSR, error) {
+	text = strings.ReplaceAll(text, "\r", "")
+
+	issued, err := awips.GetIssuedTime(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing lsr issued time: %s", err.Error())
+	}
+	location := issued.Location()
+
+	lsr := &LSR{
+		Original: text,
+	}
+
+	lines := strings.Split(text, "\n")
+
+	// The heading tells us if the product is a summary or correction
+	for _, line := range lines {
+		if strings.Contains(line, "LOCAL STORM REPORT") {
+			lsr.Summary = strings.Contains(line, "SUMMARY")
+			lsr.Corrected = strings.Contains(line, "CORRECTED")
+			break
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		if !lsrTimeRegexp.MatchString(lines[i]) {
+			continue
+		}
+		if i+1 >= len(lines) {
+			return nil, errors.New("error parsing lsr: report is missing its date line")
+		}
+
+		timeLine := lines[i]
+		dateLine := lines[i+1]
+		original := []string{timeLine, dateLine}
+
+		// Collect the indented remarks until the next report or the end of the product
+		remarks := []string{}
+		j := i + 2
+		for ; j < len(lines); j++ {
+			line := lines[j]
+			if lsrTimeRegexp.MatchString(line) || strings.HasPrefix(line, "&&") || strings.HasPrefix(line, "$$") {
+				break
+			}
+			original = append(original, line)
+			if strings.TrimSpace(line) != "" {
+				remarks = append(remarks, strings.TrimSpace(line))
+			}
+		}
+
+		report, err := parseLSRReport(timeLine, dateLine, location)
+		if err != nil {
+			return nil, err
+		}
+		report.Original = strings.TrimRight(strings.Join(original, "\n"), "\n")
+		report.Remarks = strings.Join(remarks, " ")
+
+		lsr.Reports = append(lsr.Reports, *report)
+		i = j - 1
+	}
+
+	if len(lsr.Reports) == 0 {
+		return nil, ErrNoLSRReports
+	}
+
+	return lsr, nil
+}
+
+// Parse the time and date lines of a report
+func parseLSRReport(timeLine string, dateLine string, location *time.Location) (*LSRReport, error) {
+	report := &LSRReport{
+		Event:    lsrColumn(timeLine, 12, 29),
+		Location: lsrColumn(timeLine, 29, 53),
+		County:   lsrColumn(dateLine, 29, 48),
+		State:    lsrColumn(dateLine, 48, 50),
+		Source:   lsrColumn(dateLine, 53, len(dateLine)),
+	}
+
+	t, err := time.ParseInLocation("0304 PM 01/02/2006", lsrColumn(timeLine, 0, 12)+" "+lsrColumn(dateLine, 0, 12), location)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing lsr time: %s", err.Error())
+	}
+	report.Time = t
+
+	// Missing locations are sometimes sent as 0.00N 0.00W
+	match := lsrLatLonRegexp.FindStringSubmatch(lsrColumn(timeLine, 53, len(timeLine)))
+	if len(match) == 5 {
+		lat, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing lsr latitude: %s", err.Error())
+		}
+		lon, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing lsr longitude: %s", err.Error())
+		}
+		if match[2] == "S" {
+			lat = -lat
+		}
+		if match[4] == "W" {
+			lon = -lon
+		}
+		if lat != 0 || lon != 0 {
+			report.Point = geom.NewPointFlat(geom.XY, []float64{lon, lat})
+		}
+	}
+
+	magnitude := lsrColumn(dateLine, 12, 29)
+	if match := lsrMagnitudeRegexp.FindStringSubmatch(magnitude); len(match) == 4 {
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing lsr magnitude: %s", err.Error())
+		}
+		report.Magnitude = &value
+		report.Qualifier = match[1]
+		report.Units = match[3]
+	}
+
+	return report, nil
+}
+
+// Safely slice a fixed column from the line, trimming the padding
+func lsrColumn(line string, start int, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	end = min(end, len(line))
+	return strings.TrimSpace(line[start:end])
+}
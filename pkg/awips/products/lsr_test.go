@@ -0,0 +1,101 @@
+package products
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSR(t *testing.T) {
+	text := readTestFile(t, "lsr/LSROAX-2025-05-19.txt")
+
+	lsr, err := ParseLSR(text)
+	require.NoError(t, err)
+	require.Len(t, lsr.Reports, 1)
+
+	assert.False(t, lsr.Summary)
+	assert.False(t, lsr.Corrected)
+
+	report := lsr.Reports[0]
+	assert.Equal(t, "TORNADO", report.Event)
+	assert.Equal(t, "2 SSW ELKHORN", report.Location)
+	assert.Equal(t, "DOUGLAS", report.County)
+	assert.Equal(t, "NE", report.State)
+	assert.Equal(t, "STORM CHASER", report.Source)
+	assert.Equal(t, "BRIEF TORNADO TOUCHDOWN IN AN OPEN FIELD. NO DAMAGE REPORTED.", report.Remarks)
+	assert.Equal(t, time.Date(2025, 5, 19, 22, 55, 0, 0, time.UTC), report.Time.UTC())
+	assert.Nil(t, report.Magnitude)
+	require.NotNil(t, report.Point)
+	assert.Equal(t, []float64{-96.25, 41.25}, report.Point.FlatCoords())
+}
+
+func TestParseLSRCorrection(t *testing.T) {
+	text := readTestFile(t, "lsr/LSROAX-2025-05-19-COR.txt")
+
+	lsr, err := ParseLSR(text)
+	require.NoError(t, err)
+	require.Len(t, lsr.Reports, 1)
+
+	assert.True(t, lsr.Corrected)
+	assert.Equal(t, "3 SSW ELKHORN", lsr.Reports[0].Location)
+	assert.Equal(t, "CORRECTS LOCATION. BRIEF TORNADO TOUCHDOWN IN AN OPEN FIELD. NO DAMAGE REPORTED.", lsr.Reports[0].Remarks)
+}
+
+func TestParseLSRSummary(t *testing.T) {
+	text := readTestFile(t, "lsr/LSROAX-2025-05-20-SUMMARY.txt")
+
+	lsr, err := ParseLSR(text)
+	require.NoError(t, err)
+	require.Len(t, lsr.Reports, 5)
+
+	assert.True(t, lsr.Summary)
+	assert.False(t, lsr.Corrected)
+
+	hail := lsr.Reports[1]
+	assert.Equal(t, "HAIL", hail.Event)
+	assert.Equal(t, "OMAHA", hail.Location)
+	require.NotNil(t, hail.Magnitude)
+	assert.Equal(t, 1.75, *hail.Magnitude)
+	assert.Equal(t, "INCH", hail.Units)
+	assert.Equal(t, LSREstimated, hail.Qualifier)
+	assert.Equal(t, "TRAINED SPOTTER", hail.Source)
+	assert.Empty(t, hail.Remarks)
+
+	wind := lsr.Reports[2]
+	assert.Equal(t, "TSTM WND GST", wind.Event)
+	require.NotNil(t, wind.Magnitude)
+	assert.Equal(t, 65.0, *wind.Magnitude)
+	assert.Equal(t, "MPH", wind.Units)
+	assert.Equal(t, LSRMeasured, wind.Qualifier)
+	assert.Equal(t, "ASOS STATION KOMA.", wind.Remarks)
+
+	damage := lsr.Reports[3]
+	assert.Equal(t, "POTTAWATTAMIE", damage.County)
+	assert.Equal(t, "IA", damage.State)
+	assert.Nil(t, damage.Magnitude)
+	assert.Equal(t, "LARGE TREE BRANCHES DOWN ON POWER LINES. TIME ESTIMATED FROM RADAR.", damage.Remarks)
+
+	// Reports before midnight local time are on the next UTC day
+	rain := lsr.Reports[4]
+	assert.Equal(t, time.Date(2025, 5, 20, 4, 50, 0, 0, time.UTC), rain.Time.UTC())
+	assert.Equal(t, "COCORAHS", rain.Source)
+}
+
+func TestParseLSRNoReports(t *testing.T) {
+	text := readTestFile(t, "mcd/SWOMCD-0512-2025.txt")
+
+	_, err := ParseLSR(text)
+	assert.ErrorIs(t, err, ErrNoLSRReports)
+}
+
+func TestParseLSRProduct(t *testing.T) {
+	text := readTestFile(t, "lsr/LSROAX-2025-05-19.txt")
+
+	product, err := awips.New(text)
+	require.NoError(t, err)
+	assert.Equal(t, "LSR", product.AWIPS.Product)
+	assert.Equal(t, "KOAX", product.Office)
+}
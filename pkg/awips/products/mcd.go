@@ -68,7 +68,7 @@ func ParseMCD(text string) (*MCD, error) {
 	if concerningString == "" {
 		return nil, fmt.Errorf("error parsing mcd: No concerning text found")
 	}
-	concerning := strings.ReplaceAll(concerningString, "Concerning...", "")
+	concerning := strings.TrimSpace(strings.ReplaceAll(concerningString, "Concerning...", ""))
 
 	//  Parse the LatLon segment
 	latlon, err := awips.ParseLatLon(text)
@@ -98,7 +98,7 @@ func ParseMCD(text string) (*MCD, error) {
 		}
 	}
 	// Find the probable tornado intensity
-	probTornadoRegexp := regexp.MustCompile(`(MOST PROBABLE PEAK TORNADO INTENSITY\.\.\.)([\w ]+)`)
+	probTornadoRegexp := regexp.MustCompile(`(MOST PROBABLE PEAK TORNADO INTENSITY\.\.\.)(.+)`)
 	probTornadoString := probTornadoRegexp.FindString(text)
 	var probTornado string
 	if probTornadoString != "" {
@@ -106,11 +106,11 @@ func ParseMCD(text string) (*MCD, error) {
 		if len(values) < 2 {
 			return nil, fmt.Errorf("tornado probability string was found but split returned %d elements", len(values))
 		}
-		probTornado = values[1]
+		probTornado = strings.TrimSpace(values[1])
 	}
 
 	// Find the probable gust intensity
-	probGustRegexp := regexp.MustCompile(`(MOST PROBABLE PEAK TORNADO INTENSITY\.\.\.)([\w ]+)`)
+	probGustRegexp := regexp.MustCompile(`(MOST PROBABLE PEAK WIND GUST\.\.\.)(.+)`)
 	probGustString := probGustRegexp.FindString(text)
 	var probGust string
 	if probGustString != "" {
@@ -118,11 +118,11 @@ func ParseMCD(text string) (*MCD, error) {
 		if len(values) < 2 {
 			return nil, fmt.Errorf("gust probability string was found but split returned %d elements", len(values))
 		}
-		probGust = values[1]
+		probGust = strings.TrimSpace(values[1])
 	}
 
 	// Find the probable hail intensity
-	probHailRegexp := regexp.MustCompile(`(MOST PROBABLE PEAK TORNADO INTENSITY\.\.\.)([\w ]+)`)
+	probHailRegexp := regexp.MustCompile(`(MOST PROBABLE PEAK HAIL SIZE\.\.\.)(.+)`)
 	probHailString := probHailRegexp.FindString(text)
 	var probHail string
 	if probHailString != "" {
@@ -130,7 +130,7 @@ func ParseMCD(text string) (*MCD, error) {
 		if len(values) < 2 {
 			return nil, fmt.Errorf("hail probability string was found but split returned %d elements", len(values))
 		}
-		probHail = values[1]
+		probHail = strings.TrimSpace(values[1])
 	}
 
 	mcd := MCD{
@@ -0,0 +1,45 @@
+package products
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const awipsTestDataPath = "../../../data/test/awips/"
+
+func readTestFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(awipsTestDataPath + path)
+	require.NoErrorf(t, err, "failed to read file %s", path)
+	return string(data)
+}
+
+func TestParseMCD(t *testing.T) {
+	text := readTestFile(t, "mcd/SWOMCD-0512-2025.txt")
+
+	mcd, err := ParseMCD(text)
+	require.NoError(t, err)
+	require.NotNil(t, mcd)
+
+	assert.Equal(t, 512, mcd.Number)
+	assert.Equal(t, "Tornado Watch 151...", mcd.Concerning)
+	// Valid times only carry the day and time
+	assert.Equal(t, 19, mcd.Issued.Day())
+	assert.Equal(t, 23, mcd.Issued.Hour())
+	assert.Equal(t, 54, mcd.Issued.Minute())
+	assert.Equal(t, 20, mcd.Expires.Day())
+	assert.Equal(t, 96*time.Minute, mcd.Expires.Sub(mcd.Issued))
+	assert.Equal(t, 0, mcd.WatchProbability)
+	assert.Equal(t, "95-120 MPH", mcd.MostProbTornado)
+	assert.Equal(t, "55-70 MPH", mcd.MostProbGust)
+	assert.Equal(t, "1.50-2.50 IN", mcd.MostProbHail)
+
+	coords := mcd.Polygon.Coords()
+	require.Len(t, coords, 1)
+	assert.Len(t, coords[0], 8)
+	assert.Equal(t, -98.35, coords[0][0].X())
+	assert.Equal(t, 33.69, coords[0][0].Y())
+}
@@ -0,0 +1,425 @@
+package products
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/twpayne/go-geom"
+)
+
+/*
+SPC convective outlooks are sent as point products (PTS) alongside the text discussion.
+
+Each outlook day is made up of hazards (TORNADO, HAIL, WIND, ANY SEVERE, CATEGORICAL) each with a set of thresholds
+(0.05, SIGN, SLGT, ...). The points of a threshold are sent as lines broken up by "99999". The area of the threshold
+is to the right of the line as it is drawn, with lines that are not closed running off the edge of the CONUS.
+*/
+
+// The hazards of convective outlooks
+const (
+	OutlookCategorical = "CATEGORICAL"
+	OutlookTornado     = "TORNADO"
+	OutlookHail        = "HAIL"
+	OutlookWind        = "WIND"
+	OutlookAnySevere   = "ANY SEVERE"
+)
+
+// Significant severe is sent as a threshold of the probabilistic hazards
+const OutlookSignificant = "SIGN"
+
+// The categorical thresholds in order of increasing risk
+var OutlookCategories = []string{"TSTM", "MRGL", "SLGT", "ENH", "MDT", "HIGH"}
+
+var ErrNoOutlookDays = errors.New("no outlook days found")
+
+var (
+	outlookValidRegexp     = regexp.MustCompile(`VALID TIME ([0-9]{6}Z) - ([0-9]{6}Z)`)
+	outlookDayRegexp       = regexp.MustCompile(`OUTLOOK POINTS DAY ([1-8])`)
+	outlookHazardRegexp    = regexp.MustCompile(`^\.\.\. (.+) \.\.\.$`)
+	outlookThresholdRegexp = regexp.MustCompile(`^(TSTM|MRGL|SLGT|ENH|MDT|HIGH|SIGN|0\.[0-9]+)\s+(.*)$`)
+)
+
+// Convective outlook point product (PTS)
+type Outlook struct {
+	Original string       `json:"original"`
+	Days     []OutlookDay `json:"days"`
+}
+
+// The outlook for a single day
+type OutlookDay struct {
+	Day     int           `json:"day"`
+	Starts  time.Time     `json:"starts"` // Only day, hour, minute
+	Expires time.Time     `json:"expires"`
+	Areas   []OutlookArea `json:"areas"`
+}
+
+// The area of a single threshold of a hazard
+type OutlookArea struct {
+	Hazard    string             `json:"hazard"`
+	Threshold string             `json:"threshold"`
+	Polygon   *geom.MultiPolygon `json:"polygon"`
+}
+
+// Parses a convective outlook point product (PTS) from the given text.
+func ParseOutlook(text string) (*Outlook, error) {
+	text = strings.ReplaceAll(text, "\r", "")
+
+	// Find the valid times of the outlook
+	valid := outlookValidRegexp.FindStringSubmatch(text)
+	if len(valid) != 3 {
+		return nil, errors.New("error parsing outlook: No valid time found")
+	}
+	starts, err := time.Parse("021504Z", valid[1])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing outlook start time: %s", err.Error())
+	}
+	expires, err := time.Parse("021504Z", valid[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing outlook expire time: %s", err.Error())
+	}
+
+	outlook := &Outlook{
+		Original: text,
+	}
+
+	var (
+		day       *OutlookDay
+		hazard    string
+		threshold string
+		points    []string
+	)
+
+	// Add the collected points to the current day
+	flush := func() error {
+		if day == nil || threshold == "" {
+			return nil
+		}
+		polygon, err := outlookPolygon(points)
+		if err != nil {
+			return fmt.Errorf("error parsing outlook day %d %s %s: %s", day.Day, hazard, threshold, err.Error())
+		}
+		if polygon != nil {
+			day.Areas = append(day.Areas, OutlookArea{
+				Hazard:    hazard,
+				Threshold: threshold,
+				Polygon:   polygon,
+			})
+		}
+		threshold = ""
+		points = nil
+		return nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if match := outlookDayRegexp.FindStringSubmatch(trimmed); len(match) == 2 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			n, _ := strconv.Atoi(match[1])
+			// The probabilistic and categorical sections of the same day share an entry
+			if day == nil || day.Day != n {
+				outlook.Days = append(outlook.Days, OutlookDay{Day: n})
+				day = &outlook.Days[len(outlook.Days)-1]
+			}
+			continue
+		}
+
+		if day == nil {
+			continue
+		}
+
+		if match := outlookHazardRegexp.FindStringSubmatch(trimmed); len(match) == 2 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			hazard = match[1]
+			continue
+		}
+
+		if trimmed == "&&" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			hazard = ""
+			continue
+		}
+
+		if hazard == "" {
+			continue
+		}
+
+		if match := outlookThresholdRegexp.FindStringSubmatch(trimmed); len(match) == 3 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			threshold = match[1]
+			points = append(points, strings.Fields(match[2])...)
+			continue
+		}
+
+		// Continuation of the previous threshold
+		if threshold != "" && strings.HasPrefix(line, " ") {
+			points = append(points, strings.Fields(trimmed)...)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if len(outlook.Days) == 0 {
+		return nil, ErrNoOutlookDays
+	}
+
+	// Day 4-8 outlooks share a single valid time that is split into 24 hour periods
+	first := outlook.Days[0].Day
+	for i := range outlook.Days {
+		d := &outlook.Days[i]
+		if len(outlook.Days) > 1 {
+			d.Starts = starts.Add(time.Duration(d.Day-first) * 24 * time.Hour)
+			d.Expires = d.Starts.Add(24 * time.Hour)
+		} else {
+			d.Starts = starts
+			d.Expires = expires
+		}
+	}
+
+	return outlook, nil
+}
+
+// Find the area of the given hazard and threshold for the day, if any.
+func (day *OutlookDay) Area(hazard string, threshold string) *OutlookArea {
+	for i := range day.Areas {
+		if day.Areas[i].Hazard == hazard && day.Areas[i].Threshold == threshold {
+			return &day.Areas[i]
+		}
+	}
+	return nil
+}
+
+// Convert the points of a threshold to a multipolygon. Returns nil if there are no points.
+func outlookPolygon(points []string) (*geom.MultiPolygon, error) {
+	lines := [][]geom.Coord{}
+	line := []geom.Coord{}
+	for _, p := range points {
+		if p == "99999" {
+			if len(line) > 0 {
+				lines = append(lines, line)
+			}
+			line = []geom.Coord{}
+			continue
+		}
+		coord, err := awips.ParseCoord8(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point %s: %s", p, err.Error())
+		}
+		line = append(line, coord)
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	polygons := [][][]geom.Coord{}
+	holes := [][]geom.Coord{}
+	for _, l := range lines {
+		if len(l) < 2 {
+			continue
+		}
+
+		var ring []geom.Coord
+		if l[0].Equal(geom.XY, l[len(l)-1]) {
+			// A closed line drawn counter-clockwise has the area outside of it, so it cuts a hole in another area
+			if ringArea(l) > 0 {
+				holes = append(holes, l)
+				continue
+			}
+			ring = l
+		} else {
+			ring = closeOnCONUS(l)
+		}
+
+		ring = clipToCONUS(ring)
+		if len(ring) < 4 {
+			continue
+		}
+		polygons = append(polygons, [][]geom.Coord{ring})
+	}
+
+	for _, hole := range holes {
+		attached := false
+		for i := range polygons {
+			if pointInRing(hole[0], polygons[i][0]) {
+				polygons[i] = append(polygons[i], hole)
+				attached = true
+				break
+			}
+		}
+		// Without an area to cut from, the line is treated as the outline of an area instead
+		if !attached {
+			reversed := make([]geom.Coord, len(hole))
+			for i := range hole {
+				reversed[i] = hole[len(hole)-1-i]
+			}
+			polygons = append(polygons, [][]geom.Coord{reversed})
+		}
+	}
+
+	if len(polygons) == 0 {
+		return nil, nil
+	}
+
+	return geom.NewMultiPolygon(geom.XY).SetCoords(polygons)
+}
+
+/*
+A simplified outline of the CONUS drawn clockwise, used to close outlook lines that run off the edge of the country.
+*/
+var conusOutline = []geom.Coord{
+	{-124.7, 48.4}, {-123.0, 49.0}, {-95.2, 49.0}, {-89.6, 48.0}, {-84.8, 46.5}, {-82.4, 43.0}, {-79.0, 43.3},
+	{-76.8, 43.6}, {-75.0, 45.0}, {-71.5, 45.0}, {-70.0, 46.7}, {-69.2, 47.4}, {-67.8, 47.1}, {-67.0, 44.8},
+	{-70.2, 43.6}, {-70.6, 42.0}, {-70.0, 41.7}, {-71.9, 41.3}, {-74.0, 40.6}, {-74.9, 38.9}, {-76.0, 36.9},
+	{-75.5, 35.2}, {-76.5, 34.6}, {-78.0, 33.9}, {-79.2, 33.2}, {-80.9, 32.0}, {-81.4, 30.7}, {-80.6, 28.4},
+	{-80.0, 26.7}, {-80.4, 25.2}, {-81.1, 25.1}, {-81.8, 26.1}, {-82.7, 27.5}, {-82.8, 28.9}, {-83.7, 29.9},
+	{-85.3, 29.7}, {-86.5, 30.4}, {-88.0, 30.4}, {-89.4, 30.2}, {-89.4, 29.0}, {-90.5, 29.1}, {-92.3, 29.6},
+	{-94.0, 29.7}, {-95.0, 29.2}, {-97.2, 27.7}, {-97.4, 26.0}, {-99.1, 26.4}, {-100.0, 28.1}, {-101.4, 29.8},
+	{-103.1, 29.0}, {-104.5, 29.6}, {-106.5, 31.8}, {-108.2, 31.8}, {-111.1, 31.3}, {-114.8, 32.5}, {-117.1, 32.5},
+	{-118.5, 34.0}, {-120.6, 34.6}, {-121.9, 36.6}, {-122.5, 37.8}, {-123.7, 39.0}, {-124.4, 40.4}, {-124.2, 42.0},
+	{-124.1, 43.7}, {-123.9, 46.2},
+}
+
+// The bounds outlook areas are clipped to
+const (
+	conusMinX = -125.0
+	conusMaxX = -66.5
+	conusMinY = 24.0
+	conusMaxY = 50.0
+)
+
+// Close an open line by following the CONUS outline clockwise from the end of the line back to its start,
+// keeping the area to the right of the line.
+func closeOnCONUS(line []geom.Coord) []geom.Coord {
+	endSegment, endT := nearestOutlineSegment(line[len(line)-1])
+	startSegment, startT := nearestOutlineSegment(line[0])
+
+	ring := append([]geom.Coord{}, line...)
+
+	n := len(conusOutline)
+	if endSegment != startSegment || endT > startT {
+		for i := (endSegment + 1) % n; ; i = (i + 1) % n {
+			ring = append(ring, conusOutline[i])
+			if i == startSegment {
+				break
+			}
+		}
+	}
+
+	return append(ring, line[0])
+}
+
+// Find the segment of the CONUS outline nearest to the coordinate and how far along the segment it is
+func nearestOutlineSegment(c geom.Coord) (int, float64) {
+	best := -1
+	bestT := 0.0
+	bestDistance := math.Inf(1)
+
+	n := len(conusOutline)
+	for i := range conusOutline {
+		a := conusOutline[i]
+		b := conusOutline[(i+1)%n]
+
+		dx, dy := b.X()-a.X(), b.Y()-a.Y()
+		t := ((c.X()-a.X())*dx + (c.Y()-a.Y())*dy) / (dx*dx + dy*dy)
+		t = math.Max(0, math.Min(1, t))
+
+		px, py := a.X()+t*dx, a.Y()+t*dy
+		distance := math.Hypot(c.X()-px, c.Y()-py)
+		if distance < bestDistance {
+			best, bestT, bestDistance = i, t, distance
+		}
+	}
+
+	return best, bestT
+}
+
+// Clip the ring to the CONUS bounds using Sutherland-Hodgman
+func clipToCONUS(ring []geom.Coord) []geom.Coord {
+	edges := []struct {
+		inside    func(c geom.Coord) bool
+		intersect func(a, b geom.Coord) geom.Coord
+	}{
+		{func(c geom.Coord) bool { return c.X() >= conusMinX }, func(a, b geom.Coord) geom.Coord { return intersectX(a, b, conusMinX) }},
+		{func(c geom.Coord) bool { return c.X() <= conusMaxX }, func(a, b geom.Coord) geom.Coord { return intersectX(a, b, conusMaxX) }},
+		{func(c geom.Coord) bool { return c.Y() >= conusMinY }, func(a, b geom.Coord) geom.Coord { return intersectY(a, b, conusMinY) }},
+		{func(c geom.Coord) bool { return c.Y() <= conusMaxY }, func(a, b geom.Coord) geom.Coord { return intersectY(a, b, conusMaxY) }},
+	}
+
+	// Work on the open ring
+	output := ring[:len(ring)-1]
+	for _, edge := range edges {
+		input := output
+		output = []geom.Coord{}
+		if len(input) == 0 {
+			break
+		}
+		previous := input[len(input)-1]
+		for _, current := range input {
+			if edge.inside(current) {
+				if !edge.inside(previous) {
+					output = append(output, edge.intersect(previous, current))
+				}
+				output = append(output, current)
+			} else if edge.inside(previous) {
+				output = append(output, edge.intersect(previous, current))
+			}
+			previous = current
+		}
+	}
+
+	if len(output) == 0 {
+		return output
+	}
+	return append(output, output[0])
+}
+
+func intersectX(a, b geom.Coord, x float64) geom.Coord {
+	t := (x - a.X()) / (b.X() - a.X())
+	return geom.Coord{x, a.Y() + t*(b.Y()-a.Y())}
+}
+
+func intersectY(a, b geom.Coord, y float64) geom.Coord {
+	t := (y - a.Y()) / (b.Y() - a.Y())
+	return geom.Coord{a.X() + t*(b.X()-a.X()), y}
+}
+
+// The signed area of the ring. Positive when drawn counter-clockwise.
+func ringArea(ring []geom.Coord) float64 {
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += ring[i].X()*ring[i+1].Y() - ring[i+1].X()*ring[i].Y()
+	}
+	return area / 2
+}
+
+// Whether the coordinate is inside the ring using ray casting
+func pointInRing(c geom.Coord, ring []geom.Coord) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y() > c.Y()) != (b.Y() > c.Y()) &&
+			c.X() < (b.X()-a.X())*(c.Y()-a.Y())/(b.Y()-a.Y())+a.X() {
+			inside = !inside
+		}
+	}
+	return inside
+}
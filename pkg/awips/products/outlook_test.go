@@ -0,0 +1,102 @@
+package products
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twpayne/go-geom"
+)
+
+func TestParseOutlookDay1(t *testing.T) {
+	text := readTestFile(t, "outlook/PTSDY1-2025-05-19.txt")
+
+	outlook, err := ParseOutlook(text)
+	require.NoError(t, err)
+	require.Len(t, outlook.Days, 1)
+
+	day := outlook.Days[0]
+	assert.Equal(t, 1, day.Day)
+	assert.Equal(t, 19, day.Starts.Day())
+	assert.Equal(t, 20, day.Starts.Hour())
+	assert.Equal(t, 16*time.Hour, day.Expires.Sub(day.Starts))
+
+	// 3 tornado, 2 hail, 1 wind and 4 categorical
+	assert.Len(t, day.Areas, 10)
+
+	sign := day.Area(OutlookTornado, OutlookSignificant)
+	require.NotNil(t, sign)
+	assert.Equal(t, 1, sign.Polygon.NumPolygons())
+
+	enh := day.Area(OutlookCategorical, "ENH")
+	require.NotNil(t, enh)
+	ring := enh.Polygon.Polygon(0).LinearRing(0).Coords()
+	assert.Equal(t, geom.Coord{-99.0, 35.0}, ring[0])
+	assert.Equal(t, ring[0], ring[len(ring)-1])
+
+	assert.Nil(t, day.Area(OutlookCategorical, "HIGH"))
+}
+
+func TestParseOutlookHoles(t *testing.T) {
+	text := readTestFile(t, "outlook/PTSDY1-2025-05-19.txt")
+
+	outlook, err := ParseOutlook(text)
+	require.NoError(t, err)
+
+	// The counter-clockwise line cuts a hole in the marginal risk
+	mrgl := outlook.Days[0].Area(OutlookCategorical, "MRGL")
+	require.NotNil(t, mrgl)
+	require.Equal(t, 1, mrgl.Polygon.NumPolygons())
+	assert.Equal(t, 2, mrgl.Polygon.Polygon(0).NumLinearRings())
+}
+
+func TestParseOutlookOpenLines(t *testing.T) {
+	text := readTestFile(t, "outlook/PTSDY1-2025-05-19.txt")
+
+	outlook, err := ParseOutlook(text)
+	require.NoError(t, err)
+
+	tstm := outlook.Days[0].Area(OutlookCategorical, "TSTM")
+	require.NotNil(t, tstm)
+	require.Equal(t, 2, tstm.Polygon.NumPolygons())
+
+	// The open line is closed along the Gulf coast
+	gulf := tstm.Polygon.Polygon(0).LinearRing(0).Coords()
+	assert.Equal(t, gulf[0], gulf[len(gulf)-1])
+	assert.True(t, pointInRing(geom.Coord{-96.8, 32.8}, gulf), "Dallas should be in the thunderstorm area")
+	assert.True(t, pointInRing(geom.Coord{-91.2, 30.4}, gulf), "Baton Rouge should be in the thunderstorm area")
+	assert.False(t, pointInRing(geom.Coord{-84.4, 33.7}, gulf), "Atlanta should not be in the thunderstorm area")
+	assert.False(t, pointInRing(geom.Coord{-97.5, 37.7}, gulf), "Wichita should not be in the thunderstorm area")
+
+	// Everything is clipped to the CONUS bounds
+	for _, c := range gulf {
+		assert.GreaterOrEqual(t, c.Y(), conusMinY)
+	}
+}
+
+func TestParseOutlookDay48(t *testing.T) {
+	text := readTestFile(t, "outlook/PTSD48-2025-05-19.txt")
+
+	outlook, err := ParseOutlook(text)
+	require.NoError(t, err)
+	require.Len(t, outlook.Days, 5)
+
+	for i, day := range outlook.Days {
+		assert.Equal(t, i+4, day.Day)
+		assert.Equal(t, 22+i, day.Starts.Day())
+		assert.Equal(t, 12, day.Starts.Hour())
+		assert.Equal(t, 24*time.Hour, day.Expires.Sub(day.Starts))
+	}
+
+	assert.NotNil(t, outlook.Days[0].Area(OutlookAnySevere, "0.15"))
+	assert.NotNil(t, outlook.Days[1].Area(OutlookAnySevere, "0.15"))
+	assert.Empty(t, outlook.Days[2].Areas)
+}
+
+func TestParseOutlookNoDays(t *testing.T) {
+	text := readTestFile(t, "mcd/SWOMCD-0512-2025.txt")
+
+	_, err := ParseOutlook(text)
+	assert.Error(t, err)
+}
@@ -0,0 +1,429 @@
+package products
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twpayne/go-geom"
+)
+
+/*
+Tropical cyclone products issued by the NHC and CPHC, and the local watch/warning statements of the WFOs, all carry a
+header identifying the storm and the advisory they belong to:
+
+	Hurricane Milton Forecast/Advisory Number  17
+	NWS National Hurricane Center Miami FL       AL142024
+
+The ATCF storm ID (AL142024) is made up of the basin, the storm number and the year.
+*/
+
+// Storm classifications, longest first so they are matched before their shorter forms
+var TropicalClassifications = []string{
+	"POTENTIAL TROPICAL CYCLONE",
+	"POST-TROPICAL CYCLONE",
+	"SUBTROPICAL DEPRESSION",
+	"SUBTROPICAL STORM",
+	"TROPICAL DEPRESSION",
+	"TROPICAL STORM",
+	"SUPER TYPHOON",
+	"REMNANTS OF",
+	"HURRICANE",
+	"TYPHOON",
+}
+
+var ErrNoTropicalHeader = errors.New("no tropical cyclone header found")
+
+var (
+	tropicalHeaderRegexp  = regexp.MustCompile(`(?mi)^(.+?)\s+(Intermediate\s+)?(Local Watch/Warning Statement/Advisory|Watch/Warning Breakpoints/Advisory|Forecast/Advisory|Advisory)\s+Number\s+([0-9]+[A-Z]?)\s*$`)
+	tropicalStormIDRegexp = regexp.MustCompile(`(?m)\b(AL|EP|CP|WP)([0-9]{2})([0-9]{4})\s*$`)
+)
+
+// The storm and advisory a tropical product belongs to
+type TropicalStorm struct {
+	ID             string `json:"id"` // ATCF storm ID, e.g. AL142024
+	Basin          string `json:"basin"`
+	Number         int    `json:"number"`
+	Year           int    `json:"year"`
+	Classification string `json:"classification"`
+	Name           string `json:"name"`
+	Advisory       string `json:"advisory"` // Advisory number including any intermediate letter, e.g. 17A
+	Intermediate   bool   `json:"intermediate"`
+}
+
+// Parses the storm header of a tropical product.
+func ParseTropicalStorm(text string) (*TropicalStorm, error) {
+	header := tropicalHeaderRegexp.FindStringSubmatch(text)
+	if header == nil {
+		return nil, ErrNoTropicalHeader
+	}
+
+	id := tropicalStormIDRegexp.FindStringSubmatch(text)
+	if id == nil {
+		return nil, fmt.Errorf("error parsing tropical header: No storm ID found")
+	}
+	number, err := strconv.Atoi(id[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing storm number: %s", err.Error())
+	}
+	year, err := strconv.Atoi(id[3])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing storm year: %s", err.Error())
+	}
+
+	storm := &TropicalStorm{
+		ID:           id[0],
+		Basin:        id[1],
+		Number:       number,
+		Year:         year,
+		Advisory:     strings.ToUpper(header[4]),
+		Intermediate: header[2] != "",
+	}
+	storm.ID = strings.TrimSpace(storm.ID)
+
+	// Split the classification from the name of the storm
+	title := strings.ToUpper(strings.TrimSpace(header[1]))
+	storm.Name = title
+	for _, classification := range TropicalClassifications {
+		if strings.HasPrefix(title, classification+" ") {
+			storm.Classification = classification
+			storm.Name = strings.TrimSpace(strings.TrimPrefix(title, classification))
+			break
+		}
+	}
+
+	return storm, nil
+}
+
+// The radii of a wind or sea threshold in nautical miles for each quadrant
+type TropicalRadii struct {
+	Threshold int `json:"threshold"` // Knots for winds, feet for seas
+	NE        int `json:"ne"`
+	SE        int `json:"se"`
+	SW        int `json:"sw"`
+	NW        int `json:"nw"`
+}
+
+// A forecast point of a TCM
+type TCMForecast struct {
+	Valid     time.Time       `json:"valid"` // Only day, hour, minute
+	Center    *geom.Point     `json:"center"`
+	Status    string          `json:"status,omitempty"` // INLAND, POST-TROP/EXTRATROP, DISSIPATED, ...
+	Outlook   bool            `json:"outlook"`          // Whether the point is part of the extended outlook
+	MaxWind   *int            `json:"max_wind"`
+	Gusts     *int            `json:"gusts"`
+	WindRadii []TropicalRadii `json:"wind_radii"`
+}
+
+// Tropical cyclone forecast/advisory (TCM)
+type TCM struct {
+	Original          string          `json:"original"`
+	Storm             TropicalStorm   `json:"storm"`
+	Center            *geom.Point     `json:"center"`
+	Observed          time.Time       `json:"observed"` // Only day, hour, minute
+	Accuracy          *int            `json:"accuracy"`
+	MovementDirection *int            `json:"movement_direction"`
+	MovementSpeed     *int            `json:"movement_speed"` // Knots
+	Pressure          *int            `json:"pressure"`       // Millibars
+	EyeDiameter       *int            `json:"eye_diameter"`
+	MaxWind           *int            `json:"max_wind"` // Knots
+	Gusts             *int            `json:"gusts"`
+	WindRadii         []TropicalRadii `json:"wind_radii"`
+	SeaRadii          *TropicalRadii  `json:"sea_radii"`
+	Forecasts         []TCMForecast   `json:"forecasts"`
+}
+
+var (
+	tcmCenterRegexp   = regexp.MustCompile(`CENTER LOCATED NEAR\s+([0-9.]+)([NS])\s+([0-9.]+)([EW]) AT ([0-9]{2}/[0-9]{4}Z)`)
+	tcmAccuracyRegexp = regexp.MustCompile(`POSITION ACCURATE WITHIN\s+([0-9]+) NM`)
+	tcmMovementRegexp = regexp.MustCompile(`PRESENT MOVEMENT TOWARD THE .+? OR\s+([0-9]+) DEGREES AT\s+([0-9]+) KT`)
+	tcmPressureRegexp = regexp.MustCompile(`MINIMUM CENTRAL PRESSURE\s+([0-9]+) MB`)
+	tcmEyeRegexp      = regexp.MustCompile(`EYE DIAMETER\s+([0-9]+) NM`)
+	tcmMaxWindRegexp  = regexp.MustCompile(`MAX SUSTAINED WINDS\s+([0-9]+) KT WITH GUSTS TO\s+([0-9]+) KT`)
+	tcmRadiiRegexp    = regexp.MustCompile(`^([0-9]+) (KT|FT SEAS)\.+\s*([0-9]+)NE\s+([0-9]+)SE\s+([0-9]+)SW\s+([0-9]+)NW`)
+	tcmValidRegexp    = regexp.MustCompile(`^(FORECAST|OUTLOOK) VALID ([0-9]{2}/[0-9]{4}Z)(?:\s+([0-9.]+)([NS])\s+([0-9.]+)([EW]))?(?:\.\.\.(.+))?`)
+	tcmForecastRegexp = regexp.MustCompile(`MAX WIND\s+([0-9]+) KT\.\.\.GUSTS\s+([0-9]+) KT`)
+)
+
+// Parses a tropical cyclone forecast/advisory (TCM) from the given text.
+func ParseTCM(text string) (*TCM, error) {
+	text = strings.ReplaceAll(text, "\r", "")
+
+	storm, err := ParseTropicalStorm(text)
+	if err != nil {
+		return nil, err
+	}
+
+	tcm := &TCM{
+		Original: text,
+		Storm:    *storm,
+	}
+
+	// The current conditions come before the forecast points
+	current := text
+	if i := strings.Index(text, "FORECAST VALID"); i >= 0 {
+		current = text[:i]
+	}
+
+	center := tcmCenterRegexp.FindStringSubmatch(current)
+	if center == nil {
+		return nil, errors.New("error parsing tcm: No center location found")
+	}
+	tcm.Center, err = tropicalPoint(center[1], center[2], center[3], center[4])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tcm center: %s", err.Error())
+	}
+	tcm.Observed, err = time.Parse("02/1504Z", center[5])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tcm center time: %s", err.Error())
+	}
+
+	tcm.Accuracy = findInt(tcmAccuracyRegexp, current, 1)
+	tcm.MovementDirection = findInt(tcmMovementRegexp, current, 1)
+	tcm.MovementSpeed = findInt(tcmMovementRegexp, current, 2)
+	tcm.Pressure = findInt(tcmPressureRegexp, current, 1)
+	tcm.EyeDiameter = findInt(tcmEyeRegexp, current, 1)
+	tcm.MaxWind = findInt(tcmMaxWindRegexp, current, 1)
+	tcm.Gusts = findInt(tcmMaxWindRegexp, current, 2)
+
+	for _, line := range strings.Split(current, "\n") {
+		radii, seas := parseRadii(line)
+		if radii == nil {
+			continue
+		}
+		if seas {
+			tcm.SeaRadii = radii
+		} else {
+			tcm.WindRadii = append(tcm.WindRadii, *radii)
+		}
+	}
+
+	// Each forecast point is a block of lines starting with the valid time
+	var forecast *TCMForecast
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		if match := tcmValidRegexp.FindStringSubmatch(line); match != nil {
+			tcm.Forecasts = append(tcm.Forecasts, TCMForecast{
+				Outlook: match[1] == "OUTLOOK",
+				Status:  strings.TrimSpace(match[7]),
+			})
+			forecast = &tcm.Forecasts[len(tcm.Forecasts)-1]
+
+			forecast.Valid, err = time.Parse("02/1504Z", match[2])
+			if err != nil {
+				return nil, fmt.Errorf("error parsing tcm forecast time: %s", err.Error())
+			}
+			if match[3] != "" {
+				forecast.Center, err = tropicalPoint(match[3], match[4], match[5], match[6])
+				if err != nil {
+					return nil, fmt.Errorf("error parsing tcm forecast center: %s", err.Error())
+				}
+			}
+			continue
+		}
+
+		if forecast == nil {
+			continue
+		}
+		if line == "" {
+			forecast = nil
+			continue
+		}
+
+		if match := tcmForecastRegexp.FindStringSubmatch(line); match != nil {
+			forecast.MaxWind = findInt(tcmForecastRegexp, line, 1)
+			forecast.Gusts = findInt(tcmForecastRegexp, line, 2)
+			continue
+		}
+
+		if radii, seas := parseRadii(line); radii != nil && !seas {
+			forecast.WindRadii = append(forecast.WindRadii, *radii)
+		}
+	}
+
+	return tcm, nil
+}
+
+// Tropical cyclone public advisory (TCP)
+type TCP struct {
+	Original          string        `json:"original"`
+	Storm             TropicalStorm `json:"storm"`
+	Center            *geom.Point   `json:"center"`
+	MaxWind           *int          `json:"max_wind"` // Miles per hour
+	MovementDirection *int          `json:"movement_direction"`
+	MovementSpeed     *int          `json:"movement_speed"` // Miles per hour
+	Pressure          *int          `json:"pressure"`       // Millibars
+}
+
+var (
+	tcpLocationRegexp = regexp.MustCompile(`(?i)LOCATION\.\.\.([0-9.]+)([NS])\s+([0-9.]+)([EW])`)
+	tcpMaxWindRegexp  = regexp.MustCompile(`(?i)MAXIMUM SUSTAINED WINDS\.\.\.([0-9]+) MPH`)
+	tcpMovementRegexp = regexp.MustCompile(`(?i)PRESENT MOVEMENT\.\.\.[A-Z]+ OR ([0-9]+) DEGREES AT ([0-9]+) MPH`)
+	tcpPressureRegexp = regexp.MustCompile(`(?i)MINIMUM CENTRAL PRESSURE\.\.\.([0-9]+) MB`)
+)
+
+// Parses a tropical cyclone public advisory (TCP) from the given text.
+func ParseTCP(text string) (*TCP, error) {
+	text = strings.ReplaceAll(text, "\r", "")
+
+	storm, err := ParseTropicalStorm(text)
+	if err != nil {
+		return nil, err
+	}
+
+	tcp := &TCP{
+		Original: text,
+		Storm:    *storm,
+	}
+
+	location := tcpLocationRegexp.FindStringSubmatch(text)
+	if location == nil {
+		return nil, errors.New("error parsing tcp: No location found")
+	}
+	tcp.Center, err = tropicalPoint(location[1], location[2], location[3], location[4])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing tcp location: %s", err.Error())
+	}
+
+	tcp.MaxWind = findInt(tcpMaxWindRegexp, text, 1)
+	tcp.MovementDirection = findInt(tcpMovementRegexp, text, 1)
+	tcp.MovementSpeed = findInt(tcpMovementRegexp, text, 2)
+	tcp.Pressure = findInt(tcpPressureRegexp, text, 1)
+
+	return tcp, nil
+}
+
+// The hazards of a TCV segment
+const (
+	TCVWind         = "WIND"
+	TCVStormSurge   = "STORM SURGE"
+	TCVFloodingRain = "FLOODING RAIN"
+	TCVTornadoes    = "TORNADOES"
+)
+
+// A hazard of a TCV segment
+type TCVHazard struct {
+	Forecast string `json:"forecast"` // The latest local forecast
+	Peak     string `json:"peak"`     // The peak wind, surge or rainfall forecast
+	Threat   string `json:"threat"`   // The threat to life and property
+}
+
+// The local forecast of a segment of a tropical cyclone watch/warning product (TCV)
+type TCVSegment struct {
+	Locations []string              `json:"locations"`
+	Hazards   map[string]*TCVHazard `json:"hazards"`
+}
+
+var (
+	tcvSectionRegexp  = regexp.MustCompile(`^\* ([A-Z ]+):?$`)
+	tcvForecastRegexp = regexp.MustCompile(`LATEST LOCAL FORECAST:\s*(.*)$`)
+	tcvPeakRegexp     = regexp.MustCompile(`^-\s*Peak [^:]+:\s*(.+)$`)
+	tcvThreatRegexp   = regexp.MustCompile(`INTENSITY:\s*([A-Za-z]+)`)
+)
+
+// Parses the local forecast of a TCV segment. Returns nil if the segment has no local forecast.
+func ParseTCVSegment(text string) *TCVSegment {
+	text = strings.ReplaceAll(text, "\r", "")
+
+	segment := &TCVSegment{
+		Locations: []string{},
+		Hazards:   map[string]*TCVHazard{},
+	}
+
+	section := ""
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if match := tcvSectionRegexp.FindStringSubmatch(trimmed); match != nil {
+			section = match[1]
+			switch section {
+			case TCVWind, TCVStormSurge, TCVFloodingRain, TCVTornadoes:
+				segment.Hazards[section] = &TCVHazard{}
+			}
+			continue
+		}
+
+		if section == "LOCATIONS AFFECTED" {
+			if location, ok := strings.CutPrefix(trimmed, "- "); ok {
+				segment.Locations = append(segment.Locations, strings.TrimSpace(location))
+			}
+			continue
+		}
+
+		hazard, ok := segment.Hazards[section]
+		if !ok {
+			continue
+		}
+		if match := tcvForecastRegexp.FindStringSubmatch(trimmed); match != nil {
+			hazard.Forecast = strings.TrimSpace(match[1])
+		} else if match := tcvPeakRegexp.FindStringSubmatch(trimmed); match != nil {
+			hazard.Peak = strings.TrimSpace(match[1])
+		} else if match := tcvThreatRegexp.FindStringSubmatch(trimmed); match != nil {
+			hazard.Threat = match[1]
+		}
+	}
+
+	if len(segment.Hazards) == 0 && len(segment.Locations) == 0 {
+		return nil
+	}
+
+	return segment
+}
+
+// Parse a wind or sea radii line. Returns whether the radii are for seas.
+func parseRadii(line string) (*TropicalRadii, bool) {
+	match := tcmRadiiRegexp.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return nil, false
+	}
+
+	values := make([]int, 5)
+	for i, s := range []string{match[1], match[3], match[4], match[5], match[6]} {
+		// The regexp only matches digits
+		values[i], _ = strconv.Atoi(s)
+	}
+
+	return &TropicalRadii{
+		Threshold: values[0],
+		NE:        values[1],
+		SE:        values[2],
+		SW:        values[3],
+		NW:        values[4],
+	}, match[2] == "FT SEAS"
+}
+
+// Convert a latitude and longitude with hemispheres to a point
+func tropicalPoint(lat string, ns string, lon string, ew string) (*geom.Point, error) {
+	y, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return nil, err
+	}
+	x, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return nil, err
+	}
+	if ns == "S" {
+		y = -y
+	}
+	if ew == "W" {
+		x = -x
+	}
+	return geom.NewPointFlat(geom.XY, []float64{x, y}), nil
+}
+
+// Find the integer in the given group of the regexp. Returns nil if there is no match.
+func findInt(r *regexp.Regexp, text string, group int) *int {
+	match := r.FindStringSubmatch(text)
+	if match == nil {
+		return nil
+	}
+	v, err := strconv.Atoi(match[group])
+	if err != nil {
+		return nil
+	}
+	return &v
+}
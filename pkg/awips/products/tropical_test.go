@@ -0,0 +1,137 @@
+package products
+
+import (
+	"testing"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTropicalStorm(t *testing.T) {
+	text := readTestFile(t, "tropical/TCVTBW-AL142024-17.txt")
+
+	storm, err := ParseTropicalStorm(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, "AL142024", storm.ID)
+	assert.Equal(t, "AL", storm.Basin)
+	assert.Equal(t, 14, storm.Number)
+	assert.Equal(t, 2024, storm.Year)
+	assert.Equal(t, "HURRICANE", storm.Classification)
+	assert.Equal(t, "MILTON", storm.Name)
+	assert.Equal(t, "17", storm.Advisory)
+	assert.False(t, storm.Intermediate)
+
+	_, err = ParseTropicalStorm(readTestFile(t, "mcd/SWOMCD-0512-2025.txt"))
+	assert.ErrorIs(t, err, ErrNoTropicalHeader)
+}
+
+func TestParseTCM(t *testing.T) {
+	text := readTestFile(t, "tropical/TCMAT4-AL142024-17.txt")
+
+	tcm, err := ParseTCM(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, "AL142024", tcm.Storm.ID)
+	assert.Equal(t, "17", tcm.Storm.Advisory)
+	assert.Equal(t, []float64{-84.2, 26.1}, tcm.Center.FlatCoords())
+	assert.Equal(t, 9, tcm.Observed.Day())
+	assert.Equal(t, 15, tcm.Observed.Hour())
+	assert.Equal(t, 10, *tcm.Accuracy)
+	assert.Equal(t, 40, *tcm.MovementDirection)
+	assert.Equal(t, 10, *tcm.MovementSpeed)
+	assert.Equal(t, 928, *tcm.Pressure)
+	assert.Equal(t, 15, *tcm.EyeDiameter)
+	assert.Equal(t, 125, *tcm.MaxWind)
+	assert.Equal(t, 150, *tcm.Gusts)
+
+	require.Len(t, tcm.WindRadii, 3)
+	assert.Equal(t, TropicalRadii{Threshold: 34, NE: 120, SE: 120, SW: 100, NW: 110}, tcm.WindRadii[2])
+	require.NotNil(t, tcm.SeaRadii)
+	assert.Equal(t, TropicalRadii{Threshold: 12, NE: 240, SE: 240, SW: 180, NW: 240}, *tcm.SeaRadii)
+
+	require.Len(t, tcm.Forecasts, 5)
+
+	first := tcm.Forecasts[0]
+	assert.Equal(t, 10, first.Valid.Day())
+	assert.Equal(t, 0, first.Valid.Hour())
+	assert.Equal(t, []float64{-83.0, 27.0}, first.Center.FlatCoords())
+	assert.Equal(t, 115, *first.MaxWind)
+	assert.Equal(t, 140, *first.Gusts)
+	assert.Len(t, first.WindRadii, 3)
+	assert.False(t, first.Outlook)
+	assert.Empty(t, first.Status)
+
+	assert.Equal(t, "INLAND", tcm.Forecasts[1].Status)
+	assert.Equal(t, "POST-TROP/EXTRATROP", tcm.Forecasts[2].Status)
+	assert.Len(t, tcm.Forecasts[2].WindRadii, 2)
+
+	outlook := tcm.Forecasts[3]
+	assert.True(t, outlook.Outlook)
+	assert.Equal(t, 50, *outlook.MaxWind)
+	assert.Empty(t, outlook.WindRadii)
+
+	dissipated := tcm.Forecasts[4]
+	assert.Equal(t, "DISSIPATED", dissipated.Status)
+	assert.Nil(t, dissipated.Center)
+	assert.Nil(t, dissipated.MaxWind)
+}
+
+func TestParseTCP(t *testing.T) {
+	text := readTestFile(t, "tropical/TCPAT4-AL142024-17.txt")
+
+	tcp, err := ParseTCP(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, "MILTON", tcp.Storm.Name)
+	assert.Equal(t, "17", tcp.Storm.Advisory)
+	assert.Equal(t, []float64{-84.2, 26.1}, tcp.Center.FlatCoords())
+	assert.Equal(t, 145, *tcp.MaxWind)
+	assert.Equal(t, 40, *tcp.MovementDirection)
+	assert.Equal(t, 12, *tcp.MovementSpeed)
+	assert.Equal(t, 928, *tcp.Pressure)
+}
+
+func TestParseTCPIntermediate(t *testing.T) {
+	text := readTestFile(t, "tropical/TCPAT4-AL142024-17A.txt")
+
+	tcp, err := ParseTCP(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, "17A", tcp.Storm.Advisory)
+	assert.True(t, tcp.Storm.Intermediate)
+	assert.Equal(t, []float64{-83.9, 26.4}, tcp.Center.FlatCoords())
+	assert.Nil(t, tcp.MovementDirection)
+	assert.Nil(t, tcp.MovementSpeed)
+	assert.Equal(t, 929, *tcp.Pressure)
+}
+
+func TestParseTCVSegment(t *testing.T) {
+	text := readTestFile(t, "tropical/TCVTBW-AL142024-17.txt")
+
+	product, err := awips.New(text)
+	require.NoError(t, err)
+	require.Len(t, product.Segments, 2)
+
+	segment := ParseTCVSegment(product.Segments[0].Text)
+	require.NotNil(t, segment)
+	assert.Equal(t, []string{"St. Petersburg", "Clearwater"}, segment.Locations)
+	require.Len(t, segment.Hazards, 4)
+
+	wind := segment.Hazards[TCVWind]
+	assert.Equal(t, "Equivalent Category 3 Hurricane force wind", wind.Forecast)
+	assert.Equal(t, "100-120 mph with gusts to 145 mph", wind.Peak)
+	assert.Equal(t, "Extreme", wind.Threat)
+
+	surge := segment.Hazards[TCVStormSurge]
+	assert.Equal(t, "The potential for 10-15 feet above ground somewhere within surge prone areas", surge.Peak)
+	assert.Equal(t, "Extreme", surge.Threat)
+
+	assert.Equal(t, "Moderate", segment.Hazards[TCVTornadoes].Threat)
+
+	inland := ParseTCVSegment(product.Segments[1].Text)
+	require.NotNil(t, inland)
+	assert.Equal(t, []string{"Largo"}, inland.Locations)
+	assert.NotContains(t, inland.Hazards, TCVStormSurge)
+}
@@ -0,0 +1,327 @@
+package products
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metdatasystem/us/pkg/awips"
+	"github.com/twpayne/go-geom"
+)
+
+/*
+SPC watches are issued as a set of products that each describe part of the watch:
+
+  - SEL: The public watch statement with the watch number, type, PDS flag and summary.
+  - WWP: The watch probability and attribute tables.
+  - SAW: The aviation watch with the approximate parallelogram of the watch area.
+  - WOU: The watch outline update containing the VTEC and county list of the watch.
+
+The watch number ties all of these products together along with the TO.A and SV.A VTEC events.
+*/
+
+// The VTEC phenomena of SPC watches
+const (
+	WatchTornado            = "TO"
+	WatchSevereThunderstorm = "SV"
+)
+
+var ErrNoWatchNumber = errors.New("no watch number found")
+
+// Convert the watch type text to the VTEC phenomena of the watch
+func watchPhenomena(text string) string {
+	switch strings.ToUpper(strings.TrimSpace(text)) {
+	case "TORNADO", "WT":
+		return WatchTornado
+	case "SEVERE THUNDERSTORM", "SEVERE TSTM", "WS":
+		return WatchSevereThunderstorm
+	}
+	return ""
+}
+
+// SPC products indent their text so the lines are trimmed before the generic AWIPS parsers are used.
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Public watch statement (SEL)
+type SEL struct {
+	Original  string `json:"original"`
+	Number    int    `json:"number"`
+	Phenomena string `json:"phenomena"`
+	IsPDS     bool   `json:"is_pds"`
+	Summary   string `json:"summary"`
+}
+
+// Parses a public watch statement (SEL) from the given text.
+func ParseSEL(text string) (*SEL, error) {
+	watchRegexp := regexp.MustCompile(`(?i)(Tornado|Severe Thunderstorm) Watch Number ([0-9]+)`)
+	match := watchRegexp.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("error parsing sel: %w", ErrNoWatchNumber)
+	}
+
+	number, err := strconv.Atoi(match[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing sel number: %s", err.Error())
+	}
+
+	// The summary is the paragraph following SUMMARY...
+	var summary string
+	summaryRegexp := regexp.MustCompile(`(?s)SUMMARY\.\.\.(.+?)(\n\s*\n|$)`)
+	summaryMatch := summaryRegexp.FindStringSubmatch(dedent(text))
+	if summaryMatch != nil {
+		summary = strings.Join(strings.Fields(summaryMatch[1]), " ")
+	}
+
+	pdsRegexp := regexp.MustCompile(awips.PDSRegexp)
+
+	sel := SEL{
+		Original:  text,
+		Number:    number,
+		Phenomena: watchPhenomena(match[1]),
+		IsPDS:     pdsRegexp.MatchString(text),
+		Summary:   summary,
+	}
+
+	return &sel, nil
+}
+
+// Watch probabilities (WWP)
+//
+// Probabilities reported as less than a value (e.g. <05%) are stored as 0.
+type WWP struct {
+	Original  string `json:"original"`
+	Number    int    `json:"number"`
+	Phenomena string `json:"phenomena"`
+	IsPDS     bool   `json:"is_pds"`
+	// Probability table
+	ProbTornadoes        int `json:"prob_tornadoes"`          // 2 or more tornadoes
+	ProbStrongTornadoes  int `json:"prob_strong_tornadoes"`   // 1 or more EF2-EF5 tornadoes
+	ProbSevereWind       int `json:"prob_severe_wind"`        // 10 or more severe wind events
+	ProbSignificantWind  int `json:"prob_significant_wind"`   // 1 or more wind events >= 65 knots
+	ProbSevereHail       int `json:"prob_severe_hail"`        // 10 or more severe hail events
+	ProbSignificantHail  int `json:"prob_significant_hail"`   // 1 or more hail events >= 2 inches
+	ProbCombinedHailWind int `json:"prob_combined_hail_wind"` // 6 or more combined severe hail/wind events
+	// Attribute table
+	MaxHail         float64 `json:"max_hail"`         // Inches
+	MaxWindGust     int     `json:"max_wind_gust"`    // Knots
+	MaxTops         int     `json:"max_tops"`         // Hundreds of feet
+	MotionDirection int     `json:"motion_direction"` // Degrees
+	MotionSpeed     int     `json:"motion_speed"`     // Knots
+}
+
+var wwpProbabilities = []struct {
+	Regexp string
+	Field  func(wwp *WWP) *int
+}{
+	{`PROB OF 2 OR MORE TORNADOES\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbTornadoes }},
+	{`PROB OF 1 OR MORE STRONG /EF2-EF5/ TORNADOES\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbStrongTornadoes }},
+	{`PROB OF 10 OR MORE SEVERE WIND EVENTS\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbSevereWind }},
+	{`PROB OF 1 OR MORE WIND EVENTS >= 65 KNOTS\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbSignificantWind }},
+	{`PROB OF 10 OR MORE SEVERE HAIL EVENTS\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbSevereHail }},
+	{`PROB OF 1 OR MORE HAIL EVENTS >= 2 INCHES\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbSignificantHail }},
+	{`PROB OF 6 OR MORE COMBINED SEVERE HAIL/WIND EVENTS\s*:\s*(<?)([0-9]+)%`, func(wwp *WWP) *int { return &wwp.ProbCombinedHailWind }},
+}
+
+// Parses the watch probabilities (WWP) from the given text.
+func ParseWWP(text string) (*WWP, error) {
+	watchRegexp := regexp.MustCompile(`(?m)^\s*(WT|WS) ([0-9]{4})( PDS)?\s*$`)
+	match := watchRegexp.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("error parsing wwp: %w", ErrNoWatchNumber)
+	}
+
+	number, err := strconv.Atoi(match[2])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing wwp number: %s", err.Error())
+	}
+
+	wwp := WWP{
+		Original:  text,
+		Number:    number,
+		Phenomena: watchPhenomena(match[1]),
+		IsPDS:     match[3] != "",
+	}
+
+	for _, probability := range wwpProbabilities {
+		m := regexp.MustCompile(probability.Regexp).FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("error parsing wwp: missing probability %s", probability.Regexp)
+		}
+		if m[1] == "<" {
+			continue
+		}
+		value, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing wwp probability: %s", err.Error())
+		}
+		*probability.Field(&wwp) = value
+	}
+
+	// Attribute table
+	if m := regexp.MustCompile(`MAX HAIL /INCHES/\s*:\s*([0-9.]+)`).FindStringSubmatch(text); m != nil {
+		wwp.MaxHail, err = strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing wwp max hail: %s", err.Error())
+		}
+	}
+	if m := regexp.MustCompile(`MAX WIND GUSTS SURFACE /KNOTS/\s*:\s*([0-9]+)`).FindStringSubmatch(text); m != nil {
+		wwp.MaxWindGust, err = strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing wwp max wind gust: %s", err.Error())
+		}
+	}
+	if m := regexp.MustCompile(`MAX TOPS /X 100 FEET/\s*:\s*([0-9]+)`).FindStringSubmatch(text); m != nil {
+		wwp.MaxTops, err = strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing wwp max tops: %s", err.Error())
+		}
+	}
+	if m := regexp.MustCompile(`STORM MOTION /DEGREES AND KNOTS/\s*:\s*([0-9]{3})([0-9]{2,3})`).FindStringSubmatch(text); m != nil {
+		wwp.MotionDirection, _ = strconv.Atoi(m[1])
+		wwp.MotionSpeed, _ = strconv.Atoi(m[2])
+	}
+	if regexp.MustCompile(`PARTICULARLY DANGEROUS SITUATION\s*:\s*YES`).MatchString(text) {
+		wwp.IsPDS = true
+	}
+
+	return &wwp, nil
+}
+
+// Aviation watch (SAW)
+type SAW struct {
+	Original  string        `json:"original"`
+	Number    int           `json:"number"`
+	Phenomena string        `json:"phenomena"`
+	States    []string      `json:"states"`
+	Issued    time.Time     `json:"issued"`  // Only day, hour, minute
+	Expires   time.Time     `json:"expires"` // Only day, hour, minute
+	Cancelled bool          `json:"cancelled"`
+	Polygon   *geom.Polygon `json:"polygon"` // The approximate parallelogram of the watch
+}
+
+// Parses an aviation watch (SAW) from the given text.
+func ParseSAW(text string) (*SAW, error) {
+	watchRegexp := regexp.MustCompile(`(?m)^\s*WW ([0-9]+) (TORNADO|SEVERE TSTM) ([A-Z ]+?) ([0-9]{6}Z) - ([0-9]{6}Z)`)
+	match := watchRegexp.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("error parsing saw: %w", ErrNoWatchNumber)
+	}
+
+	number, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing saw number: %s", err.Error())
+	}
+
+	issued, err := time.Parse("021504Z", match[4])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing saw issued time: %s", err.Error())
+	}
+	expires, err := time.Parse("021504Z", match[5])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing saw expire time: %s", err.Error())
+	}
+
+	saw := SAW{
+		Original:  text,
+		Number:    number,
+		Phenomena: watchPhenomena(match[2]),
+		States:    strings.Fields(match[3]),
+		Issued:    issued,
+		Expires:   expires,
+		Cancelled: regexp.MustCompile(`(?m)^\s*CANCEL WW ` + match[1] + `\b`).MatchString(text),
+	}
+
+	latlon, err := awips.ParseLatLon(dedent(text))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing saw latlon: %s", err.Error())
+	}
+	if latlon != nil {
+		saw.Polygon, err = latlon.ToPolygon()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing saw latlon to polygon: %s", err.Error())
+		}
+	} else if !saw.Cancelled {
+		return nil, errors.New("error parsing saw: no latlon found")
+	}
+
+	return &saw, nil
+}
+
+// Watch outline update (WOU)
+type WOU struct {
+	Original  string       `json:"original"`
+	Number    int          `json:"number"`
+	Phenomena string       `json:"phenomena"`
+	Segments  []WOUSegment `json:"segments"`
+}
+
+// A segment of the watch outline update with the VTEC and the counties it applies to
+type WOUSegment struct {
+	VTEC awips.VTEC `json:"vtec"`
+	UGC  []string   `json:"ugc"` // UGC codes (e.g. OKC019)
+}
+
+// Parses a watch outline update (WOU) from the given text.
+func ParseWOU(text string) (*WOU, error) {
+	wou := WOU{
+		Original: text,
+	}
+
+	for _, segment := range strings.Split(text, "$$") {
+		vtecs, errs := awips.ParseVTEC(segment)
+		if len(errs) != 0 {
+			return nil, fmt.Errorf("error parsing wou vtec: %s", errs[0].Error())
+		}
+
+		var vtec *awips.VTEC
+		for i, v := range vtecs {
+			if v.Significance == "A" && (v.Phenomena == WatchTornado || v.Phenomena == WatchSevereThunderstorm) {
+				vtec = &vtecs[i]
+				break
+			}
+		}
+		if vtec == nil {
+			continue
+		}
+
+		ugc, err := awips.ParseUGC(segment)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing wou ugc: %s", err.Error())
+		}
+		if ugc == nil {
+			return nil, fmt.Errorf("error parsing wou: no ugc found for %s", vtec.Original)
+		}
+
+		codes := []string{}
+		for _, state := range ugc.States {
+			for _, area := range state.Areas {
+				codes = append(codes, state.ID+state.Type+area)
+			}
+		}
+
+		if wou.Number == 0 {
+			wou.Number = vtec.EventNumber
+			wou.Phenomena = vtec.Phenomena
+		}
+
+		wou.Segments = append(wou.Segments, WOUSegment{
+			VTEC: *vtec,
+			UGC:  codes,
+		})
+	}
+
+	if len(wou.Segments) == 0 {
+		return nil, fmt.Errorf("error parsing wou: no watch vtec found")
+	}
+
+	return &wou, nil
+}
@@ -0,0 +1,96 @@
+package products
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSEL(t *testing.T) {
+	text := readTestFile(t, "watch/SEL1-0151-2025.txt")
+
+	sel, err := ParseSEL(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, 151, sel.Number)
+	assert.Equal(t, WatchTornado, sel.Phenomena)
+	assert.True(t, sel.IsPDS)
+	assert.Equal(t, "Supercells developing along the dryline will pose a risk of strong tornadoes and very large hail into this evening.", sel.Summary)
+
+	_, err = ParseSEL("Mesoscale Discussion 0512")
+	assert.ErrorIs(t, err, ErrNoWatchNumber)
+}
+
+func TestParseWWP(t *testing.T) {
+	text := readTestFile(t, "watch/WWP1-0151-2025.txt")
+
+	wwp, err := ParseWWP(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, 151, wwp.Number)
+	assert.Equal(t, WatchTornado, wwp.Phenomena)
+	assert.True(t, wwp.IsPDS)
+	assert.Equal(t, 90, wwp.ProbTornadoes)
+	assert.Equal(t, 70, wwp.ProbStrongTornadoes)
+	assert.Equal(t, 40, wwp.ProbSevereWind)
+	// <05%
+	assert.Equal(t, 0, wwp.ProbSignificantWind)
+	assert.Equal(t, 60, wwp.ProbSevereHail)
+	assert.Equal(t, 60, wwp.ProbSignificantHail)
+	assert.Equal(t, 90, wwp.ProbCombinedHailWind)
+	assert.Equal(t, 3.0, wwp.MaxHail)
+	assert.Equal(t, 60, wwp.MaxWindGust)
+	assert.Equal(t, 550, wwp.MaxTops)
+	assert.Equal(t, 240, wwp.MotionDirection)
+	assert.Equal(t, 30, wwp.MotionSpeed)
+}
+
+func TestParseSAW(t *testing.T) {
+	text := readTestFile(t, "watch/SAW1-0151-2025.txt")
+
+	saw, err := ParseSAW(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, 151, saw.Number)
+	assert.Equal(t, WatchTornado, saw.Phenomena)
+	assert.Equal(t, []string{"OK", "TX"}, saw.States)
+	assert.False(t, saw.Cancelled)
+	assert.Equal(t, 7*time.Hour-15*time.Minute, saw.Expires.Sub(saw.Issued))
+
+	require.NotNil(t, saw.Polygon)
+	coords := saw.Polygon.Coords()
+	require.Len(t, coords, 1)
+	// Four corners and the closing point
+	assert.Len(t, coords[0], 5)
+	assert.Equal(t, -99.28, coords[0][0].X())
+	assert.Equal(t, 34.68, coords[0][0].Y())
+
+	text = readTestFile(t, "watch/SAW1-0151-2025-CAN.txt")
+	saw, err = ParseSAW(text)
+	require.NoError(t, err)
+	assert.True(t, saw.Cancelled)
+	assert.Nil(t, saw.Polygon)
+}
+
+func TestParseWOU(t *testing.T) {
+	text := readTestFile(t, "watch/WOU1-0151-2025.txt")
+
+	wou, err := ParseWOU(text)
+	require.NoError(t, err)
+
+	assert.Equal(t, 151, wou.Number)
+	assert.Equal(t, WatchTornado, wou.Phenomena)
+	require.Len(t, wou.Segments, 2)
+	assert.Equal(t, "NEW", wou.Segments[0].VTEC.Action)
+	assert.Equal(t, []string{"OKC019", "OKC067", "OKC085", "OKC095", "OKC099"}, wou.Segments[0].UGC)
+	assert.Equal(t, []string{"TXC077", "TXC097", "TXC337", "TXC485"}, wou.Segments[1].UGC)
+
+	text = readTestFile(t, "watch/WOU1-0151-2025-2.txt")
+	wou, err = ParseWOU(text)
+	require.NoError(t, err)
+	require.Len(t, wou.Segments, 3)
+	assert.Equal(t, "CAN", wou.Segments[1].VTEC.Action)
+	assert.Equal(t, []string{"TXC077", "TXC485"}, wou.Segments[1].UGC)
+}
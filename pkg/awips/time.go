@@ -32,3 +32,20 @@ var Timezones = map[string]*time.Location{
 	// Chamorro/Guam
 	"CHST": time.FixedZone("CHST", 10*60*60),
 }
+
+// Many AWIPS timestamps (WMO headers, "Valid 192354Z" lines, UGC expiry) only carry the day, hour and minute.
+// MergeDayTime places the day and time of t into the month and year of the reference time, rolling into the
+// previous or next month when the day would otherwise be more than half a month away from the reference.
+func MergeDayTime(reference time.Time, t time.Time) time.Time {
+	reference = reference.UTC()
+	merged := time.Date(reference.Year(), reference.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+
+	switch {
+	case merged.Sub(reference) > 15*24*time.Hour:
+		merged = time.Date(reference.Year(), reference.Month()-1, t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	case reference.Sub(merged) > 15*24*time.Hour:
+		merged = time.Date(reference.Year(), reference.Month()+1, t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	}
+
+	return merged
+}
@@ -0,0 +1,35 @@
+package awips
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDayTime(t *testing.T) {
+	dayTime, err := time.Parse("021504Z", "200130Z")
+	assert.NoError(t, err)
+
+	// Same month
+	reference := time.Date(2025, time.April, 19, 23, 54, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.April, 20, 1, 30, 0, 0, time.UTC), MergeDayTime(reference, dayTime))
+
+	// Rolls into the next month
+	dayTime, err = time.Parse("021504Z", "010030Z")
+	assert.NoError(t, err)
+	reference = time.Date(2025, time.December, 31, 22, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, time.January, 1, 0, 30, 0, 0, time.UTC), MergeDayTime(reference, dayTime))
+
+	// Rolls into the previous month
+	dayTime, err = time.Parse("021504Z", "302330Z")
+	assert.NoError(t, err)
+	reference = time.Date(2025, time.May, 1, 0, 10, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, time.April, 30, 23, 30, 0, 0, time.UTC), MergeDayTime(reference, dayTime))
+
+	// Local reference times are converted to UTC first
+	dayTime, err = time.Parse("021504Z", "200100Z")
+	assert.NoError(t, err)
+	reference = time.Date(2025, time.April, 19, 19, 54, 0, 0, Timezones["CDT"])
+	assert.Equal(t, time.Date(2025, time.April, 20, 1, 0, 0, 0, time.UTC), MergeDayTime(reference, dayTime))
+}
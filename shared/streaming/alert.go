@@ -0,0 +1,40 @@
+package streaming
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The content type of alerts sent to the AWIPS queue
+const AlertContentType = "application/vnd.mds.alert+json"
+
+// An alert from a CAP, ATOM or JSON-LD feed such as api.weather.gov. Each alert is one segment of
+// a VTEC product, so it can be checked against, or fill in for, the product from NWWS.
+type Alert struct {
+	ID     string    `json:"id"`
+	Source string    `json:"source"`
+	Sent   time.Time `json:"sent"`
+	// When the segment expires, the UGC expiry of the product
+	Expires time.Time `json:"expires"`
+	Event   string    `json:"event"`
+	// The WMO heading and AWIPS identifier of the product the alert came from
+	WMO   string `json:"wmo"`
+	AWIPS string `json:"awips"`
+	// VTEC strings such as /O.NEW.KDMX.TO.W.0042.250519T2310Z-250519T2345Z/
+	VTEC []string `json:"vtec"`
+	// UGC codes such as IAC153
+	UGC []string `json:"ugc"`
+	// The warned area as longitude, latitude pairs
+	Polygon     [][2]float64 `json:"polygon,omitempty"`
+	Headline    string       `json:"headline,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Instruction string       `json:"instruction,omitempty"`
+}
+
+func (a *Alert) Marshal() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func (a *Alert) Unmarshal(data []byte) error {
+	return json.Unmarshal(data, a)
+}
@@ -0,0 +1,576 @@
+package streaming
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// AMQP 0-9-1 frame types
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xCE
+)
+
+// A RabbitMQ broker speaking just enough AMQP 0-9-1 for the transport. It has queues, exchanges
+// that route on the exact routing key, publisher confirms and redelivery of messages that were
+// not acknowledged before their channel closed.
+type fakeBroker struct {
+	listener net.Listener
+	// Messages the broker refuses to take, which are negatively confirmed
+	reject func(body []byte) bool
+
+	mu       sync.Mutex
+	queues   map[string]*fakeQueue
+	bindings map[string][]fakeBinding
+	conns    map[*fakeConn]struct{}
+	tags     int
+}
+
+type fakeBinding struct {
+	queue string
+	key   string
+}
+
+type fakeMessage struct {
+	exchange string
+	key      string
+	// The property flags and properties of the content header, which are passed on untouched
+	properties  []byte
+	size        uint64
+	body        []byte
+	redelivered bool
+}
+
+type fakeQueue struct {
+	messages  []*fakeMessage
+	consumers []*fakeConsumer
+	next      int
+}
+
+type fakeConsumer struct {
+	tag     string
+	channel *fakeChannel
+	queue   *fakeQueue
+}
+
+type fakeUnacked struct {
+	queue   *fakeQueue
+	message *fakeMessage
+}
+
+type fakeChannel struct {
+	id      uint16
+	conn    *fakeConn
+	confirm bool
+	// Publishes seen since confirm mode was selected
+	published uint64
+	delivered uint64
+	unacked   map[uint64]fakeUnacked
+	consumers map[string]*fakeConsumer
+	// The publish waiting for its content
+	publishing *fakeMessage
+}
+
+type fakeConn struct {
+	broker   *fakeBroker
+	conn     net.Conn
+	writeMu  sync.Mutex
+	channels map[uint16]*fakeChannel
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := &fakeBroker{
+		listener: listener,
+		queues:   map[string]*fakeQueue{},
+		bindings: map[string][]fakeBinding{},
+		conns:    map[*fakeConn]struct{}{},
+	}
+	go broker.accept()
+	t.Cleanup(broker.close)
+
+	return broker
+}
+
+func (broker *fakeBroker) url() string {
+	return "amqp://guest:guest@" + broker.listener.Addr().String() + "/"
+}
+
+func (broker *fakeBroker) accept() {
+	for {
+		conn, err := broker.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &fakeConn{broker: broker, conn: conn, channels: map[uint16]*fakeChannel{}}
+		broker.mu.Lock()
+		broker.conns[c] = struct{}{}
+		broker.mu.Unlock()
+
+		go c.serve()
+	}
+}
+
+// Drop every connection without closing them properly, as a broker that goes away would
+func (broker *fakeBroker) disconnect() {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	for c := range broker.conns {
+		c.conn.Close()
+	}
+}
+
+func (broker *fakeBroker) close() {
+	broker.listener.Close()
+	broker.disconnect()
+}
+
+// How many messages are waiting in the queue, including those delivered but not acknowledged
+func (broker *fakeBroker) depth(name string) int {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	queue, ok := broker.queues[name]
+	if !ok {
+		return 0
+	}
+
+	n := len(queue.messages)
+	for c := range broker.conns {
+		for _, ch := range c.channels {
+			for _, unacked := range ch.unacked {
+				if unacked.queue == queue {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// Reads the arguments of a method. Arguments that are missing read as zero.
+type fakeArgs struct {
+	b []byte
+}
+
+func (a *fakeArgs) take(n int) []byte {
+	if len(a.b) < n {
+		a.b = nil
+		return make([]byte, n)
+	}
+	v := a.b[:n]
+	a.b = a.b[n:]
+	return v
+}
+
+func (a *fakeArgs) octet() byte       { return a.take(1)[0] }
+func (a *fakeArgs) short() uint16     { return binary.BigEndian.Uint16(a.take(2)) }
+func (a *fakeArgs) long() uint32      { return binary.BigEndian.Uint32(a.take(4)) }
+func (a *fakeArgs) longlong() uint64  { return binary.BigEndian.Uint64(a.take(8)) }
+func (a *fakeArgs) shortstr() string  { return string(a.take(int(a.octet()))) }
+func (a *fakeArgs) longstr() string   { return string(a.take(int(a.long()))) }
+func (a *fakeArgs) table()            { a.take(int(a.long())) }
+func (a *fakeArgs) rest() []byte      { return a.take(len(a.b)) }
+func (a *fakeArgs) method() [2]uint16 { return [2]uint16{a.short(), a.short()} }
+
+func (a *fakeArgs) bit(octet byte, i int) bool { return octet&(1<<i) != 0 }
+
+// Writes the arguments of a method
+type fakeWriter struct {
+	bytes.Buffer
+}
+
+func (w *fakeWriter) octet(v byte) *fakeWriter { w.WriteByte(v); return w }
+func (w *fakeWriter) short(v uint16) *fakeWriter {
+	w.Write(binary.BigEndian.AppendUint16(nil, v))
+	return w
+}
+func (w *fakeWriter) long(v uint32) *fakeWriter {
+	w.Write(binary.BigEndian.AppendUint32(nil, v))
+	return w
+}
+func (w *fakeWriter) longlong(v uint64) *fakeWriter {
+	w.Write(binary.BigEndian.AppendUint64(nil, v))
+	return w
+}
+func (w *fakeWriter) shortstr(v string) *fakeWriter {
+	w.octet(byte(len(v)))
+	w.WriteString(v)
+	return w
+}
+func (w *fakeWriter) longstr(v string) *fakeWriter {
+	w.long(uint32(len(v)))
+	w.WriteString(v)
+	return w
+}
+func (w *fakeWriter) table() *fakeWriter { return w.long(0) }
+
+func method(class uint16, id uint16) *fakeWriter {
+	w := &fakeWriter{}
+	return w.short(class).short(id)
+}
+
+func (c *fakeConn) write(typ byte, channel uint16, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := []byte{typ}
+	frame = binary.BigEndian.AppendUint16(frame, channel)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+	frame = append(frame, frameEnd)
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func (c *fakeConn) send(channel uint16, w *fakeWriter) error {
+	return c.write(frameMethod, channel, w.Bytes())
+}
+
+func readFrame(r *bufio.Reader) (byte, uint16, []byte, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	if payload[len(payload)-1] != frameEnd {
+		return 0, 0, nil, errors.New("missing frame end")
+	}
+
+	return header[0], binary.BigEndian.Uint16(header[1:3]), payload[:len(payload)-1], nil
+}
+
+func (c *fakeConn) serve() {
+	broker := c.broker
+	defer func() {
+		c.conn.Close()
+
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		for _, ch := range c.channels {
+			broker.closeChannel(ch)
+		}
+		delete(broker.conns, c)
+	}()
+
+	r := bufio.NewReader(c.conn)
+	protocol := make([]byte, 8)
+	if _, err := io.ReadFull(r, protocol); err != nil || string(protocol) != "AMQP\x00\x00\x09\x01" {
+		return
+	}
+
+	start := method(10, 10).octet(0).octet(9).table().longstr("PLAIN").longstr("en_US")
+	if err := c.send(0, start); err != nil {
+		return
+	}
+
+	for {
+		typ, channel, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+
+		// Anything the broker does not understand ends the connection, which fails the test
+		if err := c.handle(typ, channel, payload); err != nil {
+			return
+		}
+	}
+}
+
+// Handle a frame. Returns io.EOF once the connection is closed.
+func (c *fakeConn) handle(typ byte, channel uint16, payload []byte) error {
+	broker := c.broker
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	args := &fakeArgs{b: payload}
+	ch := c.channels[channel]
+
+	switch typ {
+	case frameHeartbeat:
+		return nil
+	case frameHeader:
+		if ch == nil || ch.publishing == nil {
+			return errors.New("unexpected content header")
+		}
+		args.short() // class
+		args.short() // weight
+		ch.publishing.size = args.longlong()
+		ch.publishing.properties = args.rest()
+		return c.published(ch)
+	case frameBody:
+		if ch == nil || ch.publishing == nil {
+			return errors.New("unexpected content body")
+		}
+		ch.publishing.body = append(ch.publishing.body, payload...)
+		return c.published(ch)
+	case frameMethod:
+	default:
+		return fmt.Errorf("unknown frame type %d", typ)
+	}
+
+	m := args.method()
+	switch m {
+	case [2]uint16{10, 11}: // connection.start-ok
+		return c.send(0, method(10, 30).short(2047).long(131072).short(0))
+	case [2]uint16{10, 31}: // connection.tune-ok
+		return nil
+	case [2]uint16{10, 40}: // connection.open
+		return c.send(0, method(10, 41).shortstr(""))
+	case [2]uint16{10, 50}: // connection.close
+		c.send(0, method(10, 51))
+		return io.EOF
+	case [2]uint16{10, 51}: // connection.close-ok
+		return io.EOF
+	case [2]uint16{20, 10}: // channel.open
+		c.channels[channel] = &fakeChannel{
+			id:        channel,
+			conn:      c,
+			unacked:   map[uint64]fakeUnacked{},
+			consumers: map[string]*fakeConsumer{},
+		}
+		return c.send(channel, method(20, 11).longstr(""))
+	}
+
+	if ch == nil {
+		return fmt.Errorf("method %v on closed channel %d", m, channel)
+	}
+
+	switch m {
+	case [2]uint16{20, 40}: // channel.close
+		broker.closeChannel(ch)
+		delete(c.channels, channel)
+		return c.send(channel, method(20, 41))
+	case [2]uint16{40, 10}: // exchange.declare
+		return c.send(channel, method(40, 11))
+	case [2]uint16{50, 10}: // queue.declare
+		args.short()
+		name := args.shortstr()
+		queue, ok := broker.queues[name]
+		if !ok {
+			queue = &fakeQueue{}
+			broker.queues[name] = queue
+		}
+		return c.send(channel, method(50, 11).shortstr(name).long(uint32(len(queue.messages))).long(uint32(len(queue.consumers))))
+	case [2]uint16{50, 20}: // queue.bind
+		args.short()
+		queue, exchange, key := args.shortstr(), args.shortstr(), args.shortstr()
+		broker.bindings[exchange] = append(broker.bindings[exchange], fakeBinding{queue: queue, key: key})
+		return c.send(channel, method(50, 21))
+	case [2]uint16{85, 10}: // confirm.select
+		ch.confirm = true
+		return c.send(channel, method(85, 11))
+	case [2]uint16{60, 20}: // basic.consume
+		args.short()
+		name, tag := args.shortstr(), args.shortstr()
+		queue, ok := broker.queues[name]
+		if !ok {
+			return fmt.Errorf("no queue %s", name)
+		}
+		if tag == "" {
+			broker.tags++
+			tag = fmt.Sprintf("ctag-%d", broker.tags)
+		}
+		consumer := &fakeConsumer{tag: tag, channel: ch, queue: queue}
+		ch.consumers[tag] = consumer
+		queue.consumers = append(queue.consumers, consumer)
+		if err := c.send(channel, method(60, 21).shortstr(tag)); err != nil {
+			return err
+		}
+		broker.dispatch(queue)
+		return nil
+	case [2]uint16{60, 30}: // basic.cancel
+		tag := args.shortstr()
+		noWait := args.bit(args.octet(), 0)
+		if consumer, ok := ch.consumers[tag]; ok {
+			broker.removeConsumer(consumer)
+		}
+		if noWait {
+			return nil
+		}
+		return c.send(channel, method(60, 31).shortstr(tag))
+	case [2]uint16{60, 40}: // basic.publish
+		args.short()
+		ch.publishing = &fakeMessage{exchange: args.shortstr(), key: args.shortstr()}
+		if ch.confirm {
+			ch.published++
+		}
+		return nil
+	case [2]uint16{60, 80}: // basic.ack
+		tag := args.longlong()
+		multiple := args.bit(args.octet(), 0)
+		ch.settle(tag, multiple)
+		return nil
+	case [2]uint16{60, 90}: // basic.reject
+		tag := args.longlong()
+		requeue := args.bit(args.octet(), 0)
+		broker.settled(ch.settle(tag, false), requeue)
+		return nil
+	case [2]uint16{60, 120}: // basic.nack
+		tag := args.longlong()
+		bits := args.octet()
+		broker.settled(ch.settle(tag, args.bit(bits, 0)), args.bit(bits, 1))
+		return nil
+	default:
+		return fmt.Errorf("unsupported method %v", m)
+	}
+}
+
+// Route the message once all of its content has arrived and confirm it when the channel is in
+// confirm mode
+func (c *fakeConn) published(ch *fakeChannel) error {
+	message := ch.publishing
+	if message.properties == nil || uint64(len(message.body)) < message.size {
+		return nil
+	}
+	ch.publishing = nil
+
+	broker := c.broker
+	if broker.reject != nil && broker.reject(message.body) {
+		if ch.confirm {
+			return c.send(ch.id, method(60, 120).longlong(ch.published).octet(0))
+		}
+		return nil
+	}
+
+	queues := []string{}
+	if message.exchange == "" {
+		queues = append(queues, message.key)
+	}
+	for _, binding := range broker.bindings[message.exchange] {
+		if binding.key == message.key {
+			queues = append(queues, binding.queue)
+		}
+	}
+	for _, name := range queues {
+		if queue, ok := broker.queues[name]; ok {
+			copied := *message
+			queue.messages = append(queue.messages, &copied)
+			broker.dispatch(queue)
+		}
+	}
+
+	if ch.confirm {
+		return c.send(ch.id, method(60, 80).longlong(ch.published).octet(0))
+	}
+	return nil
+}
+
+// Remove the delivery, or every delivery up to it, from those waiting to be acknowledged
+func (ch *fakeChannel) settle(tag uint64, multiple bool) []fakeUnacked {
+	settled := []fakeUnacked{}
+	for t, unacked := range ch.unacked {
+		if t == tag || (multiple && t < tag) {
+			settled = append(settled, unacked)
+			delete(ch.unacked, t)
+		}
+	}
+	return settled
+}
+
+// Put rejected messages back at the front of their queues, or drop them
+func (broker *fakeBroker) settled(messages []fakeUnacked, requeue bool) {
+	if !requeue {
+		return
+	}
+	for _, unacked := range messages {
+		broker.requeue(unacked)
+	}
+	for _, unacked := range messages {
+		broker.dispatch(unacked.queue)
+	}
+}
+
+func (broker *fakeBroker) requeue(unacked fakeUnacked) {
+	unacked.message.redelivered = true
+	unacked.queue.messages = append([]*fakeMessage{unacked.message}, unacked.queue.messages...)
+}
+
+func (broker *fakeBroker) removeConsumer(consumer *fakeConsumer) {
+	delete(consumer.channel.consumers, consumer.tag)
+
+	queue := consumer.queue
+	for i, c := range queue.consumers {
+		if c == consumer {
+			queue.consumers = append(queue.consumers[:i], queue.consumers[i+1:]...)
+			break
+		}
+	}
+}
+
+// Cancel the channel's consumers and requeue what they had not acknowledged, oldest first
+func (broker *fakeBroker) closeChannel(ch *fakeChannel) {
+	for _, consumer := range ch.consumers {
+		broker.removeConsumer(consumer)
+	}
+
+	tags := []uint64{}
+	for tag := range ch.unacked {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] > tags[j] })
+	for _, tag := range tags {
+		broker.requeue(ch.unacked[tag])
+	}
+	ch.unacked = map[uint64]fakeUnacked{}
+
+	for _, queue := range broker.queues {
+		broker.dispatch(queue)
+	}
+}
+
+// Hand out the queue's messages to its consumers in turn. A consumer whose connection has gone
+// is left for that connection to clean up, which requeues what it was sent.
+func (broker *fakeBroker) dispatch(queue *fakeQueue) {
+	for len(queue.messages) > 0 && len(queue.consumers) > 0 {
+		message := queue.messages[0]
+		queue.messages = queue.messages[1:]
+
+		queue.next %= len(queue.consumers)
+		consumer := queue.consumers[queue.next]
+		queue.next++
+
+		ch := consumer.channel
+		ch.delivered++
+		ch.unacked[ch.delivered] = fakeUnacked{queue: queue, message: message}
+
+		redelivered := byte(0)
+		if message.redelivered {
+			redelivered = 1
+		}
+		deliver := method(60, 60).shortstr(consumer.tag).longlong(ch.delivered).octet(redelivered).
+			shortstr(message.exchange).shortstr(message.key)
+		header := &fakeWriter{}
+		header.short(60).short(0).longlong(uint64(len(message.body))).Write(message.properties)
+
+		ch.conn.send(ch.id, deliver)
+		ch.conn.write(frameHeader, ch.id, header.Bytes())
+		if len(message.body) > 0 {
+			ch.conn.write(frameBody, ch.id, message.body)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package streaming
+
+import (
+	"context"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Record headers carrying the message properties
+const (
+	headerContentType = "content-type"
+	headerType        = "type"
+	headerID          = "id"
+	headerAppID       = "app-id"
+)
+
+// The topic live events for the product are published to
+func LiveTopic(product string) string {
+	return "live." + product
+}
+
+// A transport using Kafka or anything speaking its protocol, such as Redpanda.
+// Raw products go to the AWIPS topic, which is consumed as a group named after the app ID.
+// Live events go to a topic per product, which every subscriber reads from the end.
+type KafkaTransport struct {
+	brokers  []string
+	appID    string
+	producer *kgo.Client
+
+	mu        sync.Mutex
+	consumers []*kgo.Client
+}
+
+func NewKafkaTransport(brokers []string, appID string) (*KafkaTransport, error) {
+	producer, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := producer.Ping(context.Background()); err != nil {
+		producer.Close()
+		return nil, err
+	}
+
+	return &KafkaTransport{
+		brokers:  brokers,
+		appID:    appID,
+		producer: producer,
+	}, nil
+}
+
+func (k *KafkaTransport) PublishAWIPS(ctx context.Context, message Message) error {
+	return k.producer.ProduceSync(ctx, kafkaRecord(QueueAWIPS, message.withDefaults(k.appID))).FirstErr()
+}
+
+func (k *KafkaTransport) PublishLive(ctx context.Context, product string, message Message) error {
+	record := kafkaRecord(LiveTopic(product), message.withDefaults(k.appID))
+	// Keep events for the same thing in order
+	if message.ID != "" {
+		record.Key = []byte(message.ID)
+	}
+
+	return k.producer.ProduceSync(ctx, record).FirstErr()
+}
+
+func (k *KafkaTransport) ConsumeAWIPS(ctx context.Context) (<-chan Delivery, error) {
+	commits := newKafkaCommits()
+	client, err := k.consumer(
+		kgo.ConsumeTopics(QueueAWIPS),
+		kgo.ConsumerGroup(k.appID),
+		// Only commit products once they and every product before them are handled
+		kgo.AutoCommitMarks(),
+		// Replacing the default revoke means committing what is marked here
+		kgo.OnPartitionsRevoked(func(ctx context.Context, client *kgo.Client, revoked map[string][]int32) {
+			client.CommitMarkedOffsets(ctx)
+			commits.forget(revoked)
+		}),
+		kgo.OnPartitionsLost(func(_ context.Context, _ *kgo.Client, lost map[string][]int32) {
+			commits.forget(lost)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.consume(ctx, client, commits), nil
+}
+
+func (k *KafkaTransport) SubscribeLive(ctx context.Context, name string, product string) (<-chan Delivery, error) {
+	client, err := k.consumer(
+		kgo.ConsumeTopics(LiveTopic(product)),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()),
+		kgo.ClientID(name),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.consume(ctx, client, nil), nil
+}
+
+func (k *KafkaTransport) consumer(opts ...kgo.Opt) (*kgo.Client, error) {
+	opts = append(opts, kgo.SeedBrokers(k.brokers...), kgo.AllowAutoTopicCreation())
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	k.consumers = append(k.consumers, client)
+	k.mu.Unlock()
+
+	return client, nil
+}
+
+// Poll the client until the context is done or the transport is closed. Deliveries are
+// committed through commits when it is not nil.
+func (k *KafkaTransport) consume(ctx context.Context, client *kgo.Client, commits *kafkaCommits) <-chan Delivery {
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+		for {
+			fetches := client.PollFetches(ctx)
+			if fetches.IsClientClosed() || ctx.Err() != nil {
+				return
+			}
+
+			for record := range fetches.RecordsAll() {
+				d := kafkaDelivery(record)
+				if commits != nil {
+					// Rejected products are not redelivered so are committed too
+					handled := commits.track(client, record)
+					d.ack = handled
+					d.nack = handled
+				}
+
+				select {
+				case out <- d:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// Deliveries are handled concurrently so are acknowledged out of order, and marking a record
+// commits everything before it in the partition. A record is only marked once every record
+// delivered before it in the partition has been handled, so a restart never skips a product
+// that was still being handled.
+type kafkaCommits struct {
+	mu         sync.Mutex
+	partitions map[kafkaPartition]*kafkaPending
+}
+
+type kafkaPartition struct {
+	topic     string
+	partition int32
+}
+
+// The records of a partition delivered but not yet marked
+type kafkaPending struct {
+	// In offset order, as they were delivered
+	records []*kgo.Record
+	handled map[int64]bool
+	// Set once the partition is taken away so late acknowledgements are ignored
+	revoked bool
+}
+
+func newKafkaCommits() *kafkaCommits {
+	return &kafkaCommits{partitions: map[kafkaPartition]*kafkaPending{}}
+}
+
+// Track a delivered record, returning what marks it handled
+func (c *kafkaCommits) track(client *kgo.Client, record *kgo.Record) func() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := kafkaPartition{record.Topic, record.Partition}
+	pending, ok := c.partitions[key]
+	if !ok {
+		pending = &kafkaPending{handled: map[int64]bool{}}
+		c.partitions[key] = pending
+	}
+	pending.records = append(pending.records, record)
+
+	return func() error {
+		c.handle(client, pending, record)
+		return nil
+	}
+}
+
+// Mark the last of the records handled without a gap before it
+func (c *kafkaCommits) handle(client *kgo.Client, pending *kafkaPending, record *kgo.Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pending.revoked {
+		return
+	}
+	pending.handled[record.Offset] = true
+
+	var last *kgo.Record
+	for len(pending.records) > 0 && pending.handled[pending.records[0].Offset] {
+		last = pending.records[0]
+		delete(pending.handled, last.Offset)
+		pending.records = pending.records[1:]
+	}
+	if last != nil {
+		client.MarkCommitRecords(last)
+	}
+}
+
+// Stop tracking partitions that are no longer assigned. Their records are delivered again
+// from the last commit.
+func (c *kafkaCommits) forget(partitions map[string][]int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for topic, numbers := range partitions {
+		for _, number := range numbers {
+			key := kafkaPartition{topic, number}
+			if pending, ok := c.partitions[key]; ok {
+				pending.revoked = true
+				delete(c.partitions, key)
+			}
+		}
+	}
+}
+
+func kafkaRecord(topic string, message Message) *kgo.Record {
+	return &kgo.Record{
+		Topic:     topic,
+		Value:     message.Body,
+		Timestamp: message.Timestamp,
+		Headers: []kgo.RecordHeader{
+			{Key: headerContentType, Value: []byte(message.ContentType)},
+			{Key: headerType, Value: []byte(message.Type)},
+			{Key: headerID, Value: []byte(message.ID)},
+			{Key: headerAppID, Value: []byte(message.AppID)},
+		},
+	}
+}
+
+func kafkaDelivery(record *kgo.Record) Delivery {
+	message := Message{
+		Timestamp: record.Timestamp,
+		Body:      record.Value,
+	}
+
+	for _, header := range record.Headers {
+		switch header.Key {
+		case headerContentType:
+			message.ContentType = string(header.Value)
+		case headerType:
+			message.Type = string(header.Value)
+		case headerID:
+			message.ID = string(header.Value)
+		case headerAppID:
+			message.AppID = string(header.Value)
+		}
+	}
+
+	return Delivery{Message: message}
+}
+
+// Kafka clients reconnect by themselves so the transport is never lost
+func (k *KafkaTransport) Closed() <-chan error {
+	return nil
+}
+
+func (k *KafkaTransport) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, client := range k.consumers {
+		client.Close()
+	}
+	k.consumers = nil
+	k.producer.Close()
+
+	return nil
+}
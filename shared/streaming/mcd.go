@@ -0,0 +1,3 @@
+package streaming
+
+const ProductMCD = "mcd"
@@ -0,0 +1,156 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// How many messages the memory transport holds for each consumer before publishing blocks
+const memoryBuffer = 256
+
+var ErrTransportClosed = errors.New("transport closed")
+
+// A transport within a single process with the same delivery semantics as the brokers.
+// Used to run services together without a broker, such as in tests.
+type MemoryTransport struct {
+	mu     sync.Mutex
+	awips  chan Delivery
+	live   map[string][]chan Delivery
+	closed bool
+	done   chan struct{}
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{
+		awips: make(chan Delivery, memoryBuffer),
+		live:  map[string][]chan Delivery{},
+		done:  make(chan struct{}),
+	}
+}
+
+func (m *MemoryTransport) PublishAWIPS(ctx context.Context, message Message) error {
+	select {
+	case m.awips <- Delivery{Message: message.withDefaults("")}:
+		return nil
+	case <-m.done:
+		return ErrTransportClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Products are shared between every consumer, each going to whichever receives it first
+func (m *MemoryTransport) ConsumeAWIPS(ctx context.Context) (<-chan Delivery, error) {
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case d := <-m.awips:
+				select {
+				case out <- d:
+				case <-ctx.Done():
+					return
+				case <-m.done:
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-m.done:
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (m *MemoryTransport) PublishLive(ctx context.Context, product string, message Message) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrTransportClosed
+	}
+	subscribers := append([]chan Delivery{}, m.live[product]...)
+	m.mu.Unlock()
+
+	d := Delivery{Message: message.withDefaults("")}
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber <- d:
+		case <-m.done:
+			return ErrTransportClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryTransport) SubscribeLive(ctx context.Context, name string, product string) (<-chan Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrTransportClosed
+	}
+
+	in := make(chan Delivery, memoryBuffer)
+	m.live[product] = append(m.live[product], in)
+
+	out := make(chan Delivery)
+	go func() {
+		defer close(out)
+		defer m.unsubscribe(product, in)
+		for {
+			select {
+			case d := <-in:
+				select {
+				case out <- d:
+				case <-ctx.Done():
+					return
+				case <-m.done:
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-m.done:
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (m *MemoryTransport) unsubscribe(product string, in chan Delivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscribers := m.live[product]
+	for i, subscriber := range subscribers {
+		if subscriber == in {
+			m.live[product] = append(subscribers[:i], subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// The memory transport is only lost when closed
+func (m *MemoryTransport) Closed() <-chan error {
+	return nil
+}
+
+func (m *MemoryTransport) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// A transport using the AWIPS queue and the live exchange on RabbitMQ
+type RabbitTransport struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	appID   string
+	closed  chan error
+}
+
+func NewRabbitTransport(url string, appID string) (*RabbitTransport, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := DeclareAWIPSQueue(ch); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare %s: %w", QueueAWIPS, err)
+	}
+	if err := DeclareLiveExchange(ch); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare %s: %w", ExchangeLiveName, err)
+	}
+
+	// Have the broker acknowledge each message so publishing only succeeds once it is safe
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to put channel in confirm mode: %w", err)
+	}
+
+	transport := &RabbitTransport{
+		conn:    conn,
+		channel: ch,
+		appID:   appID,
+		closed:  make(chan error, 1),
+	}
+
+	closures := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		if err, ok := <-closures; ok && err != nil {
+			transport.closed <- err
+		}
+	}()
+
+	return transport, nil
+}
+
+func (r *RabbitTransport) PublishAWIPS(ctx context.Context, message Message) error {
+	return r.publish(ctx, "", QueueAWIPS, message, amqp.Persistent)
+}
+
+func (r *RabbitTransport) PublishLive(ctx context.Context, product string, message Message) error {
+	return r.publish(ctx, ExchangeLiveName, product, message, amqp.Transient)
+}
+
+func (r *RabbitTransport) publish(ctx context.Context, exchange string, key string, message Message, mode uint8) error {
+	confirmation, err := r.channel.PublishWithDeferredConfirmWithContext(ctx,
+		exchange,
+		key,
+		false, // mandatory
+		false, // immediate
+		r.publishing(message, mode),
+	)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to receive confirmation: %w", err)
+	}
+	if !acked {
+		return errors.New("message was rejected by the broker")
+	}
+
+	return nil
+}
+
+func (r *RabbitTransport) publishing(message Message, mode uint8) amqp.Publishing {
+	message = message.withDefaults(r.appID)
+
+	return amqp.Publishing{
+		ContentType:  message.ContentType,
+		DeliveryMode: mode,
+		MessageId:    message.ID,
+		Timestamp:    message.Timestamp,
+		Type:         message.Type,
+		AppId:        message.AppID,
+		Body:         message.Body,
+	}
+}
+
+func (r *RabbitTransport) ConsumeAWIPS(ctx context.Context) (<-chan Delivery, error) {
+	deliveries, err := r.channel.ConsumeWithContext(ctx,
+		QueueAWIPS,
+		"",
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deliver(deliveries), nil
+}
+
+func (r *RabbitTransport) SubscribeLive(ctx context.Context, name string, product string) (<-chan Delivery, error) {
+	// Each subscriber has its own queue that goes away with it
+	q, err := r.channel.QueueDeclare(
+		name,
+		false, // durable
+		true,  // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.channel.QueueBind(q.Name, product, ExchangeLiveName, false, nil); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := r.channel.ConsumeWithContext(ctx,
+		q.Name,
+		name,
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.deliver(deliveries), nil
+}
+
+func (r *RabbitTransport) deliver(deliveries <-chan amqp.Delivery) <-chan Delivery {
+	out := make(chan Delivery)
+
+	go func() {
+		defer close(out)
+		for d := range deliveries {
+			out <- rabbitDelivery(d)
+		}
+	}()
+
+	return out
+}
+
+func rabbitDelivery(d amqp.Delivery) Delivery {
+	return Delivery{
+		Message: Message{
+			ContentType: d.ContentType,
+			Type:        d.Type,
+			ID:          d.MessageId,
+			AppID:       d.AppId,
+			Timestamp:   d.Timestamp,
+			Body:        d.Body,
+		},
+		ack:  func() error { return d.Ack(false) },
+		nack: func() error { return d.Nack(false, false) },
+	}
+}
+
+func (r *RabbitTransport) Closed() <-chan error {
+	return r.closed
+}
+
+func (r *RabbitTransport) Close() error {
+	err := r.conn.Close()
+	if errors.Is(err, amqp.ErrClosed) {
+		return nil
+	}
+	return err
+}
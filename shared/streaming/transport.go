@@ -0,0 +1,96 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	TransportRabbitMQ = "rabbitmq"
+	TransportKafka    = "kafka"
+)
+
+// A message carried by a transport
+type Message struct {
+	ContentType string
+	// The event type of live events. See EventNew, EventUpdate and EventDelete.
+	Type      string
+	ID        string
+	AppID     string
+	Timestamp time.Time
+	Body      []byte
+}
+
+// Fill in the app ID and timestamp if they were not set
+func (m Message) withDefaults(appID string) Message {
+	if m.AppID == "" {
+		m.AppID = appID
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	return m
+}
+
+// A message received from a transport. It must be acknowledged or rejected once handled.
+type Delivery struct {
+	Message
+	ack  func() error
+	nack func() error
+}
+
+// Acknowledge the message has been handled
+func (d Delivery) Ack() error {
+	if d.ack == nil {
+		return nil
+	}
+	return d.ack()
+}
+
+// Reject the message. It is not redelivered.
+func (d Delivery) Nack() error {
+	if d.nack == nil {
+		return nil
+	}
+	return d.nack()
+}
+
+// Carries raw AWIPS products from ingest to parse and live events from parse to the live service
+type Transport interface {
+	// Publish a raw product, returning once the broker has accepted it
+	PublishAWIPS(ctx context.Context, message Message) error
+	// Consume raw products. Each product is delivered to one consumer.
+	ConsumeAWIPS(ctx context.Context) (<-chan Delivery, error)
+	// Publish a live event. The product name, such as ProductWarning, is used to route it.
+	PublishLive(ctx context.Context, product string, message Message) error
+	// Receive live events for the product from now on. Every subscriber receives every event.
+	SubscribeLive(ctx context.Context, name string, product string) (<-chan Delivery, error)
+	// Receives an error if the connection to the broker is lost
+	Closed() <-chan error
+	Close() error
+}
+
+// Create the transport configured by STREAM_TRANSPORT, which is rabbitmq by default or kafka.
+// RabbitMQ is reached with RABBIT_URL and Kafka with the comma separated KAFKA_BROKERS.
+// The app ID identifies the service publishing and consuming.
+func NewTransport(appID string) (Transport, error) {
+	switch strings.ToLower(os.Getenv("STREAM_TRANSPORT")) {
+	case "", TransportRabbitMQ:
+		transport, err := NewRabbitTransport(os.Getenv("RABBIT_URL"), appID)
+		if err != nil {
+			return nil, err
+		}
+		return transport, nil
+	case TransportKafka:
+		transport, err := NewKafkaTransport(strings.Split(os.Getenv("KAFKA_BROKERS"), ","), appID)
+		if err != nil {
+			return nil, err
+		}
+		return transport, nil
+	default:
+		return nil, fmt.Errorf("unknown stream transport %s", os.Getenv("STREAM_TRANSPORT"))
+	}
+}
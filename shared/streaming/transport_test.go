@@ -0,0 +1,507 @@
+package streaming
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/twmb/franz-go/pkg/kfake"
+)
+
+func receive(t *testing.T, deliveries <-chan Delivery) Delivery {
+	t.Helper()
+
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("deliveries closed")
+		}
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+	}
+	return Delivery{}
+}
+
+func expectNothing(t *testing.T, deliveries <-chan Delivery) {
+	t.Helper()
+
+	select {
+	case d := <-deliveries:
+		t.Errorf("expected no delivery, got %q", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryTransportAWIPS(t *testing.T) {
+	transport := NewMemoryTransport()
+	defer transport.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Products published before anyone consumes are kept
+	for _, body := range []string{"TORDMX", "SVSDMX"} {
+		if err := transport.PublishAWIPS(ctx, Message{ContentType: "text/plain", Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each product goes to only one consumer
+	received := map[string]int{}
+	for range 2 {
+		select {
+		case d := <-first:
+			received[string(d.Body)]++
+		case d := <-second:
+			received[string(d.Body)]++
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for delivery")
+		}
+	}
+	if received["TORDMX"] != 1 || received["SVSDMX"] != 1 {
+		t.Errorf("expected each product once, got %v", received)
+	}
+	expectNothing(t, first)
+	expectNothing(t, second)
+}
+
+func TestMemoryTransportLive(t *testing.T) {
+	transport := NewMemoryTransport()
+	defer transport.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Events before subscribing are not received
+	if err := transport.PublishLive(ctx, ProductWarning, Message{Body: []byte("old")}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := transport.SubscribeLive(ctx, "a", ProductWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := transport.SubscribeLive(ctx, "b", ProductWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcds, err := transport.SubscribeLive(ctx, "mcd", ProductMCD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transport.PublishLive(ctx, ProductWarning, Message{Type: EventNew, ID: "KDMX.TO.W.0042", Body: []byte("new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every subscriber to the product receives the event
+	for _, deliveries := range []<-chan Delivery{a, b} {
+		d := receive(t, deliveries)
+		if string(d.Body) != "new" || d.Type != EventNew || d.ID != "KDMX.TO.W.0042" {
+			t.Errorf("unexpected delivery %+v", d.Message)
+		}
+		if d.Timestamp.IsZero() {
+			t.Error("expected the timestamp to be set")
+		}
+	}
+	expectNothing(t, mcds)
+
+	transport.Close()
+	if _, ok := <-a; ok {
+		t.Error("expected deliveries to close with the transport")
+	}
+	if err := transport.PublishLive(ctx, ProductWarning, Message{}); err != ErrTransportClosed {
+		t.Errorf("expected publishing after closing to fail, got %v", err)
+	}
+}
+
+func TestMemoryTransportUnsubscribe(t *testing.T) {
+	transport := NewMemoryTransport()
+	defer transport.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := transport.SubscribeLive(ctx, "a", ProductLSR); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		transport.mu.Lock()
+		n := len(transport.live[ProductLSR])
+		transport.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected the subscriber to be removed once its context is done")
+}
+
+func TestKafkaRecord(t *testing.T) {
+	message := Message{
+		ContentType: "application/json",
+		Type:        EventUpdate,
+		ID:          "KDMX.TO.W.0042",
+		AppID:       "us.parse.awips",
+		Timestamp:   time.Date(2025, 5, 19, 23, 5, 0, 0, time.UTC),
+		Body:        []byte(`{"id":1}`),
+	}
+
+	record := kafkaRecord(LiveTopic(ProductWarning), message)
+	if record.Topic != "live.warning" {
+		t.Errorf("expected topic live.warning, got %s", record.Topic)
+	}
+
+	d := kafkaDelivery(record)
+	if d.ContentType != message.ContentType || d.Type != message.Type || d.ID != message.ID ||
+		d.AppID != message.AppID || !d.Timestamp.Equal(message.Timestamp) || string(d.Body) != string(message.Body) {
+		t.Errorf("expected %+v, got %+v", message, d.Message)
+	}
+}
+
+// Receive deliveries until one has the body, skipping any others
+func receiveBody(t *testing.T, deliveries <-chan Delivery, body string) Delivery {
+	t.Helper()
+
+	for {
+		if d := receive(t, deliveries); string(d.Body) == body {
+			return d
+		}
+	}
+}
+
+func kafkaCluster(t *testing.T) []string {
+	t.Helper()
+
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, QueueAWIPS, LiveTopic(ProductWarning)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cluster.Close)
+
+	return cluster.ListenAddrs()
+}
+
+func TestKafkaTransportAWIPS(t *testing.T) {
+	brokers := kafkaCluster(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport, err := NewKafkaTransport(brokers, "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"TORDMX", "SVSDMX"} {
+		if err := transport.PublishAWIPS(ctx, Message{ContentType: "text/plain", Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, err := transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := receive(t, deliveries)
+	if string(d.Body) != "TORDMX" || d.ContentType != "text/plain" || d.AppID != "us.test" {
+		t.Errorf("unexpected delivery %+v", d.Message)
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	// Received but not handled before the consumer goes away
+	if d := receive(t, deliveries); string(d.Body) != "SVSDMX" {
+		t.Errorf("expected SVSDMX, got %q", d.Body)
+	}
+	transport.Close()
+
+	// The next consumer in the group starts after the last product acknowledged
+	transport, err = NewKafkaTransport(brokers, "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	deliveries, err = transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, deliveries); string(d.Body) != "SVSDMX" {
+		t.Errorf("expected SVSDMX to be redelivered, got %q", d.Body)
+	}
+}
+
+// Consume the AWIPS topic with a new member of the group
+func consumeKafkaAWIPS(t *testing.T, ctx context.Context, brokers []string) (*KafkaTransport, <-chan Delivery) {
+	t.Helper()
+
+	transport, err := NewKafkaTransport(brokers, "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		transport.Close()
+		t.Fatal(err)
+	}
+	return transport, deliveries
+}
+
+func TestKafkaTransportAWIPSOutOfOrder(t *testing.T) {
+	brokers := kafkaCluster(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport, deliveries := consumeKafkaAWIPS(t, ctx, brokers)
+	products := []string{"TORDMX", "SVSDMX", "FFWDMX"}
+	for _, body := range products {
+		if err := transport.PublishAWIPS(ctx, Message{ContentType: "text/plain", Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The later products are handled while the first is still in flight
+	received := []Delivery{}
+	for _, body := range products {
+		received = append(received, receiveBody(t, deliveries, body))
+	}
+	for _, d := range []Delivery{received[2], received[1]} {
+		if err := d.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transport.Close()
+
+	// Nothing was committed past the product still in flight
+	transport, deliveries = consumeKafkaAWIPS(t, ctx, brokers)
+	received = received[:0]
+	for _, body := range products {
+		if d := receive(t, deliveries); string(d.Body) != body {
+			t.Fatalf("expected %s to be redelivered, got %q", body, d.Body)
+		} else {
+			received = append(received, d)
+		}
+	}
+
+	// Once the first is handled everything up to the last is committed
+	for _, d := range []Delivery{received[1], received[2], received[0]} {
+		if err := d.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := transport.PublishAWIPS(ctx, Message{ContentType: "text/plain", Body: []byte("FLWDMX")}); err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, deliveries); string(d.Body) != "FLWDMX" {
+		t.Fatalf("expected FLWDMX, got %q", d.Body)
+	}
+	transport.Close()
+
+	transport, deliveries = consumeKafkaAWIPS(t, ctx, brokers)
+	defer transport.Close()
+	if d := receive(t, deliveries); string(d.Body) != "FLWDMX" {
+		t.Errorf("expected only the unacknowledged FLWDMX to be redelivered, got %q", d.Body)
+	}
+}
+
+func TestKafkaTransportLive(t *testing.T) {
+	brokers := kafkaCluster(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport, err := NewKafkaTransport(brokers, "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	subscribers := []<-chan Delivery{}
+	for _, name := range []string{"a", "b"} {
+		deliveries, err := transport.SubscribeLive(ctx, name, ProductWarning)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Subscribers start at the end of the topic once they have found it, so wait until they have
+		deadline := time.After(5 * time.Second)
+		for waiting := true; waiting; {
+			if err := transport.PublishLive(ctx, ProductWarning, Message{Body: []byte("ready")}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-deliveries:
+				waiting = false
+			case <-time.After(100 * time.Millisecond):
+			case <-deadline:
+				t.Fatal("timed out waiting for the subscriber to start")
+			}
+		}
+		subscribers = append(subscribers, deliveries)
+	}
+
+	err = transport.PublishLive(ctx, ProductWarning, Message{Type: EventNew, ID: "KDMX.TO.W.0042", Body: []byte("new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every subscriber receives the event
+	for _, deliveries := range subscribers {
+		d := receiveBody(t, deliveries, "new")
+		if d.Type != EventNew || d.ID != "KDMX.TO.W.0042" || d.AppID != "us.test" {
+			t.Errorf("unexpected delivery %+v", d.Message)
+		}
+	}
+}
+
+func TestRabbitDelivery(t *testing.T) {
+	r := &RabbitTransport{appID: "us.ingest.awips"}
+
+	publishing := r.publishing(Message{ContentType: "text/plain", Body: []byte("TORDMX")}, amqp.Persistent)
+	if publishing.AppId != "us.ingest.awips" || publishing.Timestamp.IsZero() || publishing.DeliveryMode != amqp.Persistent {
+		t.Errorf("expected defaults to be filled in, got %+v", publishing)
+	}
+
+	d := rabbitDelivery(amqp.Delivery{
+		ContentType: "application/json",
+		Type:        EventDelete,
+		MessageId:   "KDMX.TO.W.0042",
+		AppId:       "us.parse.awips",
+		Body:        []byte(`{}`),
+	})
+	if d.ContentType != "application/json" || d.Type != EventDelete || d.ID != "KDMX.TO.W.0042" || d.AppID != "us.parse.awips" {
+		t.Errorf("unexpected delivery %+v", d.Message)
+	}
+}
+
+func TestRabbitTransportAWIPS(t *testing.T) {
+	broker := newFakeBroker(t)
+	broker.reject = func(body []byte) bool { return string(body) == "REJECTED" }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport, err := NewRabbitTransport(broker.url(), "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Publishing returns once the broker has confirmed the product
+	for _, body := range []string{"TORDMX", "SVSDMX"} {
+		if err := transport.PublishAWIPS(ctx, Message{ContentType: "text/plain", Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := transport.PublishAWIPS(ctx, Message{Body: []byte("REJECTED")}); err == nil {
+		t.Error("expected a product the broker rejects to fail")
+	}
+
+	deliveries, err := transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := receive(t, deliveries)
+	if string(d.Body) != "TORDMX" || d.ContentType != "text/plain" || d.AppID != "us.test" || d.Timestamp.IsZero() {
+		t.Errorf("unexpected delivery %+v", d.Message)
+	}
+	if err := d.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	// Received but not handled before the consumer goes away
+	if d := receive(t, deliveries); string(d.Body) != "SVSDMX" {
+		t.Errorf("expected SVSDMX, got %q", d.Body)
+	}
+	transport.Close()
+
+	transport, err = NewRabbitTransport(broker.url(), "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	deliveries, err = transport.ConsumeAWIPS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = receive(t, deliveries)
+	if string(d.Body) != "SVSDMX" {
+		t.Errorf("expected SVSDMX to be redelivered, got %q", d.Body)
+	}
+
+	// Rejected products are not redelivered
+	if err := d.Nack(); err != nil {
+		t.Fatal(err)
+	}
+	expectNothing(t, deliveries)
+	if n := broker.depth(QueueAWIPS); n != 0 {
+		t.Errorf("expected the queue to be empty, got %d", n)
+	}
+}
+
+func TestRabbitTransportLive(t *testing.T) {
+	broker := newFakeBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transport, err := NewRabbitTransport(broker.url(), "us.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	a, err := transport.SubscribeLive(ctx, "a", ProductWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := transport.SubscribeLive(ctx, "b", ProductWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcds, err := transport.SubscribeLive(ctx, "mcd", ProductMCD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = transport.PublishLive(ctx, ProductWarning, Message{Type: EventNew, ID: "KDMX.TO.W.0042", Body: []byte("new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every subscriber to the product receives the event
+	for _, deliveries := range []<-chan Delivery{a, b} {
+		d := receive(t, deliveries)
+		if string(d.Body) != "new" || d.Type != EventNew || d.ID != "KDMX.TO.W.0042" {
+			t.Errorf("unexpected delivery %+v", d.Message)
+		}
+	}
+	expectNothing(t, mcds)
+
+	// Losing the broker is reported
+	broker.disconnect()
+	select {
+	case err := <-transport.Closed():
+		if err == nil {
+			t.Error("expected an error when the broker goes away")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected losing the broker to be reported")
+	}
+}
+
+func TestNewTransportUnknown(t *testing.T) {
+	t.Setenv("STREAM_TRANSPORT", "carrier-pigeon")
+
+	if _, err := NewTransport("us.test"); err == nil {
+		t.Error("expected an unknown transport to fail")
+	}
+}